@@ -0,0 +1,162 @@
+// Copyright (c) 2022. Homeland Interactive Technology Ltd. All rights reserved.
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/kkkkiven/fishpkg/metrics"
+)
+
+var (
+	_ Interceptor = &MetricsInterceptor{}
+
+	metricQuerySeconds = metrics.NewHistogramVec("db_query_duration_seconds",
+		"Database call latency by operation and table.", nil, "db_system", "operation", "table")
+	metricQueryErrors = metrics.NewCounterVec("db_query_errors_total",
+		"Database calls that returned an error.", "db_system", "operation", "table")
+)
+
+// MetricsInterceptor 指标拦截器, 按操作类型与表名记录调用耗时及错误数
+type MetricsInterceptor struct {
+	EmptyInterceptor
+
+	dbType string
+}
+
+// NewMetricsInterceptor 创建指标拦截器
+func NewMetricsInterceptor(dbType string) *MetricsInterceptor {
+	if dbType == "" {
+		dbType = "mysql"
+	}
+
+	return &MetricsInterceptor{dbType: dbType}
+}
+
+func (i *MetricsInterceptor) observe(op, query string, start time.Time, err error) {
+	table := sqlTable(query)
+
+	metricQuerySeconds.WithLabelValues(i.dbType, op, table).Observe(time.Since(start).Seconds())
+	if err != nil && err != sql.ErrNoRows {
+		metricQueryErrors.WithLabelValues(i.dbType, op, table).Inc()
+	}
+}
+
+func (i *MetricsInterceptor) BeginContext(f BeginContextFunc) BeginContextFunc {
+	return func(ctx context.Context) (*sql.Tx, error) {
+		start := time.Now()
+		tx, err := f(ctx)
+		i.observe("begin", "", start, err)
+		return tx, err
+	}
+}
+
+func (i *MetricsInterceptor) InsertContext(f InsertContextFunc) InsertContextFunc {
+	return func(ctx context.Context, query string, args ...interface{}) (int64, error) {
+		start := time.Now()
+		id, err := f(ctx, query, args...)
+		i.observe("insert", query, start, err)
+		return id, err
+	}
+}
+
+func (i *MetricsInterceptor) UpdateContext(f UpdateContextFunc) UpdateContextFunc {
+	return func(ctx context.Context, query string, args ...interface{}) (int64, error) {
+		start := time.Now()
+		n, err := f(ctx, query, args...)
+		i.observe("update", query, start, err)
+		return n, err
+	}
+}
+
+func (i *MetricsInterceptor) DeleteContext(f DeleteContextFunc) DeleteContextFunc {
+	return func(ctx context.Context, query string, args ...interface{}) (int64, error) {
+		start := time.Now()
+		n, err := f(ctx, query, args...)
+		i.observe("delete", query, start, err)
+		return n, err
+	}
+}
+
+func (i *MetricsInterceptor) SelectContext(f SelectContextFunc) SelectContextFunc {
+	return func(ctx context.Context, query string, args ...interface{}) (Results, error) {
+		start := time.Now()
+		ret, err := f(ctx, query, args...)
+		i.observe("select", query, start, err)
+		return ret, err
+	}
+}
+
+func (i *MetricsInterceptor) SelectOneContext(f SelectOneContextFunc) SelectOneContextFunc {
+	return func(ctx context.Context, query string, args ...interface{}) (OneRow, error) {
+		start := time.Now()
+		ret, err := f(ctx, query, args...)
+		i.observe("select", query, start, err)
+		return ret, err
+	}
+}
+
+func (i *MetricsInterceptor) ExecContext(f ExecContextFunc) ExecContextFunc {
+	return func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+		start := time.Now()
+		ret, err := f(ctx, query, args...)
+		i.observe("exec", query, start, err)
+		return ret, err
+	}
+}
+
+func (i *MetricsInterceptor) QueryContext(f QueryContextFunc) QueryContextFunc {
+	return func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+		start := time.Now()
+		rows, err := f(ctx, query, args...)
+		i.observe("query", query, start, err)
+		return rows, err
+	}
+}
+
+func (i *MetricsInterceptor) QueryRowContext(f QueryRowContextFunc) QueryRowContextFunc {
+	return func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+		start := time.Now()
+		row := f(ctx, query, args...)
+		i.observe("query", query, start, row.Err())
+		return row
+	}
+}
+
+func (i *MetricsInterceptor) TxExecContext(f TxExecContextFunc) TxExecContextFunc {
+	return func(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (sql.Result, error) {
+		start := time.Now()
+		ret, err := f(ctx, tx, query, args...)
+		i.observe("exec", query, start, err)
+		return ret, err
+	}
+}
+
+func (i *MetricsInterceptor) TxQueryContext(f TxQueryContextFunc) TxQueryContextFunc {
+	return func(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (*sql.Rows, error) {
+		start := time.Now()
+		rows, err := f(ctx, tx, query, args...)
+		i.observe("query", query, start, err)
+		return rows, err
+	}
+}
+
+func (i *MetricsInterceptor) TxQueryRowContext(f TxQueryRowContextFunc) TxQueryRowContextFunc {
+	return func(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) *sql.Row {
+		start := time.Now()
+		row := f(ctx, tx, query, args...)
+		i.observe("query", query, start, row.Err())
+		return row
+	}
+}
+
+func (i *MetricsInterceptor) TxPrepareContext(f TxPrepareContextFunc) TxPrepareContextFunc {
+	return func(ctx context.Context, tx *sql.Tx, query string) (*sql.Stmt, error) {
+		start := time.Now()
+		stmt, err := f(ctx, tx, query)
+		i.observe("prepare", query, start, err)
+		return stmt, err
+	}
+}
@@ -0,0 +1,321 @@
+// Copyright (c) 2022. Homeland Interactive Technology Ltd. All rights reserved.
+
+package db
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kkkkiven/fishpkg/sprotocol/tracer"
+)
+
+// DEFAULT_SQL_MAX_LEN 记录到span中的SQL最大长度
+const DEFAULT_SQL_MAX_LEN = 1024
+
+var (
+	_ Interceptor = &TracingInterceptor{}
+
+	tableRegexp = regexp.MustCompile("(?i)\\b(?:from|into|update|join|table)\\s+([`\"\\w.]+)")
+)
+
+// TracingInterceptor 链路追踪拦截器, 以 *Context 方法传入的ctx为父节点创建子span, ctx中无父span时不创建,
+// 记录脱敏后的SQL、表名、行数与错误; 耗时超过slow的调用强制采样, 无父span时作为新链路的根节点
+type TracingInterceptor struct {
+	EmptyInterceptor
+
+	dbType string
+	slow   time.Duration
+}
+
+// NewTracingInterceptor 创建链路追踪拦截器, slow为慢查询阈值, 0为不强制采样
+func NewTracingInterceptor(dbType string, slow time.Duration) *TracingInterceptor {
+	if dbType == "" {
+		dbType = "mysql"
+	}
+
+	return &TracingInterceptor{dbType: dbType, slow: slow}
+}
+
+// _DBSpan 单次调用的追踪状态
+type _DBSpan struct {
+	i     *TracingInterceptor
+	ctx   context.Context
+	op    string
+	query string
+	start time.Time
+	span  *tracer.Span
+}
+
+func (i *TracingInterceptor) start(ctx context.Context, op, query string) *_DBSpan {
+	if ctx == nil {
+		ctx = context.TODO()
+	}
+
+	s := &_DBSpan{i: i, ctx: ctx, op: op, query: query, start: time.Now()}
+
+	// 后台任务等无链路的调用不单独创建根节点
+	if tracer.HasParent(ctx) {
+		s.span, s.ctx = tracer.StartSpan(ctx, s.name())
+	}
+
+	return s
+}
+
+func (s *_DBSpan) name() string {
+	if table := sqlTable(s.query); table != "" {
+		return "db." + s.op + " " + table
+	}
+
+	return "db." + s.op
+}
+
+// end 结束span, rows小于0时不记录行数, 查询记录为db.rows, 写操作记录为db.rows_affected
+func (s *_DBSpan) end(err error, rows int64) {
+	span := s.span
+
+	if s.i.slow > 0 && time.Since(s.start) >= s.i.slow {
+		if span != nil {
+			span.ForceSample()
+		} else {
+			span, _ = tracer.StartSpan(s.ctx, s.name(), tracer.SetStartTime(s.start), tracer.SetForceSample())
+		}
+
+		span.SetBool("db.slow", true)
+	}
+
+	if span == nil {
+		return
+	}
+
+	span.SetString("db.system", s.i.dbType)
+	span.SetString("db.operation", s.op)
+	if s.query != "" {
+		span.SetString("db.statement", SanitizeSQL(s.query))
+	}
+	if table := sqlTable(s.query); table != "" {
+		span.SetString("db.table", table)
+	}
+	if rows >= 0 {
+		if s.op == "select" {
+			span.SetInt("db.rows", rows)
+		} else {
+			span.SetInt("db.rows_affected", rows)
+		}
+	}
+
+	if err != nil && err != sql.ErrNoRows {
+		span.RecordError(err)
+	} else {
+		span.SetStatus(tracer.STATUS_OK, "")
+	}
+
+	span.End()
+}
+
+func (i *TracingInterceptor) BeginContext(f BeginContextFunc) BeginContextFunc {
+	return func(ctx context.Context) (*sql.Tx, error) {
+		s := i.start(ctx, "begin", "")
+		tx, err := f(ctx)
+		s.end(err, -1)
+		return tx, err
+	}
+}
+
+func (i *TracingInterceptor) InsertContext(f InsertContextFunc) InsertContextFunc {
+	return func(ctx context.Context, query string, args ...interface{}) (int64, error) {
+		s := i.start(ctx, "insert", query)
+		id, err := f(s.ctx, query, args...)
+		s.end(err, -1)
+		return id, err
+	}
+}
+
+func (i *TracingInterceptor) UpdateContext(f UpdateContextFunc) UpdateContextFunc {
+	return func(ctx context.Context, query string, args ...interface{}) (int64, error) {
+		s := i.start(ctx, "update", query)
+		n, err := f(s.ctx, query, args...)
+		s.end(err, n)
+		return n, err
+	}
+}
+
+func (i *TracingInterceptor) DeleteContext(f DeleteContextFunc) DeleteContextFunc {
+	return func(ctx context.Context, query string, args ...interface{}) (int64, error) {
+		s := i.start(ctx, "delete", query)
+		n, err := f(s.ctx, query, args...)
+		s.end(err, n)
+		return n, err
+	}
+}
+
+func (i *TracingInterceptor) SelectContext(f SelectContextFunc) SelectContextFunc {
+	return func(ctx context.Context, query string, args ...interface{}) (Results, error) {
+		s := i.start(ctx, "select", query)
+		ret, err := f(s.ctx, query, args...)
+		s.end(err, int64(len(ret)))
+		return ret, err
+	}
+}
+
+func (i *TracingInterceptor) SelectOneContext(f SelectOneContextFunc) SelectOneContextFunc {
+	return func(ctx context.Context, query string, args ...interface{}) (OneRow, error) {
+		s := i.start(ctx, "select", query)
+		ret, err := f(s.ctx, query, args...)
+		s.end(err, -1)
+		return ret, err
+	}
+}
+
+func (i *TracingInterceptor) ExecContext(f ExecContextFunc) ExecContextFunc {
+	return func(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+		s := i.start(ctx, "exec", query)
+		ret, err := f(s.ctx, query, args...)
+		s.end(err, rowsAffected(ret, err))
+		return ret, err
+	}
+}
+
+func (i *TracingInterceptor) QueryContext(f QueryContextFunc) QueryContextFunc {
+	return func(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+		s := i.start(ctx, "query", query)
+		rows, err := f(s.ctx, query, args...)
+		s.end(err, -1)
+		return rows, err
+	}
+}
+
+func (i *TracingInterceptor) QueryRowContext(f QueryRowContextFunc) QueryRowContextFunc {
+	return func(ctx context.Context, query string, args ...interface{}) *sql.Row {
+		s := i.start(ctx, "query", query)
+		row := f(s.ctx, query, args...)
+		s.end(row.Err(), -1)
+		return row
+	}
+}
+
+func (i *TracingInterceptor) TxExecContext(f TxExecContextFunc) TxExecContextFunc {
+	return func(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (sql.Result, error) {
+		s := i.start(ctx, "exec", query)
+		ret, err := f(s.ctx, tx, query, args...)
+		s.end(err, rowsAffected(ret, err))
+		return ret, err
+	}
+}
+
+func (i *TracingInterceptor) TxQueryContext(f TxQueryContextFunc) TxQueryContextFunc {
+	return func(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) (*sql.Rows, error) {
+		s := i.start(ctx, "query", query)
+		rows, err := f(s.ctx, tx, query, args...)
+		s.end(err, -1)
+		return rows, err
+	}
+}
+
+func (i *TracingInterceptor) TxQueryRowContext(f TxQueryRowContextFunc) TxQueryRowContextFunc {
+	return func(ctx context.Context, tx *sql.Tx, query string, args ...interface{}) *sql.Row {
+		s := i.start(ctx, "query", query)
+		row := f(s.ctx, tx, query, args...)
+		s.end(row.Err(), -1)
+		return row
+	}
+}
+
+func (i *TracingInterceptor) TxPrepareContext(f TxPrepareContextFunc) TxPrepareContextFunc {
+	return func(ctx context.Context, tx *sql.Tx, query string) (*sql.Stmt, error) {
+		s := i.start(ctx, "prepare", query)
+		stmt, err := f(s.ctx, tx, query)
+		s.end(err, -1)
+		return stmt, err
+	}
+}
+
+func rowsAffected(ret sql.Result, err error) int64 {
+	if err != nil || ret == nil {
+		return -1
+	}
+
+	n, err := ret.RowsAffected()
+	if err != nil {
+		return -1
+	}
+
+	return n
+}
+
+// sqlTable 提取SQL中的第一个表名
+func sqlTable(query string) string {
+	m := tableRegexp.FindStringSubmatch(query)
+	if len(m) < 2 {
+		return ""
+	}
+
+	return strings.Trim(m[1], "`\"")
+}
+
+// SanitizeSQL 将SQL中的字符串与数字字面量替换为?, 合并空白并截断过长语句
+func SanitizeSQL(query string) string {
+	var b strings.Builder
+	b.Grow(len(query))
+
+	space := false
+	for i := 0; i < len(query); i++ {
+		c := query[i]
+
+		switch {
+		case c == '\'' || c == '"':
+			// 跳过引号内的内容, 支持反斜杠与双引号转义
+			for i++; i < len(query); i++ {
+				if query[i] == '\\' {
+					i++
+					continue
+				}
+				if query[i] == c {
+					if i+1 < len(query) && query[i+1] == c {
+						i++
+						continue
+					}
+					break
+				}
+			}
+			b.WriteByte('?')
+		case c >= '0' && c <= '9' && !isIdentByte(prevByte(query, i)):
+			for i+1 < len(query) && (isIdentByte(query[i+1]) || query[i+1] == '.') {
+				i++
+			}
+			b.WriteByte('?')
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			if !space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			space = true
+			continue
+		default:
+			b.WriteByte(c)
+		}
+
+		space = false
+	}
+
+	s := strings.TrimSpace(b.String())
+	if len(s) > DEFAULT_SQL_MAX_LEN {
+		s = s[:DEFAULT_SQL_MAX_LEN] + "..."
+	}
+
+	return s
+}
+
+func prevByte(s string, i int) byte {
+	if i == 0 {
+		return ' '
+	}
+
+	return s[i-1]
+}
+
+func isIdentByte(c byte) bool {
+	return c == '_' || c == '$' || c == '`' ||
+		(c >= '0' && c <= '9') || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
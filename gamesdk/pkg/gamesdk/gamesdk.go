@@ -21,6 +21,9 @@ import (
 	"github.com/kkkkiven/fishpkg/sprotocol/core"
 )
 
+// PATH_DEBUG_COUNT 管理端口中连接与玩家数的接口路径
+const PATH_DEBUG_COUNT = "/debug/count"
+
 func GetServer() *server.Server {
 	return server.GetServer()
 }
@@ -42,6 +45,11 @@ func Init(conf config.EntityServer, sdkConf sdk.CConfig) error {
 
 	config.Init(conf)
 
+	// 管理端口输出连接与玩家数
+	sdk.Admin().HandleJSON(PATH_DEBUG_COUNT, func() interface{} {
+		return GetCount()
+	})
+
 	return err
 }
 
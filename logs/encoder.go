@@ -0,0 +1,133 @@
+package logs
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 日志编码格式
+const (
+	ENCODER_TEXT = "text"
+	ENCODER_JSON = "json"
+)
+
+// Entry 单条日志
+type Entry struct {
+	Time   time.Time
+	Level  int
+	Caller string
+	Line   int
+	Logger string
+	Msg    string
+	Fields []Field
+}
+
+// Encoder 日志编码器, 输出包含换行符的完整日志行
+type Encoder interface {
+	Encode(prefix string, e *Entry) string
+}
+
+// NewEncoder 根据格式名称创建编码器
+func NewEncoder(name string) (Encoder, error) {
+	switch strings.ToLower(name) {
+	case "", ENCODER_TEXT:
+		return &TextEncoder{}, nil
+	case ENCODER_JSON:
+		return &JSONEncoder{}, nil
+	}
+
+	return nil, fmt.Errorf("bad log encoder: %v", name)
+}
+
+// TextEncoder 文本编码器, 格式与原有日志一致, 字段以key=value追加在消息之后
+type TextEncoder struct{}
+
+func (enc *TextEncoder) Encode(prefix string, e *Entry) string {
+	var b strings.Builder
+
+	t := e.Time.Local().Format("2006/01/02 15:04:05.999999")
+	fmt.Fprintf(&b, "%s%-26s [%s] %s(%d): ", prefix, t, log.getLevelString(e.Level), e.Caller, e.Line)
+
+	if e.Logger != "" {
+		b.WriteString("[" + e.Logger + "] ")
+	}
+	b.WriteString(e.Msg)
+
+	for _, f := range e.Fields {
+		b.WriteByte(' ')
+		b.WriteString(f.Key)
+		b.WriteByte('=')
+		b.WriteString(textValue(f.Value))
+	}
+
+	b.WriteByte('\n')
+	return b.String()
+}
+
+// textValue 格式化字段值, 含空白或引号的字符串加引号
+func textValue(v interface{}) string {
+	var s string
+	switch val := v.(type) {
+	case string:
+		s = val
+	case error:
+		s = val.Error()
+	default:
+		return fmt.Sprint(val)
+	}
+
+	if s == "" || strings.ContainsAny(s, " \t\n\"=") {
+		return strconv.Quote(s)
+	}
+
+	return s
+}
+
+// JSONEncoder JSON编码器, 每条日志为一行JSON对象
+type JSONEncoder struct{}
+
+func (enc *JSONEncoder) Encode(prefix string, e *Entry) string {
+	var b strings.Builder
+
+	b.WriteByte('{')
+	writeJSONField(&b, "time", e.Time.Local().Format(time.RFC3339Nano), true)
+	writeJSONField(&b, "level", LevelString(e.Level), false)
+	writeJSONField(&b, "caller", e.Caller+":"+strconv.Itoa(e.Line), false)
+	if prefix = strings.TrimSpace(prefix); prefix != "" {
+		writeJSONField(&b, "prefix", strings.Trim(prefix, "[]"), false)
+	}
+	if e.Logger != "" {
+		writeJSONField(&b, "logger", e.Logger, false)
+	}
+	writeJSONField(&b, "msg", e.Msg, false)
+
+	for _, f := range e.Fields {
+		writeJSONField(&b, f.Key, f.Value, false)
+	}
+
+	b.WriteString("}\n")
+	return b.String()
+}
+
+func writeJSONField(b *strings.Builder, key string, val interface{}, first bool) {
+	if !first {
+		b.WriteByte(',')
+	}
+
+	k, _ := json.Marshal(key)
+	b.Write(k)
+	b.WriteByte(':')
+
+	if err, ok := val.(error); ok {
+		val = err.Error()
+	}
+
+	v, err := json.Marshal(val)
+	if err != nil {
+		v, _ = json.Marshal(fmt.Sprint(val))
+	}
+	b.Write(v)
+}
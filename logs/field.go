@@ -0,0 +1,86 @@
+package logs
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Field 结构化日志字段
+type Field struct {
+	Key   string
+	Value interface{}
+}
+
+// String 字符串字段
+func String(key, val string) Field {
+	return Field{Key: key, Value: val}
+}
+
+// Int 整型字段
+func Int(key string, val int) Field {
+	return Field{Key: key, Value: val}
+}
+
+// Int64 整型字段
+func Int64(key string, val int64) Field {
+	return Field{Key: key, Value: val}
+}
+
+// Uint64 无符号整型字段
+func Uint64(key string, val uint64) Field {
+	return Field{Key: key, Value: val}
+}
+
+// Float64 浮点字段
+func Float64(key string, val float64) Field {
+	return Field{Key: key, Value: val}
+}
+
+// Bool 布尔字段
+func Bool(key string, val bool) Field {
+	return Field{Key: key, Value: val}
+}
+
+// Duration 时长字段, 以字符串形式输出
+func Duration(key string, val time.Duration) Field {
+	return Field{Key: key, Value: val.String()}
+}
+
+// Err 错误字段, 键名固定为error, err为nil时输出空串
+func Err(err error) Field {
+	if err == nil {
+		return Field{Key: "error", Value: ""}
+	}
+
+	return Field{Key: "error", Value: err.Error()}
+}
+
+// Any 任意类型字段, JSON格式下按encoding/json编码
+func Any(key string, val interface{}) Field {
+	return Field{Key: key, Value: val}
+}
+
+// ContextExtractor 从context中提取日志字段, 如链路追踪的trace_id与span_id
+type ContextExtractor func(ctx context.Context) []Field
+
+var extractor atomic.Value
+
+// SetContextExtractor 设置context字段提取函数, 由链路追踪等包在初始化时注册
+func SetContextExtractor(fn ContextExtractor) {
+	extractor.Store(fn)
+}
+
+// contextFields 提取context中的日志字段
+func contextFields(ctx context.Context) []Field {
+	if ctx == nil {
+		return nil
+	}
+
+	fn, _ := extractor.Load().(ContextExtractor)
+	if fn == nil {
+		return nil
+	}
+
+	return fn(ctx)
+}
@@ -0,0 +1,248 @@
+package logs
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Logger 结构化日志, 携带名称与公共字段, 名称用于按包调整日志级别
+type Logger struct {
+	name   string
+	fields []Field
+}
+
+var std = &Logger{}
+
+// New 创建结构化日志, name通常为包名或模块名, 如"servicesdk/core"
+func New(name string) *Logger {
+	return &Logger{name: name}
+}
+
+// With 创建附加公共字段的默认日志
+func With(fields ...Field) *Logger {
+	return std.With(fields...)
+}
+
+// Ctx 创建附加context字段(如trace_id)的默认日志
+func Ctx(ctx context.Context) *Logger {
+	return std.Ctx(ctx)
+}
+
+// Name 获取日志名称
+func (l *Logger) Name() string {
+	return l.name
+}
+
+// Named 创建子日志, 名称以"/"连接
+func (l *Logger) Named(name string) *Logger {
+	if l.name != "" {
+		name = l.name + "/" + name
+	}
+
+	return &Logger{name: name, fields: l.fields}
+}
+
+// With 创建附加公共字段的子日志
+func (l *Logger) With(fields ...Field) *Logger {
+	if len(fields) == 0 {
+		return l
+	}
+
+	fs := make([]Field, 0, len(l.fields)+len(fields))
+	fs = append(fs, l.fields...)
+	fs = append(fs, fields...)
+
+	return &Logger{name: l.name, fields: fs}
+}
+
+// Ctx 创建附加context字段的子日志
+func (l *Logger) Ctx(ctx context.Context) *Logger {
+	return l.With(contextFields(ctx)...)
+}
+
+// Enabled 指定级别的日志是否输出
+func (l *Logger) Enabled(level int) bool {
+	return level <= l.level()
+}
+
+func (l *Logger) Error(msg string, fields ...Field) {
+	l.write(LOG_ERROR, msg, fields)
+}
+
+func (l *Logger) Waring(msg string, fields ...Field) {
+	l.write(LOG_WARING, msg, fields)
+}
+
+func (l *Logger) Info(msg string, fields ...Field) {
+	l.write(LOG_INFO, msg, fields)
+}
+
+func (l *Logger) Debug(msg string, fields ...Field) {
+	l.write(LOG_DEBUG, msg, fields)
+}
+
+func (l *Logger) Trace(msg string, fields ...Field) {
+	l.write(LOG_TRACE, msg, fields)
+}
+
+// level 获取生效的日志级别, 优先使用按包设置的级别
+func (l *Logger) level() int {
+	if l.name != "" {
+		if level, ok := packageLevel(l.name); ok {
+			return level
+		}
+	}
+
+	return log.getLevel()
+}
+
+func (l *Logger) write(level int, msg string, fields []Field) {
+	if level > l.level() {
+		return
+	}
+
+	if !sample(level, l.name, msg) {
+		return
+	}
+
+	fs := fields
+	if len(l.fields) != 0 {
+		fs = make([]Field, 0, len(l.fields)+len(fields))
+		fs = append(fs, l.fields...)
+		fs = append(fs, fields...)
+	}
+
+	log.writeEntry(3, &Entry{Level: level, Logger: l.name, Msg: msg, Fields: fs})
+}
+
+// 按包设置的日志级别, 写时复制
+var (
+	pkgMu     sync.Mutex
+	pkgLevels atomic.Value // map[string]int
+)
+
+// SetPackageLevel 运行时设置指定名称及其子名称的日志级别, 仅作用于New创建的结构化日志
+func SetPackageLevel(name string, level int) {
+	pkgMu.Lock()
+	defer pkgMu.Unlock()
+
+	old, _ := pkgLevels.Load().(map[string]int)
+	m := make(map[string]int, len(old)+1)
+	for k, v := range old {
+		m[k] = v
+	}
+	m[name] = level
+
+	pkgLevels.Store(m)
+}
+
+// ClearPackageLevel 清除按包设置的日志级别, 恢复使用全局级别
+func ClearPackageLevel(name string) {
+	pkgMu.Lock()
+	defer pkgMu.Unlock()
+
+	old, _ := pkgLevels.Load().(map[string]int)
+	m := make(map[string]int, len(old))
+	for k, v := range old {
+		if k != name {
+			m[k] = v
+		}
+	}
+
+	pkgLevels.Store(m)
+}
+
+// PackageLevels 获取按包设置的日志级别
+func PackageLevels() map[string]int {
+	old, _ := pkgLevels.Load().(map[string]int)
+
+	m := make(map[string]int, len(old))
+	for k, v := range old {
+		m[k] = v
+	}
+
+	return m
+}
+
+// packageLevel 按最长前缀匹配名称对应的级别
+func packageLevel(name string) (int, bool) {
+	m, _ := pkgLevels.Load().(map[string]int)
+	if len(m) == 0 {
+		return 0, false
+	}
+
+	for {
+		if level, ok := m[name]; ok {
+			return level, true
+		}
+
+		i := strings.LastIndexByte(name, '/')
+		if i < 0 {
+			return 0, false
+		}
+		name = name[:i]
+	}
+}
+
+// _Sampler 日志采样, 每个周期内同一条日志先输出first条, 之后每thereafter条输出一条
+type _Sampler struct {
+	first      int
+	thereafter int
+	tick       time.Duration
+
+	mu     sync.Mutex
+	window int64
+	counts map[string]int
+}
+
+var sampler atomic.Value // *_Sampler
+
+// SetSampling 设置DEBUG与TRACE级别日志的采样, 按日志名称与消息内容计数, first为0时关闭采样
+func SetSampling(first, thereafter int, tick time.Duration) {
+	if first <= 0 {
+		sampler.Store((*_Sampler)(nil))
+		return
+	}
+
+	if tick <= 0 {
+		tick = time.Second
+	}
+
+	sampler.Store(&_Sampler{first: first, thereafter: thereafter, tick: tick, counts: make(map[string]int, 0)})
+}
+
+// sample 是否输出该条日志
+func sample(level int, name, msg string) bool {
+	if level < LOG_DEBUG {
+		return true
+	}
+
+	s, _ := sampler.Load().(*_Sampler)
+	if s == nil {
+		return true
+	}
+
+	return s.check(name + "\xff" + msg)
+}
+
+func (s *_Sampler) check(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now().UnixNano() / int64(s.tick)
+	if now != s.window {
+		s.window = now
+		s.counts = make(map[string]int, len(s.counts))
+	}
+
+	s.counts[key]++
+	n := s.counts[key]
+	if n <= s.first {
+		return true
+	}
+
+	return s.thereafter > 0 && (n-s.first)%s.thereafter == 0
+}
@@ -3,8 +3,10 @@ package logs
 
 import (
 	"fmt"
-	"os"
 	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -41,73 +43,131 @@ func Init(dir string, file string, level int, savefile bool, prefix ...string) {
 	if len(prefix) != 0 {
 		log.setPrefix(fmt.Sprintf("[%v] ", prefix[0]))
 	}
+
+	// 保存到文件时经异步缓冲写入, 否则同步输出到标准输出
+	if savefile {
+		SetSinks(&FileSink{dir: dir, file: file, rotation: log.getRotation})
+	} else {
+		SetSinks()
+	}
+}
+
+// SetLevel 运行时调整日志级别
+func SetLevel(level int) {
+	log.setLevel(level)
+}
+
+// SetEncoder 设置日志编码器, 对原有格式化函数与结构化日志均生效
+func SetEncoder(enc Encoder) {
+	log.setEncoder(enc)
+}
+
+// GetLevel 获取当前日志级别
+func GetLevel() int {
+	return log.getLevel()
+}
+
+// LevelString 获取日志级别名称
+func LevelString(level int) string {
+	switch level {
+	case LOG_ERROR:
+		return "error"
+	case LOG_WARING:
+		return "waring"
+	case LOG_INFO:
+		return "info"
+	case LOG_DEBUG:
+		return "debug"
+	case LOG_TRACE:
+		return "trace"
+	}
+
+	return "unknown"
+}
+
+// ParseLevel 解析日志级别, 支持名称(不区分大小写)与数字
+func ParseLevel(s string) (int, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "error", "err", "0":
+		return LOG_ERROR, nil
+	case "waring", "warning", "warn", "wrn", "1":
+		return LOG_WARING, nil
+	case "info", "inf", "2":
+		return LOG_INFO, nil
+	case "debug", "dbg", "3":
+		return LOG_DEBUG, nil
+	case "trace", "trc", "4":
+		return LOG_TRACE, nil
+	}
+
+	return 0, fmt.Errorf("bad log level: %v", s)
 }
 
 func Error(err ...interface{}) {
-	if LOG_ERROR > log.level {
+	if LOG_ERROR > log.getLevel() {
 		return
 	}
 	log.write(LOG_ERROR, fmt.Sprint(err...))
 }
 
 func Waring(war ...interface{}) {
-	if LOG_WARING > log.level {
+	if LOG_WARING > log.getLevel() {
 		return
 	}
 	log.write(LOG_WARING, fmt.Sprint(war...))
 }
 
 func Info(info ...interface{}) {
-	if LOG_INFO > log.level {
+	if LOG_INFO > log.getLevel() {
 		return
 	}
 	log.write(LOG_INFO, fmt.Sprint(info...))
 }
 
 func Debug(deb ...interface{}) {
-	if LOG_DEBUG > log.level {
+	if LOG_DEBUG > log.getLevel() {
 		return
 	}
 	log.write(LOG_DEBUG, fmt.Sprint(deb...))
 }
 
 func Trace(deb ...interface{}) {
-	if LOG_TRACE > log.level {
+	if LOG_TRACE > log.getLevel() {
 		return
 	}
 	log.write(LOG_TRACE, fmt.Sprint(deb...))
 }
 
 func Errorf(format string, v ...interface{}) {
-	if LOG_ERROR > log.level {
+	if LOG_ERROR > log.getLevel() {
 		return
 	}
 	log.write(LOG_ERROR, fmt.Sprintf(format, v...))
 }
 
 func Waringf(format string, v ...interface{}) {
-	if LOG_WARING > log.level {
+	if LOG_WARING > log.getLevel() {
 		return
 	}
 	log.write(LOG_WARING, fmt.Sprintf(format, v...))
 }
 
 func Infof(format string, v ...interface{}) {
-	if LOG_INFO > log.level {
+	if LOG_INFO > log.getLevel() {
 		return
 	}
 	log.write(LOG_INFO, fmt.Sprintf(format, v...))
 }
 
 func Debugf(format string, v ...interface{}) {
-	if LOG_DEBUG > log.level {
+	if LOG_DEBUG > log.getLevel() {
 		return
 	}
 	log.write(LOG_DEBUG, fmt.Sprintf(format, v...))
 }
 
 func Tracef(format string, v ...interface{}) {
-	if LOG_TRACE > log.level {
+	if LOG_TRACE > log.getLevel() {
 		return
 	}
 	log.write(LOG_TRACE, fmt.Sprintf(format, v...))
@@ -117,18 +177,29 @@ func Tracef(format string, v ...interface{}) {
  * 日志执行函数
  */
 type mylog struct {
-	log      chan string // 日志chan
-	dir      string      // 日志存放目录
-	file     string      // 日志文件名
-	savefile bool        // 是否保存到文件
-	level    int         // 日志级别
-	prefix   string      // 日志前缀
+	dir      string // 日志存放目录
+	file     string // 日志文件名
+	savefile bool   // 是否保存到文件
+	level    int32  // 日志级别
+	prefix   string // 日志前缀
+	encoder  atomic.Value
+	rotation atomic.Value // *_Rotation
+
+	// 异步写入
+	buf    *_Buffer
+	sinks  atomic.Value  // *_Sinks
+	smu    sync.Mutex    // 串行化sinks的替换与追加
+	done   chan struct{} // 写协程退出通知
+	closed int32
 }
 
+var defaultEncoder Encoder = &TextEncoder{}
+
 func newMylog() *mylog {
 	log := &mylog{}
 
-	log.log = make(chan string, 100)
+	log.buf = newBuffer(DEFAULT_BUFFER_SIZE, OVERFLOW_DROP_NEWEST)
+	log.done = make(chan struct{})
 	log.dir = "/opt/logs"
 	log.file = "out"
 	log.savefile = false
@@ -149,7 +220,11 @@ func (l *mylog) setSavefile(b bool) {
 }
 
 func (l *mylog) setLevel(level int) {
-	l.level = level
+	atomic.StoreInt32(&l.level, int32(level))
+}
+
+func (l *mylog) getLevel() int {
+	return int(atomic.LoadInt32(&l.level))
 }
 
 func (l *mylog) setPrefix(prefix string) {
@@ -174,52 +249,39 @@ func (l *mylog) getLevelString(level int) string {
 }
 
 func (l *mylog) write(level int, str string) {
-	// 输出日志
-	pc, _, line, _ := runtime.Caller(2)
-	p := runtime.FuncForPC(pc)
-	t := time.Now().Local().Format("2006/01/02 15:04:05.999999")
-	str = fmt.Sprintf("%s%-26s [%s] %s(%d): %s\n", l.prefix, t, l.getLevelString(level), p.Name(), line, str)
-	// 输出到控制台
-	if false == l.savefile {
+	l.writeEntry(3, &Entry{Level: level, Msg: str})
+}
+
+// writeEntry 编码并输出日志, skip为调用方相对本函数的栈深度
+func (l *mylog) writeEntry(skip int, e *Entry) {
+	pc, _, line, _ := runtime.Caller(skip)
+	if p := runtime.FuncForPC(pc); p != nil {
+		e.Caller = p.Name()
+	}
+	e.Line = line
+	e.Time = time.Now()
+
+	str := l.getEncoder().Encode(l.prefix, e)
+	// 未设置输出目标或已关闭时同步输出到控制台
+	if l.getSinks() == nil || atomic.LoadInt32(&l.closed) == 1 {
 		fmt.Print(str)
 		return
 	}
 
-	// 输出到文件
-	l.log <- str
+	// 写入缓冲区, 由写协程输出
+	if !l.buf.push(_Line{level: e.Level, str: str}) {
+		fmt.Print(str)
+	}
 }
 
-func (l *mylog) run() {
-
-	var last, now time.Time
-
-	var fp *os.File
-
-	for {
-		str := <-l.log
-
-		// 获取时间
-		now = time.Now()
-
-		if (now.Day() != last.Day()) || (fp == nil) { // 跨天或之前文件打开失败,则重新创建文件
-			if fp != nil {
-				fp.Close()
-			}
-
-			// 判断文件夹是否存在
-			_, err := os.Stat(l.dir)
-			if nil != err {
-				os.MkdirAll(l.dir, os.ModePerm)
-			}
-
-			path := fmt.Sprintf("%s/%s-%04d-%02d-%02d.log", l.dir, l.file,
-				now.Year(), now.Month(), now.Day())
-			fp, _ = os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, os.ModePerm)
-		}
+func (l *mylog) setEncoder(enc Encoder) {
+	l.encoder.Store(&enc)
+}
 
-		if fp != nil {
-			fp.WriteString(str)
-		}
-		last = now
+func (l *mylog) getEncoder() Encoder {
+	if enc, ok := l.encoder.Load().(*Encoder); ok {
+		return *enc
 	}
+
+	return defaultEncoder
 }
@@ -0,0 +1,433 @@
+package logs
+
+import (
+	"bufio"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 日志文件切分周期
+const (
+	ROTATE_DAILY  = "daily"
+	ROTATE_HOURLY = "hourly"
+)
+
+const (
+	DEFAULT_WRITE_BUF_SIZE = 32 * 1024
+	DEFAULT_RETRY_INTERVAL = 5 * time.Second
+)
+
+// _Rotation 日志文件切分与保留配置
+type _Rotation struct {
+	interval string // 按时间切分周期
+	maxSize  int64  // 单个文件最大字节数, 0为不限制
+	maxFiles int    // 最多保留的历史文件数, 0为不限制
+	maxAge   time.Duration
+	compress bool // gzip压缩历史文件
+	symlink  bool // 创建指向当前文件的软链接
+}
+
+type option func(*_Rotation)
+
+// SetRotateInterval 设置按时间切分的周期, ROTATE_DAILY或ROTATE_HOURLY
+func SetRotateInterval(interval string) option {
+	return func(r *_Rotation) {
+		r.interval = interval
+	}
+}
+
+// SetMaxSize 设置单个文件最大大小(MB), 超过后切分到新文件
+func SetMaxSize(mb int) option {
+	return func(r *_Rotation) {
+		r.maxSize = int64(mb) * 1024 * 1024
+	}
+}
+
+// SetMaxFiles 设置最多保留的历史文件数
+func SetMaxFiles(n int) option {
+	return func(r *_Rotation) {
+		r.maxFiles = n
+	}
+}
+
+// SetMaxAge 设置历史文件最长保留时间
+func SetMaxAge(d time.Duration) option {
+	return func(r *_Rotation) {
+		r.maxAge = d
+	}
+}
+
+// SetCompress 设置是否gzip压缩历史文件
+func SetCompress(b bool) option {
+	return func(r *_Rotation) {
+		r.compress = b
+	}
+}
+
+// SetSymlink 设置是否创建名为<file>.log并指向当前文件的软链接
+func SetSymlink(b bool) option {
+	return func(r *_Rotation) {
+		r.symlink = b
+	}
+}
+
+// SetRotation 设置Init创建的日志文件的切分与保留策略, 下一次写入时生效
+func SetRotation(opts ...option) {
+	log.rotation.Store(newRotation(opts...))
+}
+
+func newRotation(opts ...option) *_Rotation {
+	r := &_Rotation{interval: ROTATE_DAILY}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// NewFileSink 创建按时间与大小切分的日志文件输出, 文件名为<dir>/<file>-<周期>[.序号].log
+func NewFileSink(dir, file string, opts ...option) *FileSink {
+	r := newRotation(opts...)
+	return &FileSink{dir: dir, file: file, rotation: func() *_Rotation { return r }}
+}
+
+// FileSink 按时间与大小切分的日志文件, 仅由写协程访问
+type FileSink struct {
+	dir      string
+	file     string
+	rotation func() *_Rotation
+
+	fp     *os.File
+	w      *bufio.Writer
+	path   string
+	period string
+	index  int
+	size   int64
+
+	// 打开失败后的重试时间
+	retry time.Time
+
+	// 压缩与清理在后台串行执行
+	amu sync.Mutex
+	wg  sync.WaitGroup
+}
+
+func (l *mylog) getRotation() *_Rotation {
+	if r, ok := l.rotation.Load().(*_Rotation); ok {
+		return r
+	}
+
+	return &_Rotation{interval: ROTATE_DAILY}
+}
+
+// periodOf 获取时间所属的切分周期
+func (r *_Rotation) periodOf(t time.Time) string {
+	if r.interval == ROTATE_HOURLY {
+		return fmt.Sprintf("%04d-%02d-%02d-%02d", t.Year(), t.Month(), t.Day(), t.Hour())
+	}
+
+	return fmt.Sprintf("%04d-%02d-%02d", t.Year(), t.Month(), t.Day())
+}
+
+// Write 写入一行日志, 必要时切分文件; 文件不可用时输出到标准错误避免丢失
+func (f *FileSink) Write(level int, str string) error {
+	r := f.rotation()
+	now := time.Now()
+
+	period := r.periodOf(now)
+	if f.fp == nil || period != f.period || (r.maxSize > 0 && f.size+int64(len(str)) > r.maxSize && f.size > 0) {
+		f.rotate(r, now, period)
+	}
+
+	if f.w == nil {
+		os.Stderr.WriteString(str)
+		return nil
+	}
+
+	n, err := f.w.WriteString(str)
+	f.size += int64(n)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "logs: write %v err: %v\n", f.path, err)
+		f.closeFile()
+	}
+
+	return err
+}
+
+// Flush 将缓冲写入文件
+func (f *FileSink) Flush() error {
+	if f.w == nil {
+		return nil
+	}
+
+	err := f.w.Flush()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "logs: flush %v err: %v\n", f.path, err)
+	}
+
+	return err
+}
+
+// Close 刷新并同步到磁盘后关闭文件, 等待压缩完成
+func (f *FileSink) Close() error {
+	f.closeFile()
+	f.wg.Wait()
+
+	return nil
+}
+
+func (f *FileSink) closeFile() {
+	if f.fp == nil {
+		return
+	}
+
+	f.Flush()
+	f.fp.Sync()
+	f.fp.Close()
+
+	f.fp = nil
+	f.w = nil
+}
+
+// rotate 关闭当前文件并打开新文件, 跨周期时序号从0开始, 超过大小时序号递增
+func (f *FileSink) rotate(r *_Rotation, now time.Time, period string) {
+	if f.fp == nil && now.Before(f.retry) {
+		return
+	}
+
+	old := f.path
+	reopen := f.fp == nil
+	f.closeFile()
+
+	if err := os.MkdirAll(f.dir, os.ModePerm); err != nil {
+		f.fail(err)
+		return
+	}
+
+	index := 0
+	if period == f.period && !reopen {
+		index = f.index + 1
+	} else {
+		// 进程重启或重试时沿用本周期最新的文件
+		index = f.lastIndex(period)
+	}
+
+	for {
+		path := f.pathOf(period, index)
+		info, err := os.Stat(path)
+		if err == nil && r.maxSize > 0 && info.Size() >= r.maxSize {
+			index++
+			continue
+		}
+
+		fp, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, os.ModePerm)
+		if err != nil {
+			f.fail(err)
+			return
+		}
+
+		f.fp = fp
+		f.w = bufio.NewWriterSize(fp, DEFAULT_WRITE_BUF_SIZE)
+		f.path = path
+		f.period = period
+		f.index = index
+		f.size = 0
+		if info != nil {
+			f.size = info.Size()
+		}
+		break
+	}
+
+	if r.symlink {
+		f.link()
+	}
+
+	if old != f.path {
+		f.archive(r, old)
+	}
+}
+
+func (f *FileSink) fail(err error) {
+	fmt.Fprintf(os.Stderr, "logs: open log file err: %v\n", err)
+	f.retry = time.Now().Add(DEFAULT_RETRY_INTERVAL)
+}
+
+// pathOf 文件路径, 序号为0时与原有命名一致
+func (f *FileSink) pathOf(period string, index int) string {
+	if index == 0 {
+		return fmt.Sprintf("%s/%s-%s.log", f.dir, f.file, period)
+	}
+
+	return fmt.Sprintf("%s/%s-%s.%d.log", f.dir, f.file, period, index)
+}
+
+// lastIndex 获取周期内可继续写入的序号, 已压缩的序号不再复用
+func (f *FileSink) lastIndex(period string) int {
+	prefix := fmt.Sprintf("%s-%s.", f.file, period)
+
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return 0
+	}
+
+	last := 0
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+
+		next := 0
+		switch {
+		case strings.HasSuffix(name, ".log.gz"):
+			name, next = strings.TrimSuffix(name, ".log.gz"), 1
+		case strings.HasSuffix(name, ".log"):
+			name = strings.TrimSuffix(name, ".log")
+		default:
+			continue
+		}
+
+		// 序号0的文件名中不含序号
+		n := 0
+		if name != strings.TrimSuffix(prefix, ".") {
+			var err error
+			if n, err = strconv.Atoi(strings.TrimPrefix(name, prefix)); err != nil {
+				continue
+			}
+		}
+
+		if n+next > last {
+			last = n + next
+		}
+	}
+
+	return last
+}
+
+// link 更新指向当前文件的软链接
+func (f *FileSink) link() {
+	link := fmt.Sprintf("%s/%s.log", f.dir, f.file)
+	tmp := link + ".tmp"
+
+	os.Remove(tmp)
+	if err := os.Symlink(filepath.Base(f.path), tmp); err != nil {
+		fmt.Fprintf(os.Stderr, "logs: symlink err: %v\n", err)
+		return
+	}
+
+	if err := os.Rename(tmp, link); err != nil {
+		fmt.Fprintf(os.Stderr, "logs: symlink err: %v\n", err)
+		os.Remove(tmp)
+	}
+}
+
+// archive 在后台压缩已切分的文件并清理过期文件, path为空时仅清理
+func (f *FileSink) archive(r *_Rotation, path string) {
+	current := f.path
+
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+
+		f.amu.Lock()
+		defer f.amu.Unlock()
+
+		if r.compress && path != "" {
+			if err := compressFile(path); err != nil && !os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "logs: compress %v err: %v\n", path, err)
+			}
+		}
+
+		f.clean(r, current)
+	}()
+}
+
+// clean 按保留数量与保留时间删除历史文件, 不删除当前文件
+func (f *FileSink) clean(r *_Rotation, current string) {
+	if r.maxFiles <= 0 && r.maxAge <= 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(f.dir)
+	if err != nil {
+		return
+	}
+
+	type _File struct {
+		path string
+		mod  time.Time
+	}
+
+	prefix := f.file + "-"
+
+	var files []_File
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if !strings.HasSuffix(name, ".log") && !strings.HasSuffix(name, ".log.gz") {
+			continue
+		}
+
+		path := filepath.Join(f.dir, name)
+		if path == filepath.Clean(current) || e.Type()&os.ModeSymlink != 0 {
+			continue
+		}
+
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, _File{path: path, mod: info.ModTime()})
+	}
+
+	sort.Slice(files, func(i, j int) bool { return files[i].mod.After(files[j].mod) })
+
+	for i, file := range files {
+		if (r.maxFiles > 0 && i >= r.maxFiles) || (r.maxAge > 0 && time.Since(file.mod) > r.maxAge) {
+			if err := os.Remove(file.path); err != nil {
+				fmt.Fprintf(os.Stderr, "logs: remove %v err: %v\n", file.path, err)
+			}
+		}
+	}
+}
+
+// compressFile 压缩为.gz后删除原文件
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+	if _, err = io.Copy(zw, src); err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		err = dst.Sync()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+
+	return os.Remove(path)
+}
@@ -0,0 +1,374 @@
+package logs
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kkkkiven/fishpkg/metrics"
+)
+
+// 缓冲区满时的处理策略
+const (
+	OVERFLOW_DROP_NEWEST = "drop_newest" // 丢弃新日志
+	OVERFLOW_DROP_OLDEST = "drop_oldest" // 丢弃最早的日志
+	OVERFLOW_BLOCK       = "block"       // 阻塞调用方直到有空间
+)
+
+const (
+	DEFAULT_BUFFER_SIZE     = 8192
+	DEFAULT_SYSLOG_MAX_SIZE = 2048
+)
+
+// syslog facility
+const (
+	SYSLOG_USER   = 1
+	SYSLOG_LOCAL0 = 16
+)
+
+var metricDropped = metrics.NewCounterVec("logs_dropped_lines_total",
+	"Log lines dropped because the buffer was full.", "policy")
+
+// Sink 日志输出目标, 仅由写协程调用, 实现无需考虑并发
+type Sink interface {
+	// Write 写入一行已编码的日志, 包含换行符
+	Write(level int, line string) error
+
+	// Flush 刷新缓冲, 缓冲区中暂无待写日志时调用
+	Flush() error
+
+	// Close 关闭输出目标
+	Close() error
+}
+
+// _Sinks 当前生效的输出目标集合, 整体替换
+type _Sinks struct {
+	list []Sink
+}
+
+// SetSinks 替换全部输出目标, 被移除的输出目标由写协程刷新后关闭; 为空时恢复为同步输出到标准输出
+func SetSinks(sinks ...Sink) {
+	log.smu.Lock()
+	defer log.smu.Unlock()
+
+	if len(sinks) == 0 {
+		log.sinks.Store((*_Sinks)(nil))
+		return
+	}
+
+	log.sinks.Store(&_Sinks{list: append([]Sink(nil), sinks...)})
+}
+
+// AddSink 添加输出目标, 日志同时写入已有的输出目标
+func AddSink(sink Sink) {
+	log.smu.Lock()
+	defer log.smu.Unlock()
+
+	var list []Sink
+	if cur := log.getSinks(); cur != nil {
+		list = append(list, cur.list...)
+	}
+	list = append(list, sink)
+
+	log.sinks.Store(&_Sinks{list: list})
+}
+
+// SetBuffer 设置异步缓冲区大小及缓冲区满时的处理策略
+func SetBuffer(size int, policy string) {
+	log.buf.reset(size, policy)
+}
+
+// Dropped 获取因缓冲区满而丢弃的日志行数
+func Dropped() uint64 {
+	return atomic.LoadUint64(&log.buf.dropped)
+}
+
+func (l *mylog) getSinks() *_Sinks {
+	s, _ := l.sinks.Load().(*_Sinks)
+	return s
+}
+
+// _Line 待写入的日志行
+type _Line struct {
+	level int
+	str   string
+}
+
+// _Buffer 有界环形缓冲区, 调用方写入不阻塞(OVERFLOW_BLOCK除外)
+type _Buffer struct {
+	mu     sync.Mutex
+	ready  *sync.Cond // 有待写日志或已关闭
+	space  *sync.Cond // 有空闲位置
+	items  []_Line
+	head   int
+	n      int
+	policy string
+	closed bool
+
+	dropped uint64
+}
+
+func newBuffer(size int, policy string) *_Buffer {
+	b := &_Buffer{}
+	b.ready = sync.NewCond(&b.mu)
+	b.space = sync.NewCond(&b.mu)
+	b.reset(size, policy)
+
+	return b
+}
+
+// reset 调整容量与策略, 保留最新的日志
+func (b *_Buffer) reset(size int, policy string) {
+	if size <= 0 {
+		size = DEFAULT_BUFFER_SIZE
+	}
+	if policy == "" {
+		policy = OVERFLOW_DROP_NEWEST
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	items := make([]_Line, size)
+	n := b.n
+	if n > size {
+		b.drop(n - size)
+		n = size
+	}
+	for i := 0; i < n; i++ {
+		items[i] = b.items[(b.head+b.n-n+i)%len(b.items)]
+	}
+
+	b.items = items
+	b.head = 0
+	b.n = n
+	b.policy = policy
+
+	b.space.Broadcast()
+}
+
+func (b *_Buffer) drop(n int) {
+	atomic.AddUint64(&b.dropped, uint64(n))
+	metricDropped.WithLabelValues(b.policy).Add(float64(n))
+}
+
+// push 写入一行日志, 已关闭时返回false
+func (b *_Buffer) push(line _Line) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for !b.closed && b.n == len(b.items) && b.policy == OVERFLOW_BLOCK {
+		b.space.Wait()
+	}
+
+	if b.closed {
+		return false
+	}
+
+	if b.n == len(b.items) {
+		if b.policy != OVERFLOW_DROP_OLDEST {
+			b.drop(1)
+			return true
+		}
+
+		b.items[b.head] = _Line{}
+		b.head = (b.head + 1) % len(b.items)
+		b.n--
+		b.drop(1)
+	}
+
+	b.items[(b.head+b.n)%len(b.items)] = line
+	b.n++
+	b.ready.Signal()
+
+	return true
+}
+
+// wait 等待并取出全部待写日志, 关闭且无待写日志时返回closed
+func (b *_Buffer) wait(dst []_Line) (lines []_Line, closed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for b.n == 0 && !b.closed {
+		b.ready.Wait()
+	}
+
+	for ; b.n > 0; b.n-- {
+		dst = append(dst, b.items[b.head])
+		b.items[b.head] = _Line{}
+		b.head = (b.head + 1) % len(b.items)
+	}
+	b.space.Broadcast()
+
+	return dst, b.closed
+}
+
+func (b *_Buffer) close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	b.ready.Broadcast()
+	b.space.Broadcast()
+}
+
+// run 写协程, 将缓冲区中的日志写入全部输出目标
+func (l *mylog) run() {
+	var (
+		cur   *_Sinks
+		lines []_Line
+	)
+
+	for {
+		var closed bool
+		lines, closed = l.buf.wait(lines[:0])
+
+		sinks := l.getSinks()
+		if sinks != cur {
+			closeRemoved(cur, sinks)
+			cur = sinks
+		}
+
+		for _, line := range lines {
+			if cur == nil {
+				fmt.Print(line.str)
+				continue
+			}
+
+			for _, s := range cur.list {
+				s.Write(line.level, line.str)
+			}
+		}
+
+		if cur != nil {
+			for _, s := range cur.list {
+				s.Flush()
+			}
+		}
+
+		if closed {
+			closeRemoved(cur, nil)
+			close(l.done)
+			return
+		}
+	}
+}
+
+// closeRemoved 关闭old中不在cur里的输出目标
+func closeRemoved(old, cur *_Sinks) {
+	if old == nil {
+		return
+	}
+
+	for _, s := range old.list {
+		kept := false
+		if cur != nil {
+			for _, c := range cur.list {
+				if c == s {
+					kept = true
+					break
+				}
+			}
+		}
+
+		if !kept {
+			s.Flush()
+			s.Close()
+		}
+	}
+}
+
+// Close 停止写协程, 写入缓冲区中剩余的日志, 刷新并关闭全部输出目标, 之后的日志直接输出到标准输出
+func Close() {
+	log.close()
+}
+
+func (l *mylog) close() {
+	if !atomic.CompareAndSwapInt32(&l.closed, 0, 1) {
+		return
+	}
+
+	l.buf.close()
+	<-l.done
+}
+
+// StdoutSink 标准输出
+type StdoutSink struct{}
+
+// NewStdoutSink 创建标准输出
+func NewStdoutSink() *StdoutSink {
+	return &StdoutSink{}
+}
+
+func (s *StdoutSink) Write(level int, line string) error {
+	_, err := os.Stdout.WriteString(line)
+	return err
+}
+
+func (s *StdoutSink) Flush() error {
+	return nil
+}
+
+func (s *StdoutSink) Close() error {
+	return nil
+}
+
+// SyslogSink 通过UDP以RFC 3164格式发送到syslog
+type SyslogSink struct {
+	conn     net.Conn
+	tag      string
+	host     string
+	facility int
+}
+
+// NewSyslogSink 创建syslog输出, addr为syslog服务的UDP地址, facility如SYSLOG_LOCAL0
+func NewSyslogSink(addr, tag string, facility int) (*SyslogSink, error) {
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	host, _ := os.Hostname()
+	if host == "" {
+		host = "-"
+	}
+
+	return &SyslogSink{conn: conn, tag: tag, host: host, facility: facility}, nil
+}
+
+func (s *SyslogSink) Write(level int, line string) error {
+	msg := fmt.Sprintf("<%d>%s %s %s[%d]: %s", s.facility*8+syslogSeverity(level),
+		time.Now().Format(time.Stamp), s.host, s.tag, os.Getpid(), strings.TrimRight(line, "\n"))
+	if len(msg) > DEFAULT_SYSLOG_MAX_SIZE {
+		msg = msg[:DEFAULT_SYSLOG_MAX_SIZE]
+	}
+
+	_, err := s.conn.Write([]byte(msg))
+	return err
+}
+
+func (s *SyslogSink) Flush() error {
+	return nil
+}
+
+func (s *SyslogSink) Close() error {
+	return s.conn.Close()
+}
+
+// syslogSeverity 日志级别对应的syslog severity
+func syslogSeverity(level int) int {
+	switch level {
+	case LOG_ERROR:
+		return 3
+	case LOG_WARING:
+		return 4
+	case LOG_INFO:
+		return 6
+	}
+
+	return 7
+}
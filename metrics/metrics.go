@@ -0,0 +1,382 @@
+// 指标
+package metrics
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// 指标类型
+const (
+	TYPE_COUNTER   = "counter"
+	TYPE_GAUGE     = "gauge"
+	TYPE_HISTOGRAM = "histogram"
+)
+
+// DEFAULT_BUCKETS 默认耗时分桶(秒)
+var DEFAULT_BUCKETS = []float64{.001, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Collector 指标集合, 由Registry统一输出
+type Collector interface {
+	// Name 指标名
+	Name() string
+
+	// Write 以Prometheus文本格式输出
+	Write(b *strings.Builder)
+}
+
+// _Float 原子浮点数
+type _Float struct {
+	bits uint64
+}
+
+func (f *_Float) Add(v float64) {
+	for {
+		old := atomic.LoadUint64(&f.bits)
+		nv := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(&f.bits, old, nv) {
+			return
+		}
+	}
+}
+
+func (f *_Float) Set(v float64) {
+	atomic.StoreUint64(&f.bits, math.Float64bits(v))
+}
+
+func (f *_Float) Get() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&f.bits))
+}
+
+// _Vec 按标签值分组的指标集合
+type _Vec struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+
+	mu     sync.RWMutex
+	series map[string]interface{}
+	values map[string][]string
+	create func() interface{}
+}
+
+func newVec(name, help, typ string, labels []string, create func() interface{}) *_Vec {
+	return &_Vec{
+		name:   name,
+		help:   help,
+		typ:    typ,
+		labels: labels,
+		series: make(map[string]interface{}, 0),
+		values: make(map[string][]string, 0),
+		create: create,
+	}
+}
+
+func (v *_Vec) Name() string {
+	return v.name
+}
+
+// get 获取标签值对应的指标, 不存在时创建
+func (v *_Vec) get(values []string) interface{} {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics %v: expect %v label values, got %v", v.name, len(v.labels), len(values)))
+	}
+
+	key := strings.Join(values, "\xff")
+
+	v.mu.RLock()
+	m, ok := v.series[key]
+	v.mu.RUnlock()
+	if ok {
+		return m
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if m, ok = v.series[key]; ok {
+		return m
+	}
+
+	m = v.create()
+	v.series[key] = m
+	v.values[key] = append([]string(nil), values...)
+
+	return m
+}
+
+// each 按标签值排序遍历
+func (v *_Vec) each(fn func(labels string, m interface{})) {
+	v.mu.RLock()
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	type pair struct {
+		labels string
+		m      interface{}
+	}
+	list := make([]pair, 0, len(keys))
+	for _, k := range keys {
+		list = append(list, pair{formatLabels(v.labels, v.values[k]), v.series[k]})
+	}
+	v.mu.RUnlock()
+
+	for _, p := range list {
+		fn(p.labels, p.m)
+	}
+}
+
+func (v *_Vec) writeHeader(b *strings.Builder) {
+	fmt.Fprintf(b, "# HELP %s %s\n", v.name, escapeHelp(v.help))
+	fmt.Fprintf(b, "# TYPE %s %s\n", v.name, v.typ)
+}
+
+// Counter 单调递增计数器
+type Counter struct {
+	v _Float
+}
+
+func (c *Counter) Inc() {
+	c.v.Add(1)
+}
+
+// Add 增加计数, 负数被忽略
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		return
+	}
+
+	c.v.Add(v)
+}
+
+func (c *Counter) Get() float64 {
+	return c.v.Get()
+}
+
+// CounterVec 带标签的计数器
+type CounterVec struct {
+	*_Vec
+}
+
+// NewCounterVec 创建计数器并注册到默认Registry
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{newVec(name, help, TYPE_COUNTER, labels, func() interface{} { return &Counter{} })}
+	MustRegister(c)
+	return c
+}
+
+// WithLabelValues 获取标签值对应的计数器
+func (c *CounterVec) WithLabelValues(values ...string) *Counter {
+	return c.get(values).(*Counter)
+}
+
+func (c *CounterVec) Write(b *strings.Builder) {
+	c.writeHeader(b)
+	c.each(func(labels string, m interface{}) {
+		fmt.Fprintf(b, "%s%s %s\n", c.name, labels, formatFloat(m.(*Counter).Get()))
+	})
+}
+
+// Gauge 可增减的瞬时值
+type Gauge struct {
+	v _Float
+}
+
+func (g *Gauge) Set(v float64) {
+	g.v.Set(v)
+}
+
+func (g *Gauge) Add(v float64) {
+	g.v.Add(v)
+}
+
+func (g *Gauge) Inc() {
+	g.v.Add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.v.Add(-1)
+}
+
+func (g *Gauge) Get() float64 {
+	return g.v.Get()
+}
+
+// GaugeVec 带标签的瞬时值
+type GaugeVec struct {
+	*_Vec
+}
+
+// NewGaugeVec 创建瞬时值并注册到默认Registry
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	g := &GaugeVec{newVec(name, help, TYPE_GAUGE, labels, func() interface{} { return &Gauge{} })}
+	MustRegister(g)
+	return g
+}
+
+// WithLabelValues 获取标签值对应的瞬时值
+func (g *GaugeVec) WithLabelValues(values ...string) *Gauge {
+	return g.get(values).(*Gauge)
+}
+
+func (g *GaugeVec) Write(b *strings.Builder) {
+	g.writeHeader(b)
+	g.each(func(labels string, m interface{}) {
+		fmt.Fprintf(b, "%s%s %s\n", g.name, labels, formatFloat(m.(*Gauge).Get()))
+	})
+}
+
+// GaugeFunc 采集时计算的瞬时值, fn返回标签值到数值的映射
+type GaugeFunc struct {
+	name   string
+	help   string
+	labels []string
+	fn     func() map[string]float64
+}
+
+// NewGaugeFunc 创建采集时计算的瞬时值并注册到默认Registry, 仅支持一个标签
+func NewGaugeFunc(name, help, label string, fn func() map[string]float64) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, labels: []string{label}, fn: fn}
+	MustRegister(g)
+	return g
+}
+
+func (g *GaugeFunc) Name() string {
+	return g.name
+}
+
+func (g *GaugeFunc) Write(b *strings.Builder) {
+	fmt.Fprintf(b, "# HELP %s %s\n", g.name, escapeHelp(g.help))
+	fmt.Fprintf(b, "# TYPE %s %s\n", g.name, TYPE_GAUGE)
+
+	values := g.fn()
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		fmt.Fprintf(b, "%s%s %s\n", g.name, formatLabels(g.labels, []string{k}), formatFloat(values[k]))
+	}
+}
+
+// Histogram 分桶统计
+type Histogram struct {
+	buckets []float64
+	counts  []uint64
+	count   uint64
+	sum     _Float
+}
+
+// Observe 记录一次观测值
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+	if i < len(h.counts) {
+		atomic.AddUint64(&h.counts[i], 1)
+	}
+
+	atomic.AddUint64(&h.count, 1)
+	h.sum.Add(v)
+}
+
+// HistogramVec 带标签的分桶统计
+type HistogramVec struct {
+	*_Vec
+	buckets []float64
+}
+
+// NewHistogramVec 创建分桶统计并注册到默认Registry, buckets为空时使用DEFAULT_BUCKETS
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DEFAULT_BUCKETS
+	}
+
+	bs := append([]float64(nil), buckets...)
+	sort.Float64s(bs)
+
+	h := &HistogramVec{buckets: bs}
+	h._Vec = newVec(name, help, TYPE_HISTOGRAM, labels, func() interface{} {
+		return &Histogram{buckets: bs, counts: make([]uint64, len(bs))}
+	})
+	MustRegister(h)
+	return h
+}
+
+// WithLabelValues 获取标签值对应的分桶统计
+func (h *HistogramVec) WithLabelValues(values ...string) *Histogram {
+	return h.get(values).(*Histogram)
+}
+
+func (h *HistogramVec) Write(b *strings.Builder) {
+	h.writeHeader(b)
+	h.each(func(labels string, m interface{}) {
+		hist := m.(*Histogram)
+
+		var acc uint64
+		for i, le := range hist.buckets {
+			acc += atomic.LoadUint64(&hist.counts[i])
+			fmt.Fprintf(b, "%s_bucket%s %d\n", h.name, appendLabel(labels, "le", formatFloat(le)), acc)
+		}
+
+		count := atomic.LoadUint64(&hist.count)
+		fmt.Fprintf(b, "%s_bucket%s %d\n", h.name, appendLabel(labels, "le", "+Inf"), count)
+		fmt.Fprintf(b, "%s_sum%s %s\n", h.name, labels, formatFloat(hist.sum.Get()))
+		fmt.Fprintf(b, "%s_count%s %d\n", h.name, labels, count)
+	})
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+
+	return fmt.Sprintf("%v", v)
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, 0, len(names))
+	for i, n := range names {
+		pairs = append(pairs, fmt.Sprintf("%s=\"%s\"", n, escapeLabel(values[i])))
+	}
+
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+// appendLabel 在已格式化的标签后追加一个标签
+func appendLabel(labels, name, value string) string {
+	pair := fmt.Sprintf("%s=\"%s\"", name, value)
+	if labels == "" {
+		return "{" + pair + "}"
+	}
+
+	return labels[:len(labels)-1] + "," + pair + "}"
+}
+
+func escapeLabel(s string) string {
+	s = strings.Replace(s, "\\", "\\\\", -1)
+	s = strings.Replace(s, "\"", "\\\"", -1)
+	return strings.Replace(s, "\n", "\\n", -1)
+}
+
+func escapeHelp(s string) string {
+	s = strings.Replace(s, "\\", "\\\\", -1)
+	return strings.Replace(s, "\n", "\\n", -1)
+}
@@ -0,0 +1,105 @@
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// DEFAULT_PATH 指标接口路径
+const DEFAULT_PATH = "/metrics"
+
+// Registry 指标注册表
+type Registry struct {
+	mu         sync.RWMutex
+	collectors map[string]Collector
+}
+
+var defaultRegistry = NewRegistry()
+
+// NewRegistry 创建指标注册表
+func NewRegistry() *Registry {
+	return &Registry{collectors: make(map[string]Collector, 0)}
+}
+
+// Default 获取默认注册表
+func Default() *Registry {
+	return defaultRegistry
+}
+
+// Register 注册指标, 指标名重复时返回错误
+func (r *Registry) Register(c Collector) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.collectors[c.Name()]; ok {
+		return fmt.Errorf("metrics %v already registered", c.Name())
+	}
+
+	r.collectors[c.Name()] = c
+	return nil
+}
+
+// Unregister 注销指标
+func (r *Registry) Unregister(name string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.collectors, name)
+}
+
+// Gather 以Prometheus文本格式输出全部指标
+func (r *Registry) Gather() string {
+	r.mu.RLock()
+	names := make([]string, 0, len(r.collectors))
+	for name := range r.collectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	list := make([]Collector, 0, len(names))
+	for _, name := range names {
+		list = append(list, r.collectors[name])
+	}
+	r.mu.RUnlock()
+
+	var b strings.Builder
+	for _, c := range list {
+		c.Write(&b)
+	}
+
+	return b.String()
+}
+
+// ServeHTTP 输出指标
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write([]byte(r.Gather()))
+}
+
+// Register 注册指标到默认注册表
+func Register(c Collector) error {
+	return defaultRegistry.Register(c)
+}
+
+// MustRegister 注册指标到默认注册表, 指标名重复时panic
+func MustRegister(c Collector) {
+	if err := defaultRegistry.Register(c); err != nil {
+		panic(err)
+	}
+}
+
+// Handler 获取默认注册表的/metrics处理函数
+func Handler() http.Handler {
+	return defaultRegistry
+}
+
+// ListenAndServe 在addr上提供/metrics接口, 阻塞直到出错
+func ListenAndServe(addr string) error {
+	mux := http.NewServeMux()
+	mux.Handle(DEFAULT_PATH, Handler())
+
+	return http.ListenAndServe(addr, mux)
+}
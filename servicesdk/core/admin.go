@@ -0,0 +1,92 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kkkkiven/fishpkg/servicesdk/pkg/admin"
+	"github.com/kkkkiven/fishpkg/servicesdk/pkg/discovery"
+)
+
+// 管理接口路径
+const (
+	PATH_DEBUG_HANDLERS = "/debug/handlers"
+	PATH_DEBUG_GATEWAYS = "/debug/gateways"
+)
+
+// 就绪检查项
+const (
+	CHECK_GATEWAY   = "gateway"
+	CHECK_DISCOVERY = "discovery"
+	CHECK_KAFKA     = "kafka"
+)
+
+// Admin 获取默认服务的管理端口
+func Admin() *admin.Server {
+	return srv.Admin()
+}
+
+// Admin 获取管理端口, 可在Start前添加自定义检查项与接口
+func (s *Service) Admin() *admin.Server {
+	return s.admin
+}
+
+// newAdmin 创建管理端口并注册服务相关的检查项与调试接口
+func newAdmin(s *Service) *admin.Server {
+	a := admin.NewServer("")
+
+	a.AddCheck(CHECK_GATEWAY, func() error {
+		if s.gwList.GetReadyCount() == 0 {
+			return errors.New("no gateway is ready")
+		}
+		return nil
+	})
+
+	a.AddCheck(CHECK_DISCOVERY, func() error {
+		d := s.Discovery()
+		if d == nil {
+			return errors.New("discovery not initialized")
+		}
+		if c, ok := d.(discovery.Checker); ok {
+			return c.Check()
+		}
+		return nil
+	})
+
+	a.AddCheck(CHECK_KAFKA, func() error {
+		return s.KafkaProducer().Check()
+	})
+
+	a.HandleJSON(PATH_DEBUG_HANDLERS, func() interface{} {
+		ids := s.Router().GetHandlerIDs()
+
+		handlers := make([]map[string]interface{}, 0, len(ids))
+		for _, id := range ids {
+			handlers = append(handlers, map[string]interface{}{
+				"id":   id,
+				"name": s.Router().GetHandlerName(id),
+			})
+		}
+
+		return handlers
+	})
+
+	a.HandleJSON(PATH_DEBUG_GATEWAYS, func() interface{} {
+		return s.gwList.Status()
+	})
+
+	return a
+}
+
+// startAdmin 开启管理端口, 未配置监听地址时跳过
+func (s *Service) startAdmin() error {
+	if s.admin.Addr() == "" {
+		return nil
+	}
+
+	if err := s.admin.Start(); err != nil {
+		return fmt.Errorf("start admin server err: %v", err.Error())
+	}
+
+	return nil
+}
@@ -413,12 +413,18 @@ type ISdk interface {
 }
 
 type SdkClient struct {
-	so *pCore.Socket
+	so  *pCore.Socket
+	srv *Service
 }
 
 func Client() ISdk {
-	so := gwList.Roll()
-	return &SdkClient{so}
+	return srv.Client()
+}
+
+// Client 获取通过本服务网关发送请求的客户端
+func (s *Service) Client() ISdk {
+	so := s.gwList.Roll()
+	return &SdkClient{so, s}
 }
 
 // GetUserAttr 获取用户基础属性
@@ -448,7 +454,7 @@ func (c *SdkClient) GetUserAttr(tc context.Context, uid int64, attrKeys []string
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
 
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -496,7 +502,7 @@ func (c *SdkClient) GetUsersAttr(tc context.Context, id int64, uids []int64, att
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
 
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -551,7 +557,7 @@ func (c *SdkClient) GetUsersAttrAndGameAttr(tc context.Context, id int64, uids [
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
 
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, nil, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -599,7 +605,7 @@ func (c *SdkClient) SetUserAttr(tc context.Context, uid int64, appid, channelid
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -638,7 +644,7 @@ func (c *SdkClient) GetUserProp(tc context.Context, uid int64, propKeys []int32,
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -690,7 +696,7 @@ func (c *SdkClient) GetBatchUserInfoAndProp(tc context.Context, uid int64, uids
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, nil, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -754,7 +760,7 @@ func (c *SdkClient) GetBatchUserInfosAndProp(tc context.Context, uid int64, uids
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -806,7 +812,7 @@ func (c *SdkClient) SetUserProp(tc context.Context, uid int64, appid, channelid
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
 
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -854,7 +860,7 @@ func (c *SdkClient) GetUserInfo(tc context.Context, uid int64, attrKeys []string
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, nil, nil, "", fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -899,7 +905,7 @@ func (c *SdkClient) SetUserInfo(tc context.Context, uid int64, appid, channelid
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -948,7 +954,7 @@ func (c *SdkClient) OpProp(tc context.Context, uid int64, appid, channelid int32
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -992,7 +998,7 @@ func (c *SdkClient) SetAll(tc context.Context, uid int64, appid, channelid int32
 
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -1033,7 +1039,7 @@ func (c *SdkClient) GetUserGameInfo(tc context.Context, uid int64, gamename stri
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return "", fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -1077,7 +1083,7 @@ func (c *SdkClient) SetUserGameInfo(tc context.Context, uid int64, appid, channe
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -1125,7 +1131,7 @@ func (c *SdkClient) OpUserGameInfo(tc context.Context, uid int64, appid, channel
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -1165,7 +1171,7 @@ func (c *SdkClient) GetUserAProp(tc context.Context, uid int64, aPropKeys []int3
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -1209,7 +1215,7 @@ func (c *SdkClient) SetUserAProp(tc context.Context, uid int64, appid, channelid
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -1256,7 +1262,7 @@ func (c *SdkClient) OpUserAProp(tc context.Context, uid int64, appid, channelid
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -1302,7 +1308,7 @@ func (c *SdkClient) OpPropAndAdProp(tc context.Context, uid int64, appid, channe
 
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, nil, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -1359,7 +1365,7 @@ func (c *SdkClient) EnterGame(tc context.Context, uid int64, gameid string, game
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, nil, nil, "", "", fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -1394,7 +1400,7 @@ func (c *SdkClient) LeaveGame(tc context.Context, uid int64, ext ...map[string][
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -1429,7 +1435,7 @@ func (c *SdkClient) IsUsernameReg(tc context.Context, username string, ext ...ma
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return false, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -1468,7 +1474,7 @@ func (c *SdkClient) SetPassword(tc context.Context, uid int64, pwd string, ext .
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -1510,7 +1516,7 @@ func (c *SdkClient) UpdatePassword(tc context.Context, uid int64, oldPwd, newPwd
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -1543,7 +1549,7 @@ func (c *SdkClient) HasBadWord(tc context.Context, str string, ext ...map[string
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -1576,7 +1582,7 @@ func (c *SdkClient) ReplaceBadWord(tc context.Context, str string, ext ...map[st
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return "", fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -1611,7 +1617,7 @@ func (c *SdkClient) CheckIdCardExisted(tc context.Context, idcard string, ext ..
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return false, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -1646,7 +1652,7 @@ func (c *SdkClient) GetUsersByPhoneNumber(tc context.Context, phone string, ext
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -1688,7 +1694,7 @@ func (c *SdkClient) AddDeputyAccount(tc context.Context, userId int64, username,
 
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -1730,7 +1736,7 @@ func (c *SdkClient) DelDeputyAccount(tc context.Context, userId int64, username
 
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return false, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -1771,7 +1777,7 @@ func (c *SdkClient) GetDeputyAccounts(tc context.Context, userId int64, userfrom
 
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -1808,7 +1814,7 @@ func (c *SdkClient) IsNicknameReg(tc context.Context, nickname string, ext ...ma
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return false, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -1848,7 +1854,7 @@ func (c *SdkClient) GetUserAPropEx(tc context.Context, uid int64, adPropsEx []in
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -1911,7 +1917,7 @@ func (c *SdkClient) GetAllEx(tc context.Context,
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, nil, nil, nil, "", fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -1967,7 +1973,7 @@ func (c *SdkClient) SetUserAPropEx(tc context.Context, uid int64, appid, channel
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -2034,7 +2040,7 @@ func (c *SdkClient) SetAllEx(tc context.Context,
 
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -2085,7 +2091,7 @@ func (c *SdkClient) SendCaptcha(tc context.Context, uid int64, phone, purpose st
 	}
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return 0, 0, "", fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -2141,7 +2147,7 @@ func (c *SdkClient) VerifyCaptcha(tc context.Context, uid int64, phone, token, c
 
 	mDataBuf, _ := proto.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		// logs.Errorf("fail to verifySMS,req : %+v,err:%v", req, err)
 		return fmt.Errorf("fail to verifySMS, : err:%v", err)
@@ -2191,7 +2197,7 @@ func (c *SdkClient) SendMail(tc context.Context, uids []int64, title, body, send
 	mData.Type = mailType
 	mDataBuf, _ := json.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -2219,7 +2225,7 @@ func (c *SdkClient) SendBackMail(tc context.Context, content []byte) error {
 	m.SetToSvrType(ST_MAIL)
 	m.SetFunctionID(F_ID_MAIL_BACK_SEND)
 	m.SetBody(content)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -2255,7 +2261,7 @@ func (c *SdkClient) MailAwardList(tc context.Context, uid, mailid int64) ([]int6
 	mData.Mailid = mailid
 	mDataBuf, _ := json.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, nil, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -2291,7 +2297,7 @@ func (c *SdkClient) MailAllDetail(tc context.Context, uid, mailid int64) ([]int6
 	mData.Mailid = mailid
 	mDataBuf, _ := json.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, nil, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -2328,7 +2334,7 @@ func (c *SdkClient) MailEvaluation(tc context.Context, uid int64, mailid int64,
 	mData.Pleased = pleased
 	mDataBuf, _ := json.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -2364,7 +2370,7 @@ func (c *SdkClient) MailUpdateStatus(tc context.Context, uid int64, mailids []in
 	mData.MailsIds = mailids
 	mDataBuf, _ := json.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -2400,7 +2406,7 @@ func (c *SdkClient) MailBatchRead(tc context.Context, uid int64, mailids []int64
 	mData.MailsIds = mailids
 	mDataBuf, _ := json.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -2436,7 +2442,7 @@ func (c *SdkClient) MailBatchAward(tc context.Context, uid int64, mailids []int6
 	mData.MailsIds = mailids
 	mDataBuf, _ := json.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -2481,7 +2487,7 @@ func (c *SdkClient) SendMailV2(tc context.Context, uids []int64, title, body, se
 	mData.Type = mailType
 	mDataBuf, _ := json.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -2517,7 +2523,7 @@ func (c *SdkClient) SendAllUserMail(tc context.Context, title, body, sender stri
 	mData.Type = mailType
 	mDataBuf, _ := json.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -2552,7 +2558,7 @@ func (c *SdkClient) MailBatchDel(tc context.Context, uid int64, mailids []int64)
 	mData.MailsIds = mailids
 	mDataBuf, _ := json.Marshal(mData)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -2600,7 +2606,7 @@ func (c *SdkClient) KickOff(tc context.Context, uid int64, gid uint32, comment s
 	buf, _ := proto.Marshal(mData)
 	m.SetBody(buf)
 
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -2638,7 +2644,7 @@ func (c *SdkClient) GiveUp(tc context.Context, uid int64, gid uint32, comment st
 	buf, _ := proto.Marshal(mData)
 	m.SetBody(buf)
 
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -2678,7 +2684,7 @@ func (c *SdkClient) PushMatchScore(tc context.Context, playID int64, matchType i
 	body, _ := utils.EncodeJson(&data)
 
 	req.SetBody(body)
-	_, err := c.so.Send(tc, req)
+	_, err := c.send(tc, req)
 	return err
 }
 
@@ -2713,7 +2719,7 @@ func (c *SdkClient) GetRankList(tc context.Context, raceType, front, isView int,
 	body, _ := utils.EncodeJson(&data)
 
 	m.SetBody(body)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, user, "", fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -2747,7 +2753,7 @@ func (c *SdkClient) GetRankListByKey(tc context.Context, key string, size int) (
 	body, _ := utils.EncodeJson(&data)
 
 	req.SetBody(body)
-	mResp, err := c.so.Send(tc, req)
+	mResp, err := c.send(tc, req)
 	if err != nil {
 		return nil, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -2781,7 +2787,7 @@ func (c *SdkClient) GetRoomCardIDs(tc context.Context, roomid, serverid int64, n
 	data.Num = num
 	body, _ := json.Marshal(data)
 	req.SetBody(body)
-	mResp, err := c.so.Send(tc, req)
+	mResp, err := c.send(tc, req)
 
 	if err != nil {
 		return nil, fmt.Errorf("send msg err:%v", err.Error())
@@ -2821,7 +2827,7 @@ func (c *SdkClient) ReleaseRoomCardIDs(tc context.Context, roomid, serverid int6
 	data.IDs = ids
 	body, _ := json.Marshal(data)
 	req.SetBody(body)
-	mResp, err := c.so.Send(tc, req)
+	mResp, err := c.send(tc, req)
 
 	if err != nil {
 		return fmt.Errorf("send msg err:%v", err.Error())
@@ -2857,7 +2863,7 @@ func (c *SdkClient) PayOrderCheck(tc context.Context, content []byte, svrType ui
 	}
 	m.SetToSvrType(svrType)
 	m.SetBody(content)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -2895,7 +2901,7 @@ func (c *SdkClient) PayCallBack(tc context.Context, orderid, transactionid strin
 	data := &jsonmodel.CallBackPay{OrderId: orderid, TransactionID: transactionid}
 	mDataBuf, _ := json.Marshal(data)
 	m.SetBody(mDataBuf)
-	mResp, err := c.so.Send(tc, m)
+	mResp, err := c.send(tc, m)
 	if err != nil {
 		return nil, fmt.Errorf("send msg err:%v", err.Error())
 	}
@@ -2915,7 +2921,7 @@ func (c *SdkClient) PayCallBack(tc context.Context, orderid, transactionid strin
 // msg 发送的消息
 // key 取key[0]进行哈希，确定消息的分区
 func (c *SdkClient) SendKFKMessage(topic string, msg []byte, key ...string) error {
-	return srv.KafkaProducer().SendMessage(topic, msg, key...)
+	return c.srv.KafkaProducer().SendMessage(topic, msg, key...)
 }
 
 /*
@@ -2952,7 +2958,7 @@ func (s *SdkClient) GetCommonRankList(c context.Context, gameName string, raceTy
 	m.SetBody(mDataBuf)
 
 	// 调用rank服获取排行信息
-	mResp, err := s.so.Send(c, m)
+	mResp, err := s.send(c, m)
 	if err != nil {
 		// logs.Errorf("get commonRankList sdkClient send to rank is failed,args:%+v,err:%v", args, err)
 		return nil, err
@@ -2995,7 +3001,7 @@ func (s *SdkClient) SetCommonUserRank(c context.Context, args *jsonmodel.ReqSetC
 	// 调用rank设置排行信息
 	mDataBuf, _ := json.Marshal(args)
 	m.SetBody(mDataBuf)
-	mResp, err := s.so.Send(c, m)
+	mResp, err := s.send(c, m)
 	if err != nil {
 		// logs.Errorf("SetCommonUserRank sdkClient send to rank is failed,args:%+v,err:%v", args, err)
 		return err
@@ -3045,7 +3051,7 @@ func (s *SdkClient) ClearCommonRankList(c context.Context, gameName string, race
 	m.SetBody(mDataBuf)
 
 	// 调用rank服获取排行信息
-	mResp, err := s.so.Send(c, m)
+	mResp, err := s.send(c, m)
 	if err != nil {
 		// logs.Errorf("ClearCommonRankList sdkClient send to rank is failed,args:%+v,err:%v", args, err)
 		return err
@@ -3099,7 +3105,7 @@ func (s *SdkClient) GetSpecifiedRankList(c context.Context, gameName string,
 	m.SetBody(mDataBuf)
 
 	// 调用rank服获取排行信息
-	mResp, err := s.so.Send(c, m)
+	mResp, err := s.send(c, m)
 	if err != nil {
 		logs.Errorf("GetSpecifiedRankList sdkClient send to rank is failed,args:%+v,err:%v", args, err)
 		return nil, err
@@ -3110,7 +3116,7 @@ func (s *SdkClient) GetSpecifiedRankList(c context.Context, gameName string,
 	mRespData := &jsonmodel.RspSpecifiedRankData{}
 	err = json.Unmarshal(mResp.GetBody(), mRespData)
 	if err != nil || mRespData.Code != 0 {
-		logs.Errorf("GetSpecifiedRankList Unmarshal rsp body is failed,args:%+v,code,err:%v", args, mRespData.Code, err)
+		logs.Errorf("GetSpecifiedRankList Unmarshal rsp body is failed,args:%+v,code:%v,err:%v", args, mRespData.Code, err)
 		return nil, err
 	}
 
@@ -2,7 +2,6 @@ package core
 
 import (
 	"context"
-	"errors"
 	"math/rand"
 	"net"
 	"strings"
@@ -24,7 +23,8 @@ const (
 
 type _GWList struct {
 	sync.RWMutex
-	m map[string]*_GWContext
+	m   map[string]*_GWContext
+	srv *Service
 }
 
 type _GWContext struct {
@@ -33,10 +33,13 @@ type _GWContext struct {
 	cancelFn context.CancelFunc
 }
 
-var gwList *_GWList = &_GWList{m: make(map[string]*_GWContext, 0)}
+func newGWList(s *Service) *_GWList {
+	return &_GWList{m: make(map[string]*_GWContext, 0), srv: s}
+}
 
+// GetGatewayList 获取默认服务的网关连接池
 func GetGatewayList() *_GWList {
-	return gwList
+	return srv.gwList
 }
 
 func (this *_GWList) GetReadyCount() int {
@@ -67,9 +70,22 @@ func (this *_GWList) GetAll() []*p.Socket {
 	return conns
 }
 
+// Status 获取各网关地址的连接状态
+func (this *_GWList) Status() map[string]string {
+	this.RLock()
+	defer this.RUnlock()
+
+	status := make(map[string]string, len(this.m))
+	for key, gw := range this.m {
+		status[key] = gwStatusName(gw.status)
+	}
+
+	return status
+}
+
 func (this *_GWList) Del(key string) {
 	this.Lock()
-	this.Unlock()
+	defer this.Unlock()
 
 	gw, ok := this.m[key]
 	if !ok {
@@ -86,6 +102,7 @@ func (this *_GWList) Del(key string) {
 	}
 
 	delete(this.m, key)
+	this.report()
 }
 
 func (this *_GWList) Add(key string) {
@@ -110,6 +127,7 @@ func (this *_GWList) Add(key string) {
 	ctx, fn := context.WithCancel(context.TODO())
 	gw.cancelFn = fn
 	gw.status = _GW_STATUS_PENDING
+	this.report()
 
 	go this.dial(ctx, key)
 }
@@ -155,19 +173,22 @@ func (this *_GWList) dial(ctx context.Context, key string) {
 
 			so := p.NewSocket(cli,
 				p.SetFilter(&Filter{}),
-				p.SetNotify(&Notify{}),
-				p.SetTimeout(srv.Timeout()),
-				p.SetEnablePack(srv.Pack()))
+				p.SetNotify(&Notify{srv: this.srv}),
+				p.SetTimeout(this.srv.Timeout()),
+				p.SetEnablePack(this.srv.Pack()),
+				p.SetRouter(this.srv.Router()),
+				p.SetTracer(this.srv.Tracer()))
 
 			go so.Start()
 
-			if err := register(so); err != nil {
+			if err := this.srv.register(so); err != nil {
 				logs.Errorf("Register err: %s", err.Error())
 				if strings.Contains(err.Error(), "send timeout") {
 					this.Lock()
 					if gw, ok := this.m[key]; ok {
 						gw.status = _GW_STATUS_IDLE
 					}
+					this.report()
 					this.Unlock()
 				}
 				so.Close()
@@ -191,6 +212,7 @@ func (this *_GWList) dial(ctx context.Context, key string) {
 			gw.so = so
 			gw.cancelFn = nil
 			gw.status = _GW_STATUS_RUNNING
+			this.report()
 
 			so.SetContext(key)
 
@@ -199,62 +221,35 @@ func (this *_GWList) dial(ctx context.Context, key string) {
 	}
 }
 
-func dialGateway(ips []string) error {
-	if len(ips) == 0 {
-		errors.New("gateway addr isn't specified")
+func (s *Service) addGateway(addr string) {
+	if HostAddrCheck(addr) == false {
+		logs.Errorf("Bad gateway address: %s", addr)
+		return
 	}
 
-	for _, k := range ips {
-		if HostAddrCheck(k) == false {
-			logs.Errorf("Bad gateway address: %s", k)
-			continue
-		}
-
-		gwList.Add(k)
-		logs.Debugf("Add gateway[%v] to pool", k)
-	}
+	s.gwList.Add(addr)
+	logs.Debugf("Add gateway[%v] to pool", addr)
+}
 
-	monitor := func() {
-		for {
-			time.Sleep(3 * time.Second)
-			if gwList.GetReadyCount() == 0 {
-				logs.Waringf("No gateway is available ...")
-			}
-		}
+func (s *Service) delGateway(addr string) {
+	if HostAddrCheck(addr) == false {
+		logs.Errorf("Bad gateway address: %s", addr)
+		return
 	}
 
-	go monitor()
-
-	return nil
+	s.gwList.Del(addr)
+	logs.Debugf("Delete gateway[%v] from pool", addr)
 }
 
-func fetchGateway() error {
-	var (
-		keys []string
-		err  error
-	)
-
-	pfx := srv.GatewayDir()
-
-	if keys, _, err = srv.EtcdConn().GetKvWithPrefix(pfx); err != nil {
+func (s *Service) fetchGateway() error {
+	if err := s.Discovery().WatchGateway(s.addGateway, s.delGateway); err != nil {
 		return err
 	}
 
-	for _, k := range keys {
-		key := strings.TrimPrefix(k, pfx)
-		if HostAddrCheck(key) == false {
-			logs.Errorf("Bad gateway address: %s", key)
-			continue
-		}
-
-		gwList.Add(key)
-		logs.Debugf("Add gateway[%v] to pool", key)
-	}
-
 	monitor := func() {
 		for {
 			time.Sleep(3 * time.Second)
-			if gwList.GetReadyCount() == 0 {
+			if s.gwList.GetReadyCount() == 0 {
 				logs.Waringf("No gateway is available ...")
 			}
 		}
@@ -262,55 +257,5 @@ func fetchGateway() error {
 
 	go monitor()
 
-	go watchGateway()
-
 	return nil
 }
-
-func watchGateway() {
-	pfx := srv.GatewayDir()
-
-	for {
-		time.Sleep(1 * time.Second)
-		rch := srv.EtcdConn().WatchKeyWithPrefix(pfx)
-		logs.Debugf("Wath: %v", pfx)
-		for wresp := range rch {
-			if wresp.Canceled {
-				logs.Infof("Watch %v canceled", pfx)
-				break
-			}
-
-			if err := wresp.Err(); err != nil {
-				logs.Errorf("Watch %v err: %s", pfx, err.Error())
-				break
-			}
-
-			for _, ev := range wresp.Events {
-				switch ev.Type.String() {
-				case "PUT":
-					logs.Infof("Add ETCD [key:%s,value:%s]", string(ev.Kv.Key), string(ev.Kv.Value))
-					key := strings.TrimPrefix(string(ev.Kv.Key), pfx)
-
-					if HostAddrCheck(key) == false {
-						logs.Errorf("Bad gateway address: %s", key)
-						continue
-					}
-
-					gwList.Add(key)
-					logs.Debugf("Add gateway[%v] to pool", key)
-				case "DELETE":
-					logs.Infof("Delete ETCD [key:%s,value:%s]", string(ev.Kv.Key), string(ev.Kv.Value))
-					key := strings.TrimPrefix(string(ev.Kv.Key), pfx)
-
-					if HostAddrCheck(key) == false {
-						logs.Errorf("Bad gateway address: %s", key)
-						continue
-					}
-
-					gwList.Del(key)
-					logs.Debugf("Delete gateway[%v] from pool", key)
-				}
-			}
-		}
-	}
-}
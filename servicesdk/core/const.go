@@ -17,8 +17,23 @@ const (
 	DEFAULT_ETCD_PERIOD = 5
 	// 权重环境变量名
 	DEFAULT_WEIGHT_ENV = "CORESVR_WEIGHT"
-	// 服务发现方式: ETCD or STATIC
+	// 服务发现方式: ETCD, STATIC, FILE or DNS
 	DEFAULT_DISCOVER_MODE = "ETCD"
+	// 实例ID生成方式
+	DEFAULT_ID_MODE = ID_MODE_IP
+	// 租约分配实例ID的ETCD子目录
+	DEFAULT_ID_DIR = "ids/"
+	// 租约分配实例ID范围, 低于IP生成的ID以免冲突
+	DEFAULT_ID_MIN = 1
+	DEFAULT_ID_MAX = 0xFFFF
+)
+
+// 实例ID生成方式
+const (
+	ID_MODE_IP       = "IP"       // 本机IP转整型, 兼容旧版本
+	ID_MODE_IP_PORT  = "IP_PORT"  // IP与端口的哈希, 冲突由注册前检查发现
+	ID_MODE_LEASE    = "LEASE"    // ETCD租约分配
+	ID_MODE_EXPLICIT = "EXPLICIT" // 配置指定
 )
 
 // 服务类型
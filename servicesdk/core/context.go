@@ -94,7 +94,11 @@ func (this *SDKContext) SendBroadcast(svrType uint16, handlerID uint16, body []b
 }
 
 func SendRequest(ctx context.Context, svrType uint16, svrID uint32, handlerID uint16, body []byte) ([]byte, error) {
-	so := gwList.Roll()
+	return srv.SendRequest(ctx, svrType, svrID, handlerID, body)
+}
+
+func (s *Service) SendRequest(ctx context.Context, svrType uint16, svrID uint32, handlerID uint16, body []byte) ([]byte, error) {
+	so := s.gwList.Roll()
 	if so == nil {
 		return nil, fmt.Errorf("no gateway is available")
 	}
@@ -114,7 +118,11 @@ func SendRequest(ctx context.Context, svrType uint16, svrID uint32, handlerID ui
 }
 
 func SendNormal(ctx context.Context, svrType uint16, svrID uint32, handlerID uint16, body []byte) error {
-	so := gwList.Roll()
+	return srv.SendNormal(ctx, svrType, svrID, handlerID, body)
+}
+
+func (s *Service) SendNormal(ctx context.Context, svrType uint16, svrID uint32, handlerID uint16, body []byte) error {
+	so := s.gwList.Roll()
 	if so == nil {
 		return fmt.Errorf("no gateway is available")
 	}
@@ -130,7 +138,11 @@ func SendNormal(ctx context.Context, svrType uint16, svrID uint32, handlerID uin
 }
 
 func SendBroadcast(ctx context.Context, svrType uint16, handlerID uint16, body []byte) error {
-	so := gwList.Roll()
+	return srv.SendBroadcast(ctx, svrType, handlerID, body)
+}
+
+func (s *Service) SendBroadcast(ctx context.Context, svrType uint16, handlerID uint16, body []byte) error {
+	so := s.gwList.Roll()
 	if so == nil {
 		return fmt.Errorf("no gateway is available")
 	}
@@ -145,19 +157,29 @@ func SendBroadcast(ctx context.Context, svrType uint16, handlerID uint16, body [
 	return err
 }
 
+// AddHandler 注册默认服务的消息处理函数
 func AddHandler(id uint16, fn func(*SDKContext)) {
+	srv.AddHandler(id, fn)
+}
+
+// AddHandler 注册消息处理函数
+func (s *Service) AddHandler(id uint16, fn func(*SDKContext)) {
 	fp := func(ctx context.Context, so *p.Socket, msg *p.Message) {
 		sctx := NewSDKContext(ctx, so, msg)
 		fn(sctx)
 	}
 
-	if err := p.AddHandler(id, fp); err != nil {
+	if err := s.router.AddHandler(id, fp); err != nil {
 		panic(fmt.Sprintf("Add handler err: %v", err.Error()))
 	}
 }
 
 // SendAliLog 发送阿里日志
 func SendAliLog(store, topic string, contents map[string]string) error {
+	return srv.SendAliLog(store, topic, contents)
+}
+
+func (s *Service) SendAliLog(store, topic string, contents map[string]string) error {
 	if store == "" {
 		return errors.New("store can't be empty")
 	}
@@ -165,7 +187,7 @@ func SendAliLog(store, topic string, contents map[string]string) error {
 		return errors.New("topic can't be empty")
 	}
 
-	producer := srv.KafkaProducer()
+	producer := s.KafkaProducer()
 	if producer == nil {
 		return errors.New("please init first")
 	}
@@ -177,7 +199,7 @@ func SendAliLog(store, topic string, contents map[string]string) error {
 	}
 	msg, _ := proto.Marshal(reqMsg)
 
-	if err := producer.SendMessage(srv.AliLogTopic(), msg); err != nil {
+	if err := producer.SendMessage(s.AliLogTopic(), msg); err != nil {
 		return err
 	}
 
@@ -185,7 +207,11 @@ func SendAliLog(store, topic string, contents map[string]string) error {
 }
 
 func SendBroadcastFish(ctx context.Context, svrType uint16, handlerID uint16, msgID uint32, body []byte) error {
-	so := gwList.Roll()
+	return srv.SendBroadcastFish(ctx, svrType, handlerID, msgID, body)
+}
+
+func (s *Service) SendBroadcastFish(ctx context.Context, svrType uint16, handlerID uint16, msgID uint32, body []byte) error {
+	so := s.gwList.Roll()
 	if so == nil {
 		return fmt.Errorf("no gateway is available")
 	}
@@ -0,0 +1,105 @@
+package core
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/kkkkiven/fishpkg/logs"
+)
+
+// Instance 服务实例注册信息
+type Instance struct {
+	Id        uint32            `json:"id"`
+	Name      string            `json:"name"`
+	Type      uint16            `json:"type"`
+	Ip        string            `json:"ip"`
+	Port      int               `json:"port,omitempty"`
+	Owner     string            `json:"owner,omitempty"`
+	Weight    int               `json:"weight"`
+	Version   string            `json:"version,omitempty"`
+	Commit    string            `json:"commit,omitempty"`
+	Region    string            `json:"region,omitempty"`
+	Zone      string            `json:"zone,omitempty"`
+	Functions []uint16          `json:"functions,omitempty"`
+	StartTime int64             `json:"start_time,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+}
+
+// Match 判断实例标签是否满足选择器, 选择器中的每一项都需相等
+func (i *Instance) Match(selector map[string]string) bool {
+	for k, v := range selector {
+		if lv, ok := i.Labels[k]; !ok || lv != v {
+			return false
+		}
+	}
+
+	return true
+}
+
+// HasFunction 判断实例是否支持指定函数id
+func (i *Instance) HasFunction(id uint16) bool {
+	for _, fid := range i.Functions {
+		if fid == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// GetInstances 通过默认服务获取指定类型的全部服务实例
+func GetInstances(svrType uint16) ([]*Instance, error) {
+	return srv.GetInstances(svrType)
+}
+
+// GetInstances 获取指定类型的全部服务实例, svrType为0时返回所有类型
+func (s *Service) GetInstances(svrType uint16) ([]*Instance, error) {
+	d := s.Discovery()
+	if d == nil {
+		return nil, errors.New("please init first")
+	}
+
+	values, err := d.List(fmt.Sprintf("%vcore_", s.ServiceDir()))
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*Instance, 0, len(values))
+	for _, v := range values {
+		ins := &Instance{}
+		if err := json.Unmarshal([]byte(v), ins); err != nil {
+			logs.Errorf("Decode instance err: %v, value: %v", err.Error(), v)
+			continue
+		}
+
+		if svrType != 0 && ins.Type != svrType {
+			continue
+		}
+
+		list = append(list, ins)
+	}
+
+	return list, nil
+}
+
+// FindInstances 通过默认服务按标签查找服务实例
+func FindInstances(svrType uint16, selector map[string]string) ([]*Instance, error) {
+	return srv.FindInstances(svrType, selector)
+}
+
+// FindInstances 按标签查找指定类型的服务实例, 可用于灰度路由与滚动发布检查
+func (s *Service) FindInstances(svrType uint16, selector map[string]string) ([]*Instance, error) {
+	all, err := s.GetInstances(svrType)
+	if err != nil {
+		return nil, err
+	}
+
+	list := make([]*Instance, 0, len(all))
+	for _, ins := range all {
+		if ins.Match(selector) {
+			list = append(list, ins)
+		}
+	}
+
+	return list, nil
+}
@@ -0,0 +1,96 @@
+package core
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/kkkkiven/fishpkg/metrics"
+	pCore "github.com/kkkkiven/fishpkg/sprotocol/core"
+)
+
+// 调用结果标签, 收到响应时由SetCallCodeFunc设置的函数提取响应码, 未设置时为CALL_OK
+const (
+	CALL_OK         = "ok"
+	CALL_TIMEOUT    = "timeout"
+	CALL_SEND_ERROR = "send_error"
+	CALL_UNKNOWN    = "unknown"
+)
+
+// CallCodeFunc 从响应消息中提取响应码, 返回空串时记为CALL_OK
+type CallCodeFunc func(rsp *pCore.Message) string
+
+var callCodeFunc CallCodeFunc
+
+// SetCallCodeFunc 设置SdkClient调用指标的响应码提取函数, 响应体格式由业务定义, 需在发起调用前设置
+func SetCallCodeFunc(fn CallCodeFunc) {
+	callCodeFunc = fn
+}
+
+var (
+	metricCallSeconds = metrics.NewHistogramVec("servicesdk_client_call_duration_seconds",
+		"SdkClient call latency by destination service type and function id.", nil, "svr_type", "func_id")
+	metricCalls = metrics.NewCounterVec("servicesdk_client_call_total",
+		"SdkClient calls by destination and response code.", "svr_type", "func_id", "code")
+	metricGateways = metrics.NewGaugeVec("servicesdk_gateway_connections",
+		"Gateway connections in the pool by service and state.", "service", "state")
+)
+
+// send 发送请求并记录耗时与调用结果
+func (c *SdkClient) send(tc context.Context, m *pCore.Message) (*pCore.Message, error) {
+	start := time.Now()
+	rsp, err := c.so.Send(tc, m)
+
+	svrType := strconv.Itoa(int(m.GetToSvrType()))
+	funcId := strconv.Itoa(int(m.GetFunctionID()))
+	metricCallSeconds.WithLabelValues(svrType, funcId).Observe(time.Since(start).Seconds())
+
+	metricCalls.WithLabelValues(svrType, funcId, callCode(rsp, err)).Inc()
+
+	return rsp, err
+}
+
+// callCode 调用结果标签, 超时与其他发送错误分别记录
+func callCode(rsp *pCore.Message, err error) string {
+	switch {
+	case err == pCore.ErrSendTimeout:
+		return CALL_TIMEOUT
+	case err != nil:
+		return CALL_SEND_ERROR
+	case rsp == nil || callCodeFunc == nil:
+		return CALL_OK
+	}
+
+	if code := callCodeFunc(rsp); code != "" {
+		return code
+	}
+
+	return CALL_OK
+}
+
+// gwStatusName 网关连接状态标签
+func gwStatusName(status _GWStatus) string {
+	switch status {
+	case _GW_STATUS_IDLE:
+		return "idle"
+	case _GW_STATUS_PENDING:
+		return "pending"
+	case _GW_STATUS_RUNNING:
+		return "running"
+	}
+
+	return CALL_UNKNOWN
+}
+
+// report 更新网关连接池状态指标, 调用方需持有锁
+func (this *_GWList) report() {
+	counts := map[_GWStatus]int{_GW_STATUS_IDLE: 0, _GW_STATUS_PENDING: 0, _GW_STATUS_RUNNING: 0}
+	for _, gw := range this.m {
+		counts[gw.status]++
+	}
+
+	name := this.srv.Name()
+	for status, n := range counts {
+		metricGateways.WithLabelValues(name, gwStatusName(status)).Set(float64(n))
+	}
+}
@@ -0,0 +1,37 @@
+package core
+
+import (
+	"errors"
+	"testing"
+
+	pCore "github.com/kkkkiven/fishpkg/sprotocol/core"
+)
+
+func TestCallCode(t *testing.T) {
+	defer SetCallCodeFunc(nil)
+
+	rsp := pCore.NewResponseMessage()
+	rsp.SetBody([]byte("E1001"))
+
+	cases := []struct {
+		name string
+		fn   CallCodeFunc
+		rsp  *pCore.Message
+		err  error
+		want string
+	}{
+		{"ok", nil, rsp, nil, CALL_OK},
+		{"timeout", nil, nil, pCore.ErrSendTimeout, CALL_TIMEOUT},
+		{"send error", nil, nil, errors.New("post failed"), CALL_SEND_ERROR},
+		{"extracted", func(m *pCore.Message) string { return string(m.GetBody()) }, rsp, nil, "E1001"},
+		{"empty code", func(m *pCore.Message) string { return "" }, rsp, nil, CALL_OK},
+		{"error ignores extractor", func(m *pCore.Message) string { return "E1001" }, nil, pCore.ErrSendTimeout, CALL_TIMEOUT},
+	}
+
+	for _, c := range cases {
+		SetCallCodeFunc(c.fn)
+		if got := callCode(c.rsp, c.err); got != c.want {
+			t.Errorf("%v: callCode = %v, want %v", c.name, got, c.want)
+		}
+	}
+}
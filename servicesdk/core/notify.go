@@ -9,6 +9,7 @@ import (
 
 type Notify struct {
 	ping int
+	srv  *Service
 }
 
 // 关闭tcp连接回调
@@ -16,7 +17,11 @@ func (this *Notify) OnClose(so *p.Socket) {
 	logs.Waringf("- %s - Reconnection", so.GetConn().RemoteAddr().String())
 
 	if key, ok := so.GetContext().(string); ok {
-		gwList.Add(key)
+		s := this.srv
+		if s == nil {
+			s = GetService()
+		}
+		s.gwList.Add(key)
 		time.Sleep(1 * time.Second)
 	}
 }
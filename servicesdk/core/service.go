@@ -3,6 +3,7 @@ package core
 import (
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"os"
 	"strings"
 	"sync"
@@ -12,6 +13,8 @@ import (
 	"github.com/golang/protobuf/proto"
 	"github.com/kkkkiven/fishpkg/logs"
 	. "github.com/kkkkiven/fishpkg/servicesdk/core/pb/core"
+	"github.com/kkkkiven/fishpkg/servicesdk/pkg/admin"
+	"github.com/kkkkiven/fishpkg/servicesdk/pkg/discovery"
 	"github.com/kkkkiven/fishpkg/servicesdk/pkg/etcd"
 	"github.com/kkkkiven/fishpkg/servicesdk/pkg/kafka"
 	u "github.com/kkkkiven/fishpkg/servicesdk/pkg/utils"
@@ -34,26 +37,51 @@ type EntityKafka struct {
 	Brokers     []string `yaml:"brokers" json:"brokers"`
 }
 
+// EntityExporter 链路追踪导出器配置项
+type EntityExporter struct {
+	Type     string            `yaml:"type" json:"type"`
+	Endpoint string            `yaml:"endpoint" json:"endpoint"`
+	Headers  map[string]string `yaml:"headers" json:"-"`
+}
+
 type CConfig struct {
-	Type         uint16       `yaml:"type" json:"type"`
-	Name         string       `yaml:"name" json:"name"`
-	DiscoverMode string       `yaml:"discover_mode" json:"discover_mode"`
-	Secret       string       `yaml:"secret" json:"-"`
-	ProberAddr   string       `yaml:"prober_addr" json:"prober_addr"`
-	GatewayAddr  []string     `yaml:"gateway_addr" json:"gateway_addr"`
-	TraceRate    int          `yaml:"trace_rate" json:"trace_rate"`
-	Timeout      int64        `yaml:"timeout" json:"timeout"`
-	Pack         bool         `yaml:"pack" json:"pack"`
-	GatewayDir   string       `yaml:"gateway_dir" json:"gateway_dir"`
-	ServiceDir   string       `yaml:"service_dir" json:"service_dir"`
-	Etcd         *EntityEtcd  `yaml:"etcd" json:"etcd"`
-	Kafka        *EntityKafka `yaml:"kafka" json:"kafka"`
-}
-
-type _Service struct {
+	Type         uint16            `yaml:"type" json:"type"`
+	Name         string            `yaml:"name" json:"name"`
+	IdMode       string            `yaml:"id_mode" json:"id_mode"`
+	Id           uint32            `yaml:"id" json:"id"`
+	Port         int               `yaml:"port" json:"port"`
+	DiscoverMode string            `yaml:"discover_mode" json:"discover_mode"`
+	Secret       string            `yaml:"secret" json:"-"`
+	ProberAddr   string            `yaml:"prober_addr" json:"prober_addr"`
+	AdminAddr    string            `yaml:"admin_addr" json:"admin_addr"`
+	GatewayAddr  []string          `yaml:"gateway_addr" json:"gateway_addr"`
+	GatewayFile  string            `yaml:"gateway_file" json:"gateway_file"`
+	GatewaySrv   string            `yaml:"gateway_srv" json:"gateway_srv"`
+	Version      string            `yaml:"version" json:"version"`
+	Commit       string            `yaml:"commit" json:"commit"`
+	Region       string            `yaml:"region" json:"region"`
+	Zone         string            `yaml:"zone" json:"zone"`
+	Labels       map[string]string `yaml:"labels" json:"labels"`
+	TraceRate    int               `yaml:"trace_rate" json:"trace_rate"`
+	Exporters    []*EntityExporter `yaml:"exporters" json:"exporters"`
+	Sampling     *t.SamplingConfig `yaml:"sampling" json:"sampling"`
+	Timeout      int64             `yaml:"timeout" json:"timeout"`
+	Pack         bool              `yaml:"pack" json:"pack"`
+	GatewayDir   string            `yaml:"gateway_dir" json:"gateway_dir"`
+	ServiceDir   string            `yaml:"service_dir" json:"service_dir"`
+	Etcd         *EntityEtcd       `yaml:"etcd" json:"etcd"`
+	Kafka        *EntityKafka      `yaml:"kafka" json:"kafka"`
+}
+
+type Service struct {
 	sync.RWMutex
 
 	id           uint32
+	idMode       string
+	idKey        string
+	onIdLost     func(id uint32)
+	owner        string
+	port         int
 	typ          uint16
 	name         string
 	secret       string
@@ -61,80 +89,152 @@ type _Service struct {
 	ip           string
 	proberAddr   string
 	gatewayAddr  []string
+	gatewayFile  string
+	gatewaySrv   string
 	discoverMode string
 	traceRate    int
+	exporters    []*EntityExporter
+	sampling     *t.SamplingConfig
 	timeout      int64
 	pack         bool
 
+	// 元数据
+	version   string
+	commit    string
+	region    string
+	zone      string
+	labels    map[string]string
+	startTime int64
+
 	// ETCD相关
 	gatewayDir string
 	serviceDir string
 	etcdConf   *EntityEtcd
 	etcdConn   *etcd.Client
 
+	// 服务发现
+	discovery discovery.Discovery
+
 	// kafka
 	kafkaConf     *EntityKafka
 	kafkaProducer *kafka.AsyncProducer
+
+	// 网关连接池、消息路由与链路追踪
+	gwList *_GWList
+	router *p.Router
+	tracer *t.Tracer
+
+	// 管理端口
+	admin *admin.Server
 }
 
-type option func(*_Service)
+type option func(*Service)
 
 func SetType(t uint16) option {
-	return func(s *_Service) {
+	return func(s *Service) {
 		s.typ = t
 	}
 }
 
 func SetName(n string) option {
-	return func(s *_Service) {
+	return func(s *Service) {
 		s.name = n
 	}
 }
 
+func SetIdMode(m string) option {
+	return func(s *Service) {
+		s.idMode = m
+	}
+}
+
+// SetId 指定实例ID, 需配合ID_MODE_EXPLICIT使用
+func SetId(id uint32) option {
+	return func(s *Service) {
+		s.id = id
+	}
+}
+
+// SetIdLostHandler 设置租约分配的ID被其他实例占用时的处理函数, 未设置时注销网关并停止服务
+func SetIdLostHandler(fn func(id uint32)) option {
+	return func(s *Service) {
+		s.onIdLost = fn
+	}
+}
+
+// SetPort 设置实例端口, ID_MODE_IP_PORT方式下参与生成实例ID
+func SetPort(port int) option {
+	return func(s *Service) {
+		s.port = port
+	}
+}
+
 func SetSecret(secret string) option {
-	return func(s *_Service) {
+	return func(s *Service) {
 		s.secret = secret
 	}
 }
 
 func SetProberAddr(p string) option {
-	return func(s *_Service) {
+	return func(s *Service) {
 		s.proberAddr = p
 	}
 }
 
+// SetAdminAddr 设置管理端口监听地址, 为空时不开启
+func SetAdminAddr(addr string) option {
+	return func(s *Service) {
+		s.admin.SetAddr(addr)
+	}
+}
+
 func SetTraceRate(r int) option {
-	return func(s *_Service) {
+	return func(s *Service) {
 		s.traceRate = r
 	}
 }
 
+// SetExporters 设置链路追踪导出器, 类型为ZIPKIN, OTLP或FILE
+func SetExporters(exps []*EntityExporter) option {
+	return func(s *Service) {
+		s.exporters = nil
+		s.exporters = append(s.exporters, exps...)
+	}
+}
+
+// SetSampling 设置链路追踪采样规则
+func SetSampling(c *t.SamplingConfig) option {
+	return func(s *Service) {
+		s.sampling = c
+	}
+}
+
 func SetTimeout(t int64) option {
-	return func(s *_Service) {
+	return func(s *Service) {
 		s.timeout = t
 	}
 }
 
 func SetPack(p bool) option {
-	return func(s *_Service) {
+	return func(s *Service) {
 		s.pack = p
 	}
 }
 
 func SetGatewayDir(d string) option {
-	return func(s *_Service) {
+	return func(s *Service) {
 		s.gatewayDir = d
 	}
 }
 
 func SetServiceDir(d string) option {
-	return func(s *_Service) {
+	return func(s *Service) {
 		s.serviceDir = d
 	}
 }
 
 func SetEtcdConf(c *EntityEtcd) option {
-	return func(s *_Service) {
+	return func(s *Service) {
 		if c == nil || len(c.Addrs) == 0 {
 			return
 		}
@@ -147,7 +247,7 @@ func SetEtcdConf(c *EntityEtcd) option {
 }
 
 func SetKafkaConf(c *EntityKafka) option {
-	return func(s *_Service) {
+	return func(s *Service) {
 		if c == nil || len(c.Brokers) == 0 {
 			return
 		}
@@ -168,237 +268,546 @@ func SetKafkaConf(c *EntityKafka) option {
 }
 
 func SetDiscoverMode(m string) option {
-	return func(s *_Service) {
+	return func(s *Service) {
 		s.discoverMode = m
 	}
 }
 
 func SetGatewayAddr(ips []string) option {
-	return func(s *_Service) {
+	return func(s *Service) {
 		s.gatewayAddr = nil
 		s.gatewayAddr = append(s.gatewayAddr, ips...)
 	}
 }
 
-func (s *_Service) Id() uint32 {
+func SetGatewayFile(f string) option {
+	return func(s *Service) {
+		s.gatewayFile = f
+	}
+}
+
+func SetGatewaySrv(name string) option {
+	return func(s *Service) {
+		s.gatewaySrv = name
+	}
+}
+
+func SetVersion(v string) option {
+	return func(s *Service) {
+		s.version = v
+	}
+}
+
+func SetCommit(c string) option {
+	return func(s *Service) {
+		s.commit = c
+	}
+}
+
+func SetRegion(r string) option {
+	return func(s *Service) {
+		s.region = r
+	}
+}
+
+func SetZone(z string) option {
+	return func(s *Service) {
+		s.zone = z
+	}
+}
+
+func SetLabels(labels map[string]string) option {
+	return func(s *Service) {
+		s.labels = make(map[string]string, len(labels))
+		for k, v := range labels {
+			s.labels[k] = v
+		}
+	}
+}
+
+func (s *Service) Id() uint32 {
 	s.RLock()
 	defer s.RUnlock()
 
 	return s.id
 }
 
-func (s *_Service) Type() uint16 {
+func (s *Service) IdMode() string {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.idMode
+}
+
+func (s *Service) Port() int {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.port
+}
+
+// Owner 实例归属标识, 用于区分同一ID下的不同进程
+func (s *Service) Owner() string {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.owner
+}
+
+func (s *Service) Type() uint16 {
 	s.RLock()
 	defer s.RUnlock()
 
 	return s.typ
 }
 
-func (s *_Service) Name() string {
+func (s *Service) Name() string {
 	s.RLock()
 	defer s.RUnlock()
 
 	return s.name
 }
 
-func (s *_Service) Secret() string {
+func (s *Service) Secret() string {
 	s.RLock()
 	defer s.RUnlock()
 
 	return s.secret
 }
 
-func (s *_Service) Weight() int {
+func (s *Service) Weight() int {
 	s.RLock()
 	defer s.RUnlock()
 
 	return s.weight
 }
 
-func (s *_Service) Ip() string {
+func (s *Service) Ip() string {
 	s.RLock()
 	defer s.RUnlock()
 
 	return s.ip
 }
 
-func (s *_Service) ProberAddr() string {
+func (s *Service) ProberAddr() string {
 	s.RLock()
 	defer s.RUnlock()
 
 	return s.proberAddr
 }
 
-func (s *_Service) TraceRate() int {
+func (s *Service) TraceRate() int {
 	s.RLock()
 	defer s.RUnlock()
 
 	return s.traceRate
 }
 
-func (s *_Service) Timeout() int64 {
+func (s *Service) Timeout() int64 {
 	s.RLock()
 	defer s.RUnlock()
 
 	return s.timeout
 }
 
-func (s *_Service) Pack() bool {
+func (s *Service) Pack() bool {
 	s.RLock()
 	defer s.RUnlock()
 
 	return s.pack
 }
 
-func (s *_Service) GatewayDir() string {
+func (s *Service) GatewayDir() string {
 	s.RLock()
 	defer s.RUnlock()
 
 	return s.gatewayDir
 }
 
-func (s *_Service) ServiceDir() string {
+func (s *Service) ServiceDir() string {
 	s.RLock()
 	defer s.RUnlock()
 
 	return s.serviceDir
 }
 
-func (s *_Service) EtcdConn() *etcd.Client {
+func (s *Service) EtcdConn() *etcd.Client {
 	s.RLock()
 	defer s.RUnlock()
 
 	return s.etcdConn
 }
 
-func (s *_Service) KafkaProducer() *kafka.AsyncProducer {
+func (s *Service) KafkaProducer() *kafka.AsyncProducer {
 	s.RLock()
 	defer s.RUnlock()
 
 	return s.kafkaProducer
 }
 
-func (s *_Service) AliLogTopic() string {
+func (s *Service) AliLogTopic() string {
 	s.RLock()
 	defer s.RUnlock()
 
 	return s.kafkaConf.AliLogTopic
 }
 
-func (s *_Service) TracerTopic() string {
+func (s *Service) TracerTopic() string {
 	s.RLock()
 	defer s.RUnlock()
 
 	return s.kafkaConf.TracerTopic
 }
 
-func (s *_Service) DiscoverMode() string {
+func (s *Service) DiscoverMode() string {
 	s.RLock()
 	defer s.RUnlock()
 
 	return s.discoverMode
 }
 
-func (s *_Service) GatewayAddr() []string {
+func (s *Service) GatewayAddr() []string {
 	s.RLock()
 	defer s.RUnlock()
 
 	return s.gatewayAddr
 }
 
-func GetService() *_Service {
+func (s *Service) Version() string {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.version
+}
+
+func (s *Service) Commit() string {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.commit
+}
+
+func (s *Service) Region() string {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.region
+}
+
+func (s *Service) Zone() string {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.zone
+}
+
+func (s *Service) Labels() map[string]string {
+	s.RLock()
+	defer s.RUnlock()
+
+	labels := make(map[string]string, len(s.labels))
+	for k, v := range s.labels {
+		labels[k] = v
+	}
+
+	return labels
+}
+
+func (s *Service) StartTime() int64 {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.startTime
+}
+
+func (s *Service) GatewayList() *_GWList {
+	return s.gwList
+}
+
+func (s *Service) Router() *p.Router {
+	return s.router
+}
+
+func (s *Service) Tracer() *t.Tracer {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.tracer
+}
+
+func (s *Service) Discovery() discovery.Discovery {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.discovery
+}
+
+// newDiscovery 根据服务发现方式创建实现, 调用方需持有写锁
+func (s *Service) newDiscovery() (discovery.Discovery, error) {
+	switch discovery.NormalizeMode(s.discoverMode) {
+	case discovery.MODE_ETCD:
+		if s.etcdConf == nil || len(s.etcdConf.Addrs) == 0 {
+			return nil, errors.New("bad ETCD config")
+		}
+
+		conn, err := etcd.New(s.etcdConf.Addrs, s.etcdConf.User, s.etcdConf.Pass)
+		if err != nil {
+			return nil, err
+		}
+		s.etcdConn = conn
+
+		return discovery.NewEtcd(conn, s.gatewayDir, DEFAULT_ETCD_EXPIRE, DEFAULT_ETCD_PERIOD), nil
+	case discovery.MODE_STATIC:
+		return discovery.NewStatic(s.gatewayAddr), nil
+	case discovery.MODE_FILE:
+		return discovery.NewFile(s.gatewayFile, 0), nil
+	case discovery.MODE_DNS:
+		return discovery.NewDNS(s.gatewaySrv, 0), nil
+	}
+
+	return nil, fmt.Errorf("unsupported discover mode: %v", s.discoverMode)
+}
+
+// initId 按ID生成方式确定实例ID, 调用方需持有写锁
+func (s *Service) initId() error {
+	if s.idMode == "" {
+		s.idMode = DEFAULT_ID_MODE
+	}
+	s.idMode = strings.ToUpper(strings.TrimSpace(s.idMode))
+
+	switch s.idMode {
+	case ID_MODE_IP:
+		s.id = utils.Ip2long(s.ip)
+	case ID_MODE_IP_PORT:
+		if s.port <= 0 || s.port > 0xFFFF {
+			return errors.New("bad service port")
+		}
+		s.id = ipPortId(s.ip, s.port)
+	case ID_MODE_LEASE:
+		alloc, ok := s.discovery.(discovery.Allocator)
+		if !ok {
+			return fmt.Errorf("id mode %v isn't supported by discover mode %v", s.idMode, s.discoverMode)
+		}
+
+		pfx := s.serviceDir + DEFAULT_ID_DIR
+		id, err := alloc.Allocate(pfx, s.owner, DEFAULT_ID_MIN, DEFAULT_ID_MAX, s.idLost)
+		if err != nil {
+			return err
+		}
+		s.id = id
+		s.idKey = fmt.Sprintf("%v%v", pfx, id)
+	case ID_MODE_EXPLICIT:
+		if s.id == 0 {
+			return errors.New("bad service id")
+		}
+	default:
+		return fmt.Errorf("unsupported id mode: %v", s.idMode)
+	}
+
+	logs.Infof("Service id: [mode=%v,id=%v,owner=%v]", s.idMode, s.id, s.owner)
+	return nil
+}
+
+// ipPortId 由完整的ip:port哈希得到实例ID
+func ipPortId(ip string, port int) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(fmt.Sprintf("%v:%v", ip, port)))
+
+	if id := h.Sum32(); id != 0 {
+		return id
+	}
+	return 1
+}
+
+// SetIdLostHandler 设置租约分配的ID丢失时的处理函数, 未设置时停止服务
+func (s *Service) SetIdLostHandler(fn func(id uint32)) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.onIdLost = fn
+}
+
+// idLost 租约分配的ID已被其他实例占用, 清除idKey以免注销时删除对方的key
+func (s *Service) idLost(id uint32) {
+	s.Lock()
+	if s.id != id {
+		s.Unlock()
+		return
+	}
+	s.idKey = ""
+	fn := s.onIdLost
+	s.Unlock()
+
+	logs.Errorf("Service id %v is taken by another instance", id)
+
+	if fn != nil {
+		fn(id)
+		return
+	}
+
+	// 不再以该ID提供服务
+	for key := range s.gwList.Status() {
+		s.gwList.Del(key)
+	}
+	s.Stop()
+}
+
+// newOwner 生成进程唯一的归属标识
+func newOwner() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%v-%v-%v", host, os.Getpid(), time.Now().UnixNano())
+}
+
+// GetService 获取默认服务
+func GetService() *Service {
 	return srv
 }
 
-var srv *_Service = &_Service{}
+// 默认服务, 包级函数均作用于该实例
+var srv *Service = newService(p.DefaultRouter())
+
+func newService(router *p.Router) *Service {
+	s := &Service{router: router}
+	s.gwList = newGWList(s)
+	s.admin = newAdmin(s)
+	return s
+}
+
+// NewService 创建独立的服务实例, 用于单进程内注册多个服务身份
+func NewService(c *CConfig) (*Service, error) {
+	s := newService(p.NewRouter())
+	if err := s.Init(c); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// NewServiceOpts 通过选项创建独立的服务实例
+func NewServiceOpts(opts ...option) (*Service, error) {
+	s := newService(p.NewRouter())
+	if err := s.InitOpts(opts...); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
 
 func Init(c *CConfig) error {
-	srv.Lock()
-	defer srv.Unlock()
+	return srv.Init(c)
+}
+
+func (s *Service) Init(c *CConfig) error {
+	s.Lock()
+	defer s.Unlock()
 
-	srv.typ = c.Type
-	if srv.typ == 0 {
+	s.typ = c.Type
+	if s.typ == 0 {
 		return errors.New("bad service type")
 	}
 
-	srv.name = c.Name
-	if srv.name == "" {
+	s.name = c.Name
+	if s.name == "" {
 		return errors.New("bad service name")
 	}
 
-	srv.timeout = c.Timeout
-	if srv.timeout == 0 {
-		srv.timeout = DEFAULT_TIMEOUT
+	s.timeout = c.Timeout
+	if s.timeout == 0 {
+		s.timeout = DEFAULT_TIMEOUT
 	}
 
-	srv.gatewayDir = c.GatewayDir
-	if srv.gatewayDir == "" {
-		srv.gatewayDir = DEFAULT_GATEWAY_DIR
+	s.gatewayDir = c.GatewayDir
+	if s.gatewayDir == "" {
+		s.gatewayDir = DEFAULT_GATEWAY_DIR
 	}
-	if srv.gatewayDir[len(srv.gatewayDir)-1] != '/' {
-		srv.gatewayDir += "/"
+	if s.gatewayDir[len(s.gatewayDir)-1] != '/' {
+		s.gatewayDir += "/"
 	}
 
-	srv.serviceDir = c.ServiceDir
-	if srv.serviceDir == "" {
-		srv.serviceDir = DEFAULT_SERVICE_DIR
+	s.serviceDir = c.ServiceDir
+	if s.serviceDir == "" {
+		s.serviceDir = DEFAULT_SERVICE_DIR
 	}
-	if srv.serviceDir[len(srv.serviceDir)-1] != '/' {
-		srv.serviceDir += "/"
+	if s.serviceDir[len(s.serviceDir)-1] != '/' {
+		s.serviceDir += "/"
 	}
 
-	srv.discoverMode = c.DiscoverMode
-	if srv.discoverMode == "" {
-		srv.discoverMode = DEFAULT_DISCOVER_MODE
+	s.discoverMode = c.DiscoverMode
+	if s.discoverMode == "" {
+		s.discoverMode = DEFAULT_DISCOVER_MODE
 	}
 
-	srv.ip = u.GetLocalAddress(srv.proberAddr)
-	if srv.ip == "" {
+	s.ip = u.GetLocalAddress(s.proberAddr)
+	if s.ip == "" {
 		return errors.New("get local address failed")
 	}
 
-	srv.gatewayAddr = c.GatewayAddr
-	srv.proberAddr = c.ProberAddr
-	srv.id = utils.Ip2long(srv.ip)
-	srv.pack = c.Pack
-	srv.secret = c.Secret
-	srv.traceRate = c.TraceRate
+	s.gatewayAddr = c.GatewayAddr
+	s.gatewayFile = c.GatewayFile
+	s.gatewaySrv = c.GatewaySrv
+	s.proberAddr = c.ProberAddr
+	s.admin.SetAddr(c.AdminAddr)
+	s.idMode = c.IdMode
+	s.id = c.Id
+	s.port = c.Port
+	s.pack = c.Pack
+	s.secret = c.Secret
+	s.traceRate = c.TraceRate
+	s.exporters = append([]*EntityExporter(nil), c.Exporters...)
+	s.sampling = c.Sampling
+	s.version = c.Version
+	s.commit = c.Commit
+	s.region = c.Region
+	s.zone = c.Zone
+	s.labels = make(map[string]string, len(c.Labels))
+	for k, v := range c.Labels {
+		s.labels[k] = v
+	}
+	s.startTime = time.Now().Unix()
+	s.owner = newOwner()
 
-	srv.weight = utils.Atoi(os.Getenv(DEFAULT_WEIGHT_ENV))
-	logs.Infof("Node weight: [%v:%v]", DEFAULT_WEIGHT_ENV, srv.weight)
+	s.weight = utils.Atoi(os.Getenv(DEFAULT_WEIGHT_ENV))
+	logs.Infof("Node weight: [%v:%v]", DEFAULT_WEIGHT_ENV, s.weight)
 
 	var err error
-	if strings.ToUpper(srv.discoverMode) == DEFAULT_DISCOVER_MODE {
-		if c.Etcd == nil || len(c.Etcd.Addrs) == 0 {
-			return errors.New("bad ETCD config")
-		}
-		srv.etcdConf = new(EntityEtcd)
-		srv.etcdConf.User = c.Etcd.User
-		srv.etcdConf.Pass = c.Etcd.Pass
-		srv.etcdConf.Addrs = append(srv.etcdConf.Addrs, c.Etcd.Addrs...)
-		if srv.etcdConn, err = etcd.New(srv.etcdConf.Addrs, srv.etcdConf.User, srv.etcdConf.Pass); err != nil {
-			return err
-		}
+	if c.Etcd != nil && len(c.Etcd.Addrs) != 0 {
+		s.etcdConf = new(EntityEtcd)
+		s.etcdConf.User = c.Etcd.User
+		s.etcdConf.Pass = c.Etcd.Pass
+		s.etcdConf.Addrs = append(s.etcdConf.Addrs, c.Etcd.Addrs...)
+	}
+
+	if s.discovery, err = s.newDiscovery(); err != nil {
+		return err
+	}
+
+	if err = s.initId(); err != nil {
+		return err
 	}
 
 	if c.Kafka == nil || len(c.Kafka.Brokers) == 0 {
 		return errors.New("bad KAFKA config")
 	}
-	srv.kafkaConf = new(EntityKafka)
+	s.kafkaConf = new(EntityKafka)
 
-	srv.kafkaConf.AliLogTopic = c.Kafka.AliLogTopic
-	if srv.kafkaConf.AliLogTopic == "" {
-		srv.kafkaConf.AliLogTopic = DEFAULT_TOPIC_ALILOG
+	s.kafkaConf.AliLogTopic = c.Kafka.AliLogTopic
+	if s.kafkaConf.AliLogTopic == "" {
+		s.kafkaConf.AliLogTopic = DEFAULT_TOPIC_ALILOG
 	}
 
-	srv.kafkaConf.TracerTopic = c.Kafka.TracerTopic
-	if srv.kafkaConf.TracerTopic == "" {
-		srv.kafkaConf.TracerTopic = DEFAULT_TOPIC_TRACER
+	s.kafkaConf.TracerTopic = c.Kafka.TracerTopic
+	if s.kafkaConf.TracerTopic == "" {
+		s.kafkaConf.TracerTopic = DEFAULT_TOPIC_TRACER
 	}
 
-	srv.kafkaConf.Brokers = append(srv.kafkaConf.Brokers, c.Kafka.Brokers...)
+	s.kafkaConf.Brokers = append(s.kafkaConf.Brokers, c.Kafka.Brokers...)
 
 	onSuccess := func(msg *sarama.ProducerMessage) {
+		// 日志输出的消息不再记录日志, 避免回写同一输出
+		if kafka.IsLogSinkMessage(msg) {
+			return
+		}
+
 		value, err := msg.Value.Encode()
 		if err != nil {
 			logs.Errorf("Send kafka msg err: %s", err.Error())
@@ -408,86 +817,100 @@ func Init(c *CConfig) error {
 	}
 
 	onError := func(err *sarama.ProducerError) {
+		if kafka.IsLogSinkMessage(err.Msg) {
+			return
+		}
+
 		logs.Errorf("Send kafka msg err: %v", err.Error())
 	}
 
-	srv.kafkaProducer, err = kafka.NewAsyncProducer(srv.kafkaConf.Brokers, 10*time.Second, onSuccess, onError)
+	s.kafkaProducer, err = kafka.NewAsyncProducer(s.kafkaConf.Brokers, 10*time.Second, onSuccess, onError)
 	if err != nil {
 		return err
 	}
 
-	topic := srv.kafkaConf.TracerTopic
+	topic := s.kafkaConf.TracerTopic
 	fp := func(msg []byte, key string) error {
-		return srv.kafkaProducer.SendMessage(topic, msg, key)
+		return s.kafkaProducer.SendMessage(topic, msg, key)
 	}
 
-	t.Init(srv.typ, srv.id, srv.name, srv.traceRate, fp)
+	if err = s.initTracer(fp); err != nil {
+		return err
+	}
 
 	return nil
 }
 
 func InitOpts(opts ...option) error {
-	srv.Lock()
-	defer srv.Unlock()
+	return srv.InitOpts(opts...)
+}
+
+func (s *Service) InitOpts(opts ...option) error {
+	s.Lock()
+	defer s.Unlock()
 
 	for _, opt := range opts {
-		opt(srv)
+		opt(s)
 	}
 
-	if srv.typ == 0 {
+	if s.typ == 0 {
 		return errors.New("bad service type")
 	}
 
-	if srv.name == "" {
+	if s.name == "" {
 		return errors.New("bad service name")
 	}
 
-	if srv.timeout == 0 {
-		srv.timeout = DEFAULT_TIMEOUT
+	if s.timeout == 0 {
+		s.timeout = DEFAULT_TIMEOUT
 	}
 
-	if srv.gatewayDir == "" {
-		srv.gatewayDir = DEFAULT_GATEWAY_DIR
+	if s.gatewayDir == "" {
+		s.gatewayDir = DEFAULT_GATEWAY_DIR
 	}
-	if srv.gatewayDir[len(srv.gatewayDir)-1] != '/' {
-		srv.gatewayDir += "/"
+	if s.gatewayDir[len(s.gatewayDir)-1] != '/' {
+		s.gatewayDir += "/"
 	}
 
-	if srv.serviceDir == "" {
-		srv.serviceDir = DEFAULT_SERVICE_DIR
+	if s.serviceDir == "" {
+		s.serviceDir = DEFAULT_SERVICE_DIR
 	}
-	if srv.serviceDir[len(srv.serviceDir)-1] != '/' {
-		srv.serviceDir += "/"
+	if s.serviceDir[len(s.serviceDir)-1] != '/' {
+		s.serviceDir += "/"
 	}
 
-	if srv.discoverMode == "" {
-		srv.discoverMode = DEFAULT_DISCOVER_MODE
+	if s.discoverMode == "" {
+		s.discoverMode = DEFAULT_DISCOVER_MODE
 	}
 
-	srv.ip = u.GetLocalAddress(srv.proberAddr)
-	if srv.ip == "" {
+	s.ip = u.GetLocalAddress(s.proberAddr)
+	if s.ip == "" {
 		return errors.New("get local address failed")
 	}
 
-	srv.id = utils.Ip2long(srv.ip)
-	srv.weight = utils.Atoi(os.Getenv(DEFAULT_WEIGHT_ENV))
+	s.weight = utils.Atoi(os.Getenv(DEFAULT_WEIGHT_ENV))
+	s.startTime = time.Now().Unix()
+	s.owner = newOwner()
 
 	var err error
-	if strings.ToUpper(srv.discoverMode) == DEFAULT_DISCOVER_MODE {
-		if srv.etcdConf == nil || len(srv.etcdConf.Addrs) == 0 {
-			return errors.New("bad ETCD config")
-		}
+	if s.discovery, err = s.newDiscovery(); err != nil {
+		return err
+	}
 
-		if srv.etcdConn, err = etcd.New(srv.etcdConf.Addrs, srv.etcdConf.User, srv.etcdConf.Pass); err != nil {
-			return err
-		}
+	if err = s.initId(); err != nil {
+		return err
 	}
 
-	if srv.kafkaConf == nil || len(srv.kafkaConf.Brokers) == 0 {
+	if s.kafkaConf == nil || len(s.kafkaConf.Brokers) == 0 {
 		return errors.New("bad KAFKA config")
 	}
 
 	onSuccess := func(msg *sarama.ProducerMessage) {
+		// 日志输出的消息不再记录日志, 避免回写同一输出
+		if kafka.IsLogSinkMessage(msg) {
+			return
+		}
+
 		value, err := msg.Value.Encode()
 		if err != nil {
 			logs.Errorf("Send kafka msg err: %s", err.Error())
@@ -497,68 +920,153 @@ func InitOpts(opts ...option) error {
 	}
 
 	onError := func(err *sarama.ProducerError) {
+		if kafka.IsLogSinkMessage(err.Msg) {
+			return
+		}
+
 		logs.Errorf("Send kafka msg err: %v", err.Error())
 	}
 
-	if srv.kafkaProducer, err = kafka.NewAsyncProducer(srv.kafkaConf.Brokers, 10*time.Second, onSuccess, onError); err != nil {
+	if s.kafkaProducer, err = kafka.NewAsyncProducer(s.kafkaConf.Brokers, 10*time.Second, onSuccess, onError); err != nil {
 		return err
 	}
 
-	topic := srv.kafkaConf.TracerTopic
+	topic := s.kafkaConf.TracerTopic
 	fp := func(msg []byte, key string) error {
-		return srv.kafkaProducer.SendMessage(topic, msg, key)
+		return s.kafkaProducer.SendMessage(topic, msg, key)
 	}
 
-	t.Init(srv.typ, srv.id, srv.name, srv.traceRate, fp)
+	if err = s.initTracer(fp); err != nil {
+		return err
+	}
 
 	return nil
 }
 
 func Update(rate int) {
-	t.Update(rate)
+	srv.Update(rate)
 }
 
-func Start() error {
-	if strings.ToUpper(srv.DiscoverMode()) == DEFAULT_DISCOVER_MODE {
-		if srv.EtcdConn() == nil {
-			return errors.New("please init first")
+func (s *Service) Update(rate int) {
+	if tr := s.Tracer(); tr != nil {
+		tr.Update(rate)
+	}
+}
+
+// UpdateSampling 运行时更新默认服务的采样规则
+func UpdateSampling(c *t.SamplingConfig) {
+	srv.UpdateSampling(c)
+}
+
+// UpdateSampling 运行时更新采样规则
+func (s *Service) UpdateSampling(c *t.SamplingConfig) {
+	s.Lock()
+	s.sampling = c
+	tr := s.tracer
+	s.Unlock()
+
+	if tr != nil {
+		tr.SetSampling(c)
+	}
+}
+
+// initTracer 初始化链路追踪器及导出器, 默认服务沿用包级追踪器以兼容直接使用tracer包的代码
+func (s *Service) initTracer(fp func([]byte, string) error) error {
+	if s == srv {
+		t.Init(s.typ, s.id, s.name, s.traceRate, fp)
+		s.tracer = t.Default()
+	} else {
+		s.tracer = t.New(s.typ, s.id, s.name, s.traceRate, fp)
+	}
+
+	// 重复初始化时关闭旧的导出器
+	s.tracer.Shutdown()
+	s.tracer.SetSampling(s.sampling)
+
+	for _, c := range s.exporters {
+		if c == nil {
+			continue
 		}
 
-		if err := publish(); err != nil {
+		exp, err := t.NewExporter(c.Type, c.Endpoint, c.Headers)
+		if err != nil {
 			return err
 		}
+		s.tracer.AddExporter(exp)
+	}
+
+	return nil
+}
 
-		return fetchGateway()
+func Start() error {
+	return srv.Start()
+}
+
+func (s *Service) Start() error {
+	if s.Discovery() == nil {
+		return errors.New("please init first")
 	}
 
-	return dialGateway(srv.GatewayAddr())
+	if err := s.checkCollision(); err != nil {
+		return err
+	}
+
+	if err := s.publish(); err != nil {
+		return err
+	}
+
+	s.watch()
+
+	if err := s.fetchGateway(); err != nil {
+		return err
+	}
+
+	return s.startAdmin()
 }
 
 func Stop() {
-	if srv.EtcdConn() == nil {
+	srv.Stop()
+}
+
+func (s *Service) Stop() {
+	d := s.Discovery()
+	if d == nil {
 		return
 	}
 
-	revoke()
-	srv.EtcdConn().Close()
+	s.revoke()
+	s.admin.Stop()
+
+	if tr := s.Tracer(); tr != nil {
+		tr.Shutdown()
+	}
+
+	s.RLock()
+	idKey := s.idKey
+	s.RUnlock()
+	if idKey != "" {
+		d.Deregister(idKey)
+	}
+
+	d.Close()
 
 	return
 }
 
-func register(so *p.Socket) error {
+func (s *Service) register(so *p.Socket) error {
 	regReq := &RegMsg{}
-	regReq.Id = srv.Id()
-	regReq.Type = uint32(srv.Type())
-	regReq.Weight = int32(srv.Weight())
-	regReq.Name = srv.Name()
-	regReq.Secret = srv.Secret()
+	regReq.Id = s.Id()
+	regReq.Type = uint32(s.Type())
+	regReq.Weight = int32(s.Weight())
+	regReq.Name = s.Name()
+	regReq.Secret = s.Secret()
 
 	body, _ := proto.Marshal(regReq)
 
 	reqMsg := p.NewRequestMessage()
 	reqMsg.SetToSvrType(ST_GW_CORE)
-	reqMsg.SetFromSvrID(srv.Id())
-	reqMsg.SetFromSvrType(srv.Type())
+	reqMsg.SetFromSvrID(s.Id())
+	reqMsg.SetFromSvrType(s.Type())
 	reqMsg.SetFunctionID(F_ID_REGISTER)
 	reqMsg.SetBody(body)
 
@@ -580,108 +1088,200 @@ func register(so *p.Socket) error {
 	return nil
 }
 
-// func update(so *p.Socket) error {
-// 	upReq := &UpdateMsg{}
-// 	upReq.Id = srv.Id()
-// 	upReq.Type = uint32(srv.Type())
-// 	upReq.Weight = int32(srv.Weight())
+func (s *Service) update(so *p.Socket) error {
+	upReq := &UpdateMsg{}
+	upReq.Id = s.Id()
+	upReq.Type = uint32(s.Type())
+	upReq.Weight = int32(s.Weight())
 
-// 	body, _ := proto.Marshal(upReq)
+	body, _ := proto.Marshal(upReq)
 
-// 	reqMsg := p.NewRequestMessage()
-// 	reqMsg.SetToSvrType(ST_GW_CORE)
-// 	reqMsg.SetFromSvrID(srv.Id())
-// 	reqMsg.SetFromSvrType(srv.Type())
-// 	reqMsg.SetFunctionID(F_ID_UPDATE)
-// 	reqMsg.SetBody(body)
+	reqMsg := p.NewRequestMessage()
+	reqMsg.SetToSvrType(ST_GW_CORE)
+	reqMsg.SetFromSvrID(s.Id())
+	reqMsg.SetFromSvrType(s.Type())
+	reqMsg.SetFunctionID(F_ID_UPDATE)
+	reqMsg.SetBody(body)
 
-// 	rspMsg, err := so.Send(nil, reqMsg)
-// 	if err != nil {
-// 		return err
-// 	}
+	rspMsg, err := so.Send(nil, reqMsg)
+	if err != nil {
+		return err
+	}
 
-// 	upRsp := &RspMsg{}
-// 	if err := proto.Unmarshal(rspMsg.GetBody(), upRsp); err != nil {
-// 		return err
-// 	}
+	upRsp := &RspMsg{}
+	if err := proto.Unmarshal(rspMsg.GetBody(), upRsp); err != nil {
+		return err
+	}
 
-// 	if upRsp.Code != p.RC_OK {
-// 		return errors.New(upRsp.Msg)
-// 	}
+	if upRsp.Code != p.RC_OK {
+		return errors.New(upRsp.Msg)
+	}
 
-// 	return nil
-// }
+	return nil
+}
 
-type _Publish struct {
-	Id     uint32 `json:"id"`
-	Name   string `json:"name"`
-	Type   uint16 `json:"type"`
-	Ip     string `json:"ip"`
-	Weight int    `json:"weight"`
+// SetWeight 调整默认服务权重
+func SetWeight(w int) error {
+	return srv.SetWeight(w)
 }
 
-func publish() error {
-	pub := &_Publish{}
-	pub.Id = srv.Id()
-	pub.Name = srv.Name()
-	pub.Type = srv.Type()
-	pub.Weight = srv.Weight()
-	pub.Ip = srv.Ip()
-	key := fmt.Sprintf("%vcore_%v", srv.ServiceDir(), srv.Id())
+// SetWeight 运行时调整权重, 重新发布注册信息并通知所有已连接网关
+func (s *Service) SetWeight(w int) error {
+	if w < 0 {
+		return errors.New("bad weight")
+	}
+
+	if s.Discovery() == nil {
+		return errors.New("please init first")
+	}
+
+	s.Lock()
+	old := s.weight
+	s.weight = w
+	s.Unlock()
+
+	logs.Infof("Node weight: [%v -> %v]", old, w)
+
+	var lastErr error
+	if err := s.publish(); err != nil {
+		logs.Errorf("Republish err: %s", err.Error())
+		lastErr = err
+	}
+
+	for _, so := range s.gwList.GetAll() {
+		if so == nil {
+			continue
+		}
+		if err := s.update(so); err != nil {
+			logs.Errorf("- %s - Update weight err: %s", so.GetConn().RemoteAddr().String(), err.Error())
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
+
+// Drain 默认服务摘流
+func Drain(wait time.Duration) error {
+	return srv.Drain(wait)
+}
+
+// Drain 将权重置零使网关不再路由新请求, 并等待wait时长让存量请求处理完毕
+func (s *Service) Drain(wait time.Duration) error {
+	err := s.SetWeight(0)
+	time.Sleep(wait)
+	return err
+}
+
+// GracefulStop 默认服务摘流后停止
+func GracefulStop(wait time.Duration) {
+	srv.GracefulStop(wait)
+}
+
+// GracefulStop 摘流后停止服务
+func (s *Service) GracefulStop(wait time.Duration) {
+	if err := s.Drain(wait); err != nil {
+		logs.Errorf("Drain err: %s", err.Error())
+	}
+
+	s.Stop()
+}
+
+// instanceKey 实例注册key, 默认服务沿用<dir>core_<id>, 运维工具可直接修改该key调整权重;
+// NewService创建的服务附加服务类型(<dir>core_<type>_<id>), 以区分同一进程内的多个服务身份
+func (s *Service) instanceKey() string {
+	if s == srv {
+		return fmt.Sprintf("%vcore_%v", s.ServiceDir(), s.Id())
+	}
+
+	return fmt.Sprintf("%vcore_%v_%v", s.ServiceDir(), s.Type(), s.Id())
+}
+
+func (s *Service) publish() error {
+	pub := &Instance{}
+	pub.Id = s.Id()
+	pub.Name = s.Name()
+	pub.Type = s.Type()
+	pub.Weight = s.Weight()
+	pub.Ip = s.Ip()
+	pub.Port = s.Port()
+	pub.Owner = s.Owner()
+	pub.Version = s.Version()
+	pub.Commit = s.Commit()
+	pub.Region = s.Region()
+	pub.Zone = s.Zone()
+	pub.Functions = s.router.GetHandlerIDs()
+	pub.StartTime = s.StartTime()
+	pub.Labels = s.Labels()
+	key := s.instanceKey()
 
 	body, _ := json.Marshal(pub)
-	if err := srv.EtcdConn().SetKvAndKeepAlive(key, string(body), DEFAULT_ETCD_EXPIRE, DEFAULT_ETCD_PERIOD); err != nil {
+	if err := s.Discovery().Register(key, string(body)); err != nil {
 		return err
 	}
 
-	// go watch()
-
-	logs.Debugf("Publish to etcd: [key=%v,value=%v]", key, string(body))
+	logs.Debugf("Publish service: [key=%v,value=%v]", key, string(body))
 	return nil
 }
 
-func revoke() error {
-	key := fmt.Sprintf("%vcore_%v", srv.ServiceDir(), srv.Id())
-
-	return srv.EtcdConn().Del(key)
-}
-
-// func watch() {
-// 	key := fmt.Sprintf("%vcore_%v", srv.ServiceDir(), srv.Id())
-// 	rch := srv.EtcdConn().WatchKeyWithPrefix(key)
-
-// 	for wresp := range rch {
-// 		if wresp.Canceled {
-// 			logs.Infof("Watch %v canceled", key)
-// 			return
-// 		}
-
-// 		if err := wresp.Err(); err != nil {
-// 			logs.Errorf("Watch %v err: %s", key, err.Error())
-// 		}
-
-// 		for _, ev := range wresp.Events {
-// 			switch ev.Type.String() {
-// 			case "PUT":
-// 				logs.Infof("Add ETCD [key:%s,value:%s]", string(ev.Kv.Key), string(ev.Kv.Value))
-// 				pub := &_Publish{}
-// 				if err := json.Unmarshal(ev.Kv.Value, pub); err != nil {
-// 					logs.Errorf(err.Error())
-// 					continue
-// 				}
-
-// 				conns := gwList.GetAll()
-// 				for _, conn := range conns {
-// 					if conn == nil {
-// 						continue
-// 					}
-// 					if err := update(conn); err != nil {
-// 						logs.Errorf("Update weight err: %s", err.Error())
-// 					}
-// 				}
-// 			case "DELETE":
-// 				logs.Infof("Delete ETCD [key:%s,value:%s]", string(ev.Kv.Key), string(ev.Kv.Value))
-// 			}
-// 		}
-// 	}
-// }
+// checkCollision 注册前检查实例key是否已被其他实例持有, key仍在租约内即拒绝启动,
+// 异常退出的实例需等待其租约过期, 正常Stop会注销key
+func (s *Service) checkCollision() error {
+	key := s.instanceKey()
+
+	value, err := s.Discovery().Get(key)
+	if err == discovery.ErrNotSupported {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if value == "" {
+		return nil
+	}
+
+	old := &Instance{}
+	if err := json.Unmarshal([]byte(value), old); err != nil {
+		logs.Errorf("Decode instance err: %v, value: %v", err.Error(), value)
+		return nil
+	}
+
+	if old.Owner == s.Owner() {
+		return nil
+	}
+
+	return fmt.Errorf("service id %v collides with instance %v(%v:%v, owner %v)", s.Id(), old.Name, old.Ip, old.Port, old.Owner)
+}
+
+func (s *Service) revoke() error {
+	key := s.instanceKey()
+
+	return s.Discovery().Deregister(key)
+}
+
+// watch 监听本节点注册信息, 外部修改权重时同步到所有网关
+func (s *Service) watch() {
+	key := s.instanceKey()
+
+	err := s.Discovery().WatchKey(key, func(value string) {
+		pub := &Instance{}
+		if err := json.Unmarshal([]byte(value), pub); err != nil {
+			logs.Errorf(err.Error())
+			return
+		}
+
+		if pub.Weight == s.Weight() {
+			return
+		}
+
+		logs.Infof("Weight changed by discovery: [key:%s,value:%s]", key, value)
+		if err := s.SetWeight(pub.Weight); err != nil {
+			logs.Errorf("Update weight err: %s", err.Error())
+		}
+	})
+
+	if err != nil {
+		logs.Errorf("Watch %v err: %s", key, err.Error())
+	}
+}
@@ -0,0 +1,170 @@
+package core
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/kkkkiven/fishpkg/servicesdk/pkg/discovery"
+	p "github.com/kkkkiven/fishpkg/sprotocol/core"
+)
+
+// _MemDiscovery 内存服务发现, 模拟共享的ETCD目录
+type _MemDiscovery struct {
+	mu sync.Mutex
+	kv map[string]string
+}
+
+func newMemDiscovery() *_MemDiscovery {
+	return &_MemDiscovery{kv: make(map[string]string, 0)}
+}
+
+func (this *_MemDiscovery) Register(key, value string) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	this.kv[key] = value
+	return nil
+}
+
+func (this *_MemDiscovery) Deregister(key string) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	delete(this.kv, key)
+	return nil
+}
+
+func (this *_MemDiscovery) WatchKey(key string, onPut func(value string)) error {
+	return nil
+}
+
+func (this *_MemDiscovery) Get(key string) (string, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	return this.kv[key], nil
+}
+
+func (this *_MemDiscovery) List(prefix string) ([]string, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	var values []string
+	for k, v := range this.kv {
+		if strings.HasPrefix(k, prefix) {
+			values = append(values, v)
+		}
+	}
+
+	return values, nil
+}
+
+func (this *_MemDiscovery) WatchGateway(onAdd, onDel func(addr string)) error {
+	return nil
+}
+
+func (this *_MemDiscovery) Close() error {
+	return nil
+}
+
+var _ discovery.Discovery = (*_MemDiscovery)(nil)
+
+func newTestService(d discovery.Discovery, typ uint16, name string) *Service {
+	s := newService(p.NewRouter())
+	s.typ = typ
+	s.name = name
+	s.ip = "10.0.0.1"
+	s.id = 167772161
+	s.serviceDir = DEFAULT_SERVICE_DIR
+	s.owner = newOwner()
+	s.discovery = d
+	return s
+}
+
+func TestMultipleServicesRegisterAndRevoke(t *testing.T) {
+	d := newMemDiscovery()
+	rank := newTestService(d, 20, "rank")
+	mail := newTestService(d, 21, "mail")
+
+	if rank.instanceKey() == mail.instanceKey() {
+		t.Fatalf("services share key %v", rank.instanceKey())
+	}
+
+	for _, s := range []*Service{rank, mail} {
+		if err := s.Start(); err != nil {
+			t.Fatalf("start %v: %v", s.Name(), err)
+		}
+	}
+
+	all, err := rank.GetInstances(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all) != 2 {
+		t.Fatalf("got %d instances, want 2", len(all))
+	}
+
+	mail.Stop()
+
+	if v, _ := d.Get(mail.instanceKey()); v != "" {
+		t.Errorf("mail is still registered: %v", v)
+	}
+	if v, _ := d.Get(rank.instanceKey()); v == "" {
+		t.Errorf("rank was deregistered by mail.Stop")
+	}
+
+	list, err := rank.GetInstances(20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].Name != "rank" {
+		t.Errorf("got %+v, want only rank", list)
+	}
+}
+
+func TestDefaultServiceKeepsInstanceKey(t *testing.T) {
+	want := fmt.Sprintf("%vcore_%v", srv.ServiceDir(), srv.Id())
+	if key := srv.instanceKey(); key != want {
+		t.Errorf("default service key = %v, want %v", key, want)
+	}
+
+	extra := newTestService(newMemDiscovery(), 20, "rank")
+	if key := extra.instanceKey(); !strings.HasPrefix(key, DEFAULT_SERVICE_DIR+"core_20_") {
+		t.Errorf("extra service key = %v, want the type in it", key)
+	}
+}
+
+func TestCollisionRejectsOtherOwner(t *testing.T) {
+	d := newMemDiscovery()
+	first := newTestService(d, 20, "rank")
+	if err := first.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 同一主机上的另一个进程, IP模式下ID与端口均相同
+	second := newTestService(d, 20, "rank")
+	if err := second.Start(); err == nil {
+		t.Fatal("second instance with the same id was accepted")
+	}
+
+	// 本实例重复注册不视为冲突
+	if err := first.checkCollision(); err != nil {
+		t.Fatalf("own registration reported as collision: %v", err)
+	}
+
+	first.Stop()
+	if err := second.Start(); err != nil {
+		t.Fatalf("start after the first instance stopped: %v", err)
+	}
+}
+
+func TestIpPortIdUsesFullAddress(t *testing.T) {
+	if ipPortId("10.1.0.1", 8080) == ipPortId("10.2.0.1", 8080) {
+		t.Error("hosts in different /16s got the same id")
+	}
+	if ipPortId("10.1.0.1", 8080) != ipPortId("10.1.0.1", 8080) {
+		t.Error("id isn't stable")
+	}
+}
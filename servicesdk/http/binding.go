@@ -0,0 +1,416 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/kkkkiven/fishpkg/servicesdk/http/response"
+	"github.com/kkkkiven/fishpkg/util"
+	"github.com/kkkkiven/fishpkg/utils"
+
+	json "github.com/json-iterator/go"
+)
+
+const DEFAULT_MAX_MEMORY = 32 << 20 // multipart表单内存上限
+
+// 校验规则
+const (
+	RULE_REQUIRED = "required"
+	RULE_PHONE    = "phone"    // 中国大陆手机号
+	RULE_EMAIL    = "email"    // 邮箱
+	RULE_IDCARD   = "idcard"   // 18位身份证号
+	RULE_USERNAME = "username" // 用户名, 4-22位字母数字下划线
+	RULE_PASSWORD = "password" // 密码
+	RULE_URL      = "url"      // 网址
+	RULE_NUMBER   = "number"   // 纯数字字符串
+	RULE_MIN      = "min"      // 数值下限或字符串最小长度, 如min=1
+	RULE_MAX      = "max"      // 数值上限或字符串最大长度, 如max=20
+	RULE_LEN      = "len"      // 字符串长度或切片元素数, 如len=6
+	RULE_ONEOF    = "oneof"    // 枚举值, 以空格分隔, 如oneof=ios android
+	RULE_REGEXP   = "regexp"   // 正则, 须为最后一条规则, 如regexp=^[a-z]+$
+	RULE_TYPE     = "type"     // 类型转换失败
+	RULE_JSON     = "json"     // JSON请求体解码失败
+)
+
+// BindError 单个字段的参数错误
+type BindError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Msg   string `json:"msg"`
+}
+
+func (e *BindError) Error() string {
+	if e.Field == "" {
+		return e.Msg
+	}
+
+	return e.Field + ": " + e.Msg
+}
+
+// BindErrors 参数错误列表
+type BindErrors []*BindError
+
+func (es BindErrors) Error() string {
+	msgs := make([]string, 0, len(es))
+	for _, e := range es {
+		msgs = append(msgs, e.Error())
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+// Bind 将JSON请求体、表单、query及路径参数依次解码到obj(结构体指针), 后者覆盖前者, 然后按validate标签校验.
+// 字段通过path、query、form、json标签指定来源, 校验失败时返回BindErrors
+func Bind(r *http.Request, ps Params, obj interface{}) error {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("bind: obj must be a pointer to struct, got %T", obj)
+	}
+
+	var errs BindErrors
+
+	ct, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch ct {
+	case "application/json":
+		if r.Body != nil && r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(obj); err != nil {
+				return BindErrors{{Rule: RULE_JSON, Msg: err.Error()}}
+			}
+		}
+	case "multipart/form-data":
+		if err := r.ParseMultipartForm(DEFAULT_MAX_MEMORY); err != nil {
+			return BindErrors{{Rule: RULE_TYPE, Msg: err.Error()}}
+		}
+	default:
+		if err := r.ParseForm(); err != nil {
+			return BindErrors{{Rule: RULE_TYPE, Msg: err.Error()}}
+		}
+	}
+
+	query := r.URL.Query()
+	eachField(v.Elem(), func(f reflect.StructField, fv reflect.Value) {
+		var (
+			name   string
+			values []string
+		)
+
+		if name = tagName(f, "path"); name != "" {
+			if val := ps.ByName(name); val != "" {
+				values = []string{val}
+			}
+		} else if name = tagName(f, "query"); name != "" {
+			values = query[name]
+		} else if name = tagName(f, "form"); name != "" {
+			values = r.Form[name]
+		}
+
+		if len(values) == 0 {
+			return
+		}
+
+		if err := setValue(fv, values); err != nil {
+			errs = append(errs, &BindError{Field: name, Rule: RULE_TYPE, Msg: err.Error()})
+		}
+	})
+
+	if len(errs) != 0 {
+		return errs
+	}
+
+	return Validate(obj)
+}
+
+// Validate 按validate标签校验结构体, 多条规则以逗号分隔, 如validate:"required,phone"
+func Validate(obj interface{}) error {
+	v := reflect.Indirect(reflect.ValueOf(obj))
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+
+	var errs BindErrors
+	validateStruct(v, "", &errs)
+	if len(errs) != 0 {
+		return errs
+	}
+
+	return nil
+}
+
+func validateStruct(v reflect.Value, prefix string, errs *BindErrors) {
+	eachField(v, func(f reflect.StructField, fv reflect.Value) {
+		name := prefix + fieldName(f)
+
+		if tag := f.Tag.Get("validate"); tag != "" && tag != "-" {
+			if err := validateField(fv, tag); err != nil {
+				err.Field = name
+				*errs = append(*errs, err)
+				return
+			}
+		}
+
+		// 校验嵌套结构体
+		if ev := reflect.Indirect(fv); ev.Kind() == reflect.Struct && ev.Type() != timeType {
+			validateStruct(ev, name+".", errs)
+		}
+	})
+}
+
+// validateField 依次校验规则, 返回第一条不满足的规则, 非必填字段为零值时跳过其余规则
+func validateField(v reflect.Value, tag string) *BindError {
+	rules := splitRules(tag)
+
+	for _, rule := range rules {
+		if rule == RULE_REQUIRED && isZero(v) {
+			return &BindError{Rule: RULE_REQUIRED, Msg: "is required"}
+		}
+	}
+
+	if isZero(v) {
+		return nil
+	}
+	v = reflect.Indirect(v)
+
+	for _, rule := range rules {
+		key, arg := rule, ""
+		if i := strings.IndexByte(rule, '='); i > 0 {
+			key, arg = rule[:i], rule[i+1:]
+		}
+
+		if msg := checkRule(v, key, arg); msg != "" {
+			return &BindError{Rule: key, Msg: msg}
+		}
+	}
+
+	return nil
+}
+
+// splitRules 以逗号分隔规则, regexp规则取剩余全部内容
+func splitRules(tag string) []string {
+	var rules []string
+	for tag != "" {
+		if strings.HasPrefix(tag, RULE_REGEXP+"=") {
+			return append(rules, tag)
+		}
+
+		i := strings.IndexByte(tag, ',')
+		if i < 0 {
+			return append(rules, tag)
+		}
+		rules = append(rules, tag[:i])
+		tag = tag[i+1:]
+	}
+
+	return rules
+}
+
+// checkRule 校验单条规则, 不满足时返回错误信息
+func checkRule(v reflect.Value, key, arg string) string {
+	s := fmt.Sprint(v.Interface())
+
+	switch key {
+	case RULE_REQUIRED:
+	case RULE_PHONE:
+		if !util.IsChinaMobile(s) {
+			return "must be a valid phone number"
+		}
+	case RULE_EMAIL:
+		if !utils.CheckEmail(s) {
+			return "must be a valid email"
+		}
+	case RULE_IDCARD:
+		if !util.IsIDCard(s) {
+			return "must be a valid ID card number"
+		}
+	case RULE_USERNAME:
+		if !utils.CheckUserName(s) {
+			return "must be 4-22 letters, digits or underscores"
+		}
+	case RULE_PASSWORD:
+		if !utils.CheckPwd(s) {
+			return "must be a valid password"
+		}
+	case RULE_URL:
+		if !utils.CheckUrl(s) {
+			return "must be a valid url"
+		}
+	case RULE_NUMBER:
+		if !util.IsLongNumStr(s) {
+			return "must be a number"
+		}
+	case RULE_MIN, RULE_MAX, RULE_LEN:
+		return checkSize(v, key, arg)
+	case RULE_ONEOF:
+		for _, opt := range strings.Fields(arg) {
+			if s == opt {
+				return ""
+			}
+		}
+		return "must be one of [" + arg + "]"
+	case RULE_REGEXP:
+		if !util.RegexpOK(arg, s) {
+			return "must match " + arg
+		}
+	default:
+		return "unknown rule " + key
+	}
+
+	return ""
+}
+
+// checkSize 数值比较大小, 字符串比较字符数, 切片与map比较元素数
+func checkSize(v reflect.Value, key, arg string) string {
+	limit, err := strconv.ParseFloat(arg, 64)
+	if err != nil {
+		return "bad rule " + key + "=" + arg
+	}
+
+	var (
+		n    float64
+		unit string
+	)
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n = float64(v.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n = float64(v.Uint())
+	case reflect.Float32, reflect.Float64:
+		n = v.Float()
+	case reflect.String:
+		n, unit = float64(utf8.RuneCountInString(v.String())), " characters"
+	case reflect.Slice, reflect.Array, reflect.Map:
+		n, unit = float64(v.Len()), " items"
+	default:
+		return ""
+	}
+
+	switch {
+	case key == RULE_MIN && n < limit:
+		return "must be at least " + arg + unit
+	case key == RULE_MAX && n > limit:
+		return "must be at most " + arg + unit
+	case key == RULE_LEN && n != limit:
+		return "must be " + arg + unit
+	}
+
+	return ""
+}
+
+// eachField 遍历可设置的导出字段, 展开匿名嵌入的结构体
+func eachField(v reflect.Value, fn func(reflect.StructField, reflect.Value)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		fv := v.Field(i)
+
+		if f.Anonymous && f.Tag.Get("json") == "" && indirect(f.Type).Kind() == reflect.Struct {
+			if fv.Kind() == reflect.Ptr {
+				if fv.IsNil() {
+					if !fv.CanSet() {
+						continue
+					}
+					fv.Set(reflect.New(f.Type.Elem()))
+				}
+				fv = fv.Elem()
+			}
+			eachField(fv, fn)
+			continue
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+
+		fn(f, fv)
+	}
+}
+
+// setValue 将字符串值转换为字段类型, 切片字段接收全部值
+func setValue(v reflect.Value, values []string) error {
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		return setValue(v.Elem(), values)
+	}
+
+	if v.Kind() == reflect.Slice {
+		s := reflect.MakeSlice(v.Type(), len(values), len(values))
+		for i, val := range values {
+			if err := setValue(s.Index(i), []string{val}); err != nil {
+				return err
+			}
+		}
+		v.Set(s)
+		return nil
+	}
+
+	val := values[0]
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(val)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(val)
+		if err != nil {
+			return fmt.Errorf("must be a bool")
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(val, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(val, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be an unsigned integer")
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(val, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("must be a number")
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported type %v", v.Type())
+	}
+
+	return nil
+}
+
+// fieldName 错误信息中的字段名, 依次取path、query、form、json标签
+func fieldName(f reflect.StructField) string {
+	for _, key := range []string{"path", "query", "form"} {
+		if name := tagName(f, key); name != "" {
+			return name
+		}
+	}
+
+	return jsonName(f)
+}
+
+func isZero(v reflect.Value) bool {
+	return !v.IsValid() || v.IsZero()
+}
+
+// WriteBindError 以ErrParamInvalid响应参数错误, data为错误列表, 格式由response.Negotiate协商
+func WriteBindError(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	errs, ok := err.(BindErrors)
+	if !ok {
+		errs = BindErrors{{Msg: err.Error()}}
+	}
+
+	msg := "invalid param"
+	if len(errs) != 0 && errs[0].Field != "" {
+		msg += ": " + errs[0].Field
+	}
+
+	response.Write(ctx, w, r, errs, response.ErrParamInvalid.WithMsg(msg))
+}
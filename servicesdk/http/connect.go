@@ -57,7 +57,7 @@ func (this *_GWList) GetAll() []*p.Socket {
 
 func (this *_GWList) Del(key string) {
 	this.Lock()
-	this.Unlock()
+	defer this.Unlock()
 
 	gw, ok := this.m[key]
 	if !ok {
@@ -75,10 +75,45 @@ func (this *_GWList) Del(key string) {
 	delete(this.m, key)
 }
 
+// Evict 移除未响应心跳的网关连接并重连, 网关已移除或so已被替换时仅关闭
+func (this *_GWList) Evict(key string, so *p.Socket) {
+	this.Lock()
+	defer this.Unlock()
+
+	gw, ok := this.m[key]
+	if !ok || gw.status != _GW_STATUS_RUNNING || gw.so != so {
+		so.Close()
+		return
+	}
+
+	logs.Waringf("Gateway[%v] missed %d pongs, reconnecting", key, so.RTT().Missed)
+	this.restart(key)
+}
+
+// RTT 获取各网关连接的往返时延统计
+func (this *_GWList) RTT() map[string]p.RTTStats {
+	this.RLock()
+	defer this.RUnlock()
+
+	stats := make(map[string]p.RTTStats, len(this.m))
+	for k, v := range this.m {
+		if v.status == _GW_STATUS_RUNNING {
+			stats[k] = v.so.RTT()
+		}
+	}
+
+	return stats
+}
+
 func (this *_GWList) Add(key string) {
 	this.Lock()
 	defer this.Unlock()
 
+	this.restart(key)
+}
+
+// restart 关闭已有连接并重新连接网关, 调用方需持有锁
+func (this *_GWList) restart(key string) {
 	gw, ok := this.m[key]
 	if !ok {
 		gw = &_GWContext{}
@@ -181,27 +216,34 @@ func dialGateway(ips []string) error {
 	return nil
 }
 
-func fetchGateway() error {
-	var (
-		keys []string
-		err  error
-	)
+func addGateway(addr string) {
+	if HostAddrCheck(addr) == false {
+		logs.Errorf("Bad gateway address: %s", addr)
+		return
+	}
 
-	pfx := srv.GatewayDir()
+	gwList.Add(addr)
+	logs.Debugf("Add gateway[%v] to pool", addr)
+}
 
-	if keys, _, err = srv.EtcdConn().GetKvWithPrefix(pfx); err != nil {
-		return err
+func delGateway(addr string) {
+	if HostAddrCheck(addr) == false {
+		logs.Errorf("Bad gateway address: %s", addr)
+		return
 	}
 
-	for _, k := range keys {
-		key := strings.TrimPrefix(k, pfx)
-		if HostAddrCheck(key) == false {
-			logs.Errorf("Bad gateway address: %s", key)
-			continue
-		}
+	gwList.Del(addr)
+	logs.Debugf("Delete gateway[%v] from pool", addr)
+}
 
-		gwList.Add(key)
-		logs.Debugf("Add gateway[%v] to pool", key)
+// GatewayRTT 获取已连接网关的往返时延统计, key为网关地址
+func GatewayRTT() map[string]p.RTTStats {
+	return gwList.RTT()
+}
+
+func fetchGateway() error {
+	if err := srv.Discovery().WatchGateway(addGateway, delGateway); err != nil {
+		return err
 	}
 
 	monitor := func() {
@@ -215,55 +257,5 @@ func fetchGateway() error {
 
 	go monitor()
 
-	go watchGateway()
-
 	return nil
 }
-
-func watchGateway() {
-	pfx := srv.GatewayDir()
-
-	for {
-		time.Sleep(1 * time.Second)
-		rch := srv.EtcdConn().WatchKeyWithPrefix(pfx)
-		logs.Debugf("Wath: %v", pfx)
-		for wresp := range rch {
-			if wresp.Canceled {
-				logs.Infof("Watch %v canceled", pfx)
-				break
-			}
-
-			if err := wresp.Err(); err != nil {
-				logs.Errorf("Watch %v err: %s", pfx, err.Error())
-				break
-			}
-
-			for _, ev := range wresp.Events {
-				switch ev.Type.String() {
-				case "PUT":
-					logs.Infof("Add ETCD [key:%s,value:%s]", string(ev.Kv.Key), string(ev.Kv.Value))
-					key := strings.TrimPrefix(string(ev.Kv.Key), pfx)
-
-					if HostAddrCheck(key) == false {
-						logs.Errorf("Bad gateway address: %s", key)
-						continue
-					}
-
-					gwList.Add(key)
-					logs.Debugf("Add gateway[%v] to pool", key)
-				case "DELETE":
-					logs.Infof("Delete ETCD [key:%s,value:%s]", string(ev.Kv.Key), string(ev.Kv.Value))
-					key := strings.TrimPrefix(string(ev.Kv.Key), pfx)
-
-					if HostAddrCheck(key) == false {
-						logs.Errorf("Bad gateway address: %s", key)
-						continue
-					}
-
-					gwList.Del(key)
-					logs.Debugf("Delete gateway[%v] from pool", key)
-				}
-			}
-		}
-	}
-}
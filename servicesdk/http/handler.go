@@ -3,6 +3,7 @@ package http
 import (
 	"context"
 	"net/http"
+	"strings"
 	"unsafe"
 
 	p "github.com/kkkkiven/fishpkg/sprotocol/http"
@@ -24,14 +25,24 @@ func (ps Params) ByName(name string) string {
 	return ""
 }
 
-// AddHandler 注册路由与处理函数
+// AddHandler 注册路由与处理函数, 匹配全部请求方法
 func AddHandler(path, desc string, fn func(context.Context, http.ResponseWriter, *http.Request, Params), groups ...string) {
-	fp := *(*p.HandlerFunc)(unsafe.Pointer(&fn))
-	p.AddHandler(path, fp, groups...)
+	Handle("", path, desc, fn, SetGroups(groups...))
+}
+
+// Handle 注册路由与处理函数, method为空时匹配全部请求方法, 可附加中间件分组、请求与响应结构、标签及认证方式, 用于生成OpenAPI文档
+func Handle(method, path, desc string, fn func(context.Context, http.ResponseWriter, *http.Request, Params), opts ...ifaceOption) {
 	iface := _Iface{
-		Path: path,
-		Desc: desc,
+		Method: strings.ToUpper(method),
+		Path:   path,
+		Desc:   desc,
 	}
+	for _, opt := range opts {
+		opt(&iface)
+	}
+
+	fp := *(*p.HandlerFunc)(unsafe.Pointer(&fn))
+	p.Handle(method, path, fp, iface.groups...)
 	srv.AddIface(iface)
 }
 
@@ -0,0 +1,17 @@
+package http
+
+import (
+	"net/http"
+
+	p "github.com/kkkkiven/fishpkg/sprotocol/http"
+)
+
+// LocalHandler 本地模式handler, 可挂载到任意net/http服务或用于httptest
+func LocalHandler() http.Handler {
+	return p.NewLocalHandler()
+}
+
+// ServeLocal 本地模式, 不连接网关, 直接以net/http在addr上提供AddHandler注册的接口, 阻塞直到服务退出
+func ServeLocal(addr string) error {
+	return http.ListenAndServe(addr, LocalHandler())
+}
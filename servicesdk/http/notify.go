@@ -1,7 +1,6 @@
 package http
 
 import (
-	"encoding/binary"
 	"time"
 
 	"github.com/kkkkiven/fishpkg/logs"
@@ -9,7 +8,6 @@ import (
 )
 
 type Notify struct {
-	ping int
 }
 
 // 关闭tcp连接回调
@@ -23,25 +21,31 @@ func (this *Notify) OnClose(so *p.Socket) {
 
 }
 
-// 读取数据超时回调
+// 读取数据超时回调, 连接空闲时发送ping, 连续未收到pong时移除网关并重连
 func (this *Notify) OnTimeout(so *p.Socket) {
 	go func() {
-		buf := make([]byte, 8)
-		binary.BigEndian.PutUint64(buf, uint64(time.Now().UnixNano()))
-		msg := p.NewPingMessage()
-		msg.SetBody(buf)
-		rsp, err := so.Send(msg)
-		if rsp != nil {
-			this.ping = 0
-			logs.Tracef("- %s - Send PING message", so.GetConn().RemoteAddr().String())
+		rtt, err := so.Ping()
+		if err == p.ErrPingPending {
 			return
 		}
 
-		this.ping++
-		logs.Errorf("- %v - Send PING message failed, err:%s", so.GetConn().RemoteAddr().String(), err.Error())
+		if err == nil {
+			logs.Tracef("- %s - Send PING message, rtt: %v", so.GetConn().RemoteAddr().String(), rtt)
+			return
+		}
+
+		missed := so.RTT().Missed
+		logs.Errorf("- %v - Send PING message failed(%d), err:%s", so.GetConn().RemoteAddr().String(), missed, err.Error())
+
+		if missed < p.DEFAULT_PING_MISSES {
+			return
+		}
 
-		if this.ping > 2 {
-			so.Close()
+		if key, ok := so.GetContext().(string); ok {
+			gwList.Evict(key, so)
+			return
 		}
+
+		so.Close()
 	}()
 }
@@ -0,0 +1,452 @@
+package http
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	p "github.com/kkkkiven/fishpkg/sprotocol/http"
+
+	json "github.com/json-iterator/go"
+)
+
+const (
+	OPENAPI_VERSION = "3.0.3"
+	OPENAPI_PATH    = "/openapi.json" // 文档路由为/<服务名>/openapi.json
+
+	DEFAULT_API_VERSION = "1.0.0"
+)
+
+// 内置认证方式
+const (
+	AUTH_BEARER = "bearer"
+)
+
+var timeType = reflect.TypeOf(time.Time{})
+
+type ifaceOption func(*_Iface)
+
+// SetGroups 设置中间件分组
+func SetGroups(groups ...string) ifaceOption {
+	return func(i *_Iface) {
+		i.groups = append(i.groups, groups...)
+	}
+}
+
+// SetReqSchema 设置请求结构, 字段按path、query、form、json标签生成参数与请求体, desc标签为字段说明,
+// validate标签包含required时为必填
+func SetReqSchema(v interface{}) ifaceOption {
+	return func(i *_Iface) {
+		i.req = reflect.TypeOf(v)
+	}
+}
+
+// SetRspSchema 设置响应结构, 以application/json生成200响应
+func SetRspSchema(v interface{}) ifaceOption {
+	return func(i *_Iface) {
+		i.rsp = reflect.TypeOf(v)
+	}
+}
+
+// SetTags 设置文档标签
+func SetTags(tags ...string) ifaceOption {
+	return func(i *_Iface) {
+		i.Tags = append(i.Tags, tags...)
+	}
+}
+
+// SetAuth 设置认证方式, 满足其一即可
+func SetAuth(schemes ...string) ifaceOption {
+	return func(i *_Iface) {
+		i.Auth = append(i.Auth, schemes...)
+	}
+}
+
+// SecurityScheme OpenAPI认证方式
+type SecurityScheme struct {
+	Type         string `json:"type"` // http, apiKey
+	Scheme       string `json:"scheme,omitempty"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+	In           string `json:"in,omitempty"` // header, query, cookie
+	Name         string `json:"name,omitempty"`
+	Description  string `json:"description,omitempty"`
+}
+
+var (
+	schemeMu sync.RWMutex
+	schemes  = map[string]*SecurityScheme{
+		AUTH_BEARER: {Type: "http", Scheme: "bearer"},
+	}
+)
+
+// AddSecurityScheme 注册认证方式
+func AddSecurityScheme(name string, scheme *SecurityScheme) {
+	schemeMu.Lock()
+	defer schemeMu.Unlock()
+
+	schemes[name] = scheme
+}
+
+type _OpenAPI struct {
+	OpenAPI    string                              `json:"openapi"`
+	Info       _OAInfo                             `json:"info"`
+	Paths      map[string]map[string]*_OAOperation `json:"paths"`
+	Components *_OAComponents                      `json:"components,omitempty"`
+}
+
+type _OAInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type _OAOperation struct {
+	Summary     string                `json:"summary,omitempty"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []*_OAParameter       `json:"parameters,omitempty"`
+	RequestBody *_OABody              `json:"requestBody,omitempty"`
+	Responses   map[string]*_OABody   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type _OAParameter struct {
+	Name        string     `json:"name"`
+	In          string     `json:"in"`
+	Required    bool       `json:"required,omitempty"`
+	Description string     `json:"description,omitempty"`
+	Schema      *_OASchema `json:"schema"`
+}
+
+// _OABody 请求体或响应
+type _OABody struct {
+	Description string               `json:"description,omitempty"`
+	Required    bool                 `json:"required,omitempty"`
+	Content     map[string]*_OAMedia `json:"content,omitempty"`
+}
+
+type _OAMedia struct {
+	Schema *_OASchema `json:"schema"`
+}
+
+type _OASchema struct {
+	Ref                  string                `json:"$ref,omitempty"`
+	Type                 string                `json:"type,omitempty"`
+	Format               string                `json:"format,omitempty"`
+	Description          string                `json:"description,omitempty"`
+	Items                *_OASchema            `json:"items,omitempty"`
+	Properties           map[string]*_OASchema `json:"properties,omitempty"`
+	AdditionalProperties *_OASchema            `json:"additionalProperties,omitempty"`
+	Required             []string              `json:"required,omitempty"`
+}
+
+type _OAComponents struct {
+	Schemas         map[string]*_OASchema      `json:"schemas,omitempty"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// OpenAPI 根据已注册的接口生成OpenAPI 3文档
+func OpenAPI() ([]byte, error) {
+	srv.RLock()
+	title := srv.name
+	version := srv.version
+	ifaces := make([]_Iface, len(srv.iface))
+	copy(ifaces, srv.iface)
+	srv.RUnlock()
+
+	if version == "" {
+		version = DEFAULT_API_VERSION
+	}
+
+	doc := &_OpenAPI{
+		OpenAPI: OPENAPI_VERSION,
+		Info:    _OAInfo{Title: title, Version: version},
+		Paths:   make(map[string]map[string]*_OAOperation, len(ifaces)),
+	}
+
+	gen := &_SchemaGen{schemas: make(map[string]*_OASchema, 0), names: make(map[reflect.Type]string, 0)}
+	auth := make(map[string]bool, 0)
+
+	for _, iface := range ifaces {
+		path, pathParams := openAPIPath(iface.Path)
+
+		// 未指定请求方法的接口以GET与POST描述
+		methods := []string{iface.Method}
+		if iface.Method == "" || iface.Method == p.METHOD_ANY {
+			methods = []string{http.MethodGet, http.MethodPost}
+		}
+
+		item := doc.Paths[path]
+		if item == nil {
+			item = make(map[string]*_OAOperation, len(methods))
+			doc.Paths[path] = item
+		}
+
+		for _, method := range methods {
+			op := gen.operation(method, &iface, pathParams)
+			for _, name := range iface.Auth {
+				op.Security = append(op.Security, map[string][]string{name: {}})
+				auth[name] = true
+			}
+			item[strings.ToLower(method)] = op
+		}
+	}
+
+	if len(gen.schemas) != 0 || len(auth) != 0 {
+		doc.Components = &_OAComponents{}
+		if len(gen.schemas) != 0 {
+			doc.Components.Schemas = gen.schemas
+		}
+
+		schemeMu.RLock()
+		for name := range auth {
+			if scheme, ok := schemes[name]; ok {
+				if doc.Components.SecuritySchemes == nil {
+					doc.Components.SecuritySchemes = make(map[string]*SecurityScheme, len(auth))
+				}
+				doc.Components.SecuritySchemes[name] = scheme
+			}
+		}
+		schemeMu.RUnlock()
+	}
+
+	return json.Marshal(doc)
+}
+
+// openAPIHandler 提供OpenAPI文档
+func openAPIHandler(ctx context.Context, w http.ResponseWriter, r *http.Request, _ p.Params) {
+	doc, err := OpenAPI()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(doc)
+}
+
+// openAPIPath 将":name"与"*name"形式的路由转换为"{name}", 并返回路径参数名
+func openAPIPath(path string) (string, []string) {
+	var params []string
+
+	segs := strings.Split(path, "/")
+	for i, seg := range segs {
+		if len(seg) > 1 && (seg[0] == ':' || seg[0] == '*') {
+			params = append(params, seg[1:])
+			segs[i] = "{" + seg[1:] + "}"
+		}
+	}
+
+	return strings.Join(segs, "/"), params
+}
+
+// _SchemaGen 由Go类型生成schema, 具名结构体放入components并以$ref引用
+type _SchemaGen struct {
+	schemas map[string]*_OASchema
+	names   map[reflect.Type]string
+}
+
+// operation 生成单个接口的文档
+func (g *_SchemaGen) operation(method string, iface *_Iface, pathParams []string) *_OAOperation {
+	op := &_OAOperation{
+		Summary:   iface.Desc,
+		Tags:      iface.Tags,
+		Responses: map[string]*_OABody{"200": {Description: http.StatusText(http.StatusOK)}},
+	}
+
+	params := make(map[string]*_OAParameter, len(pathParams))
+	for _, name := range pathParams {
+		param := &_OAParameter{Name: name, In: "path", Required: true, Schema: &_OASchema{Type: "string"}}
+		params[name] = param
+		op.Parameters = append(op.Parameters, param)
+	}
+
+	if t := indirect(iface.req); t != nil && t.Kind() == reflect.Struct {
+		body := &_OASchema{Type: "object", Properties: make(map[string]*_OASchema, 0)}
+		form := &_OASchema{Type: "object", Properties: make(map[string]*_OASchema, 0)}
+
+		g.walk(t, func(f reflect.StructField) {
+			desc := f.Tag.Get("desc")
+			required := strings.Contains(","+f.Tag.Get("validate")+",", ",required,")
+
+			switch {
+			case tagName(f, "path") != "":
+				name := tagName(f, "path")
+				if param, ok := params[name]; ok {
+					param.Schema = g.schemaOf(f.Type)
+					param.Description = desc
+				}
+			case tagName(f, "query") != "":
+				op.Parameters = append(op.Parameters, &_OAParameter{
+					Name:        tagName(f, "query"),
+					In:          "query",
+					Required:    required,
+					Description: desc,
+					Schema:      g.schemaOf(f.Type),
+				})
+			case tagName(f, "form") != "":
+				addProperty(form, tagName(f, "form"), g.schemaOf(f.Type), desc, required)
+			default:
+				if name := jsonName(f); name != "" {
+					addProperty(body, name, g.schemaOf(f.Type), desc, required)
+				}
+			}
+		})
+
+		if method != http.MethodGet && method != http.MethodHead {
+			content := make(map[string]*_OAMedia, 0)
+			if len(body.Properties) != 0 {
+				content["application/json"] = &_OAMedia{Schema: body}
+			}
+			if len(form.Properties) != 0 {
+				content["application/x-www-form-urlencoded"] = &_OAMedia{Schema: form}
+			}
+			if len(content) != 0 {
+				op.RequestBody = &_OABody{Required: len(body.Required)+len(form.Required) != 0, Content: content}
+			}
+		}
+	}
+
+	if iface.rsp != nil {
+		op.Responses["200"].Content = map[string]*_OAMedia{"application/json": {Schema: g.schemaOf(iface.rsp)}}
+	}
+
+	return op
+}
+
+// walk 遍历结构体的导出字段, 展开匿名嵌入的结构体
+func (g *_SchemaGen) walk(t reflect.Type, fn func(reflect.StructField)) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.Anonymous && f.Tag.Get("json") == "" {
+			if et := indirect(f.Type); et.Kind() == reflect.Struct {
+				g.walk(et, fn)
+				continue
+			}
+		}
+
+		if f.PkgPath != "" {
+			continue
+		}
+
+		fn(f)
+	}
+}
+
+// schemaOf 生成类型对应的schema
+func (g *_SchemaGen) schemaOf(t reflect.Type) *_OASchema {
+	t = indirect(t)
+
+	if t == timeType {
+		return &_OASchema{Type: "string", Format: "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &_OASchema{Type: "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return &_OASchema{Type: "integer", Format: "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint, reflect.Uint32, reflect.Uint64:
+		return &_OASchema{Type: "integer", Format: "int64"}
+	case reflect.Float32:
+		return &_OASchema{Type: "number", Format: "float"}
+	case reflect.Float64:
+		return &_OASchema{Type: "number", Format: "double"}
+	case reflect.String:
+		return &_OASchema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &_OASchema{Type: "string", Format: "byte"}
+		}
+		return &_OASchema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &_OASchema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.structOf(t)
+		}
+		return &_OASchema{Ref: "#/components/schemas/" + g.define(t)}
+	}
+
+	return &_OASchema{}
+}
+
+// define 将具名结构体放入components, 同名类型以序号区分
+func (g *_SchemaGen) define(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	for i := 2; g.schemas[name] != nil; i++ {
+		name = t.Name() + strconv.Itoa(i)
+	}
+
+	// 先占位以支持递归类型
+	g.names[t] = name
+	g.schemas[name] = &_OASchema{}
+	*g.schemas[name] = *g.structOf(t)
+
+	return name
+}
+
+func (g *_SchemaGen) structOf(t reflect.Type) *_OASchema {
+	s := &_OASchema{Type: "object", Properties: make(map[string]*_OASchema, 0)}
+
+	g.walk(t, func(f reflect.StructField) {
+		if name := jsonName(f); name != "" {
+			required := strings.Contains(","+f.Tag.Get("validate")+",", ",required,")
+			addProperty(s, name, g.schemaOf(f.Type), f.Tag.Get("desc"), required)
+		}
+	})
+
+	return s
+}
+
+func addProperty(s *_OASchema, name string, prop *_OASchema, desc string, required bool) {
+	// $ref的同级属性会被忽略
+	if desc != "" && prop.Ref == "" {
+		prop.Description = desc
+	}
+
+	s.Properties[name] = prop
+	if required {
+		s.Required = append(s.Required, name)
+		sort.Strings(s.Required)
+	}
+}
+
+// jsonName 字段的json名称, 忽略的字段返回空
+func jsonName(f reflect.StructField) string {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return ""
+	}
+
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name
+	}
+
+	return f.Name
+}
+
+// tagName 标签中的名称
+func tagName(f reflect.StructField, key string) string {
+	tag := f.Tag.Get(key)
+	if tag == "-" {
+		return ""
+	}
+
+	return strings.Split(tag, ",")[0]
+}
+
+func indirect(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	return t
+}
@@ -0,0 +1,125 @@
+package response
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+// Error 业务错误, 携带错误码、提示信息及对应的HTTP状态码
+// 错误码定义格式与gamesdk一致:
+// xx          xx        xxx
+// 二位服务号   二位模块号 三位错误码
+type Error struct {
+	Code   int32
+	Msg    string
+	Status int
+}
+
+var (
+	mu     sync.RWMutex
+	codes  = make(map[int32]*Error, 0)
+	mapped = make(map[error]*Error, 0)
+)
+
+var (
+	OK              = New(0, "ok", http.StatusOK)
+	ErrUnauthorized = New(500401, "unauthorized request", http.StatusUnauthorized)
+	ErrForbidden    = New(500403, "forbidden", http.StatusForbidden)
+	ErrNotFound     = New(500404, "not found", http.StatusNotFound)
+	ErrUnknown      = New(501000, "unknown error", http.StatusInternalServerError)
+	ErrSystem       = New(501001, "system error", http.StatusInternalServerError)
+	ErrServer       = New(501002, "server disable", http.StatusServiceUnavailable)
+	ErrMedia        = New(501004, "unsupported media types", http.StatusUnsupportedMediaType)
+	ErrParamInvalid = New(501005, "invalid parameters, please refer to the document", http.StatusBadRequest)
+	ErrBusy         = New(501006, "system busy", http.StatusServiceUnavailable)
+	ErrTimeout      = New(501007, "time out", http.StatusGatewayTimeout)
+	ErrMissParam    = New(501008, "missing required parameters, please refer to the document", http.StatusBadRequest)
+	ErrBadMsg       = New(501009, "bad message", http.StatusBadRequest)
+	ErrRemoteSvr    = New(501010, "some errors occurred in the remote service", http.StatusBadGateway)
+	ErrTooMany      = New(501011, "too many requests", http.StatusTooManyRequests)
+)
+
+// New 定义错误码, status为0时使用200, 错误码重复时panic
+func New(code int32, msg string, status int) *Error {
+	if status == 0 {
+		status = http.StatusOK
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if _, ok := codes[code]; ok {
+		panic(fmt.Sprintf("response: duplicate error code %d", code))
+	}
+
+	e := &Error{Code: code, Msg: msg, Status: status}
+	codes[code] = e
+
+	return e
+}
+
+// Code 根据错误码获取定义
+func Code(code int32) (*Error, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	e, ok := codes[code]
+	return e, ok
+}
+
+// Register 将已有的错误值(如gamesdk/pkg/errors中的错误)映射为错误码
+func Register(err error, e *Error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	mapped[err] = e
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("(%d)%v", e.Code, e.Msg)
+}
+
+// WithMsg 创建错误码相同、提示信息不同的错误
+func (e *Error) WithMsg(format string, args ...interface{}) *Error {
+	msg := format
+	if len(args) != 0 {
+		msg = fmt.Sprintf(format, args...)
+	}
+
+	return &Error{Code: e.Code, Msg: msg, Status: e.Status}
+}
+
+// Is 错误码相同即视为同一错误, 支持errors.Is
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+// FromError 将err转换为业务错误, nil为OK, 未定义的错误为ErrSystem
+func FromError(err error) *Error {
+	if err == nil {
+		return OK
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for err != nil {
+		if reflect.TypeOf(err).Comparable() {
+			if e, ok := mapped[err]; ok {
+				return e
+			}
+		}
+		err = errors.Unwrap(err)
+	}
+
+	return ErrSystem
+}
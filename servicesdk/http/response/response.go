@@ -0,0 +1,211 @@
+package response
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	p "github.com/kkkkiven/fishpkg/sprotocol/http"
+	"github.com/kkkkiven/fishpkg/utils"
+	"github.com/tinylib/msgp/msgp"
+)
+
+// 响应格式
+const (
+	FORMAT_JSON    = "json"
+	FORMAT_LUA     = "lua"
+	FORMAT_MSGPACK = "msgpack"
+)
+
+const (
+	CONTENT_TYPE_JSON    = "application/json; charset=utf-8"
+	CONTENT_TYPE_LUA     = "text/plain; charset=utf-8"
+	CONTENT_TYPE_MSGPACK = "application/x-msgpack"
+)
+
+// Body 统一响应结构, Lua格式下code对应status字段
+type Body struct {
+	Code int32       `json:"code"`
+	Msg  string      `json:"msg"`
+	Data interface{} `json:"data,omitempty"`
+}
+
+// Negotiate 根据format参数或Accept头选择响应格式, 默认JSON
+func Negotiate(r *http.Request) string {
+	switch f := strings.ToLower(r.URL.Query().Get("format")); f {
+	case FORMAT_JSON, FORMAT_LUA, FORMAT_MSGPACK:
+		return f
+	}
+
+	accept := strings.ToLower(r.Header.Get("Accept"))
+	switch {
+	case strings.Contains(accept, "msgpack"):
+		return FORMAT_MSGPACK
+	case strings.Contains(accept, "lua"):
+		return FORMAT_LUA
+	}
+
+	return FORMAT_JSON
+}
+
+// Success 输出成功响应
+func Success(ctx context.Context, w http.ResponseWriter, r *http.Request, data interface{}) {
+	Write(ctx, w, r, data, nil)
+}
+
+// Fail 输出错误响应, 未定义的错误以ErrSystem输出, 原始错误仅记录在span中
+func Fail(ctx context.Context, w http.ResponseWriter, r *http.Request, err error) {
+	Write(ctx, w, r, nil, err)
+}
+
+// Write 按协商的格式输出响应, err决定错误码与HTTP状态码, 并记录到当前请求的span
+func Write(ctx context.Context, w http.ResponseWriter, r *http.Request, data interface{}, err error) {
+	e := FromError(err)
+
+	if span := p.SpanFromContext(ctx); span != nil && e != OK {
+		span.Tag("error.code", e.Code)
+		span.Tag("error.msg", e.Msg)
+		if err != nil && err != e {
+			span.Tag("error", err.Error())
+		}
+		if e.Status >= http.StatusInternalServerError {
+			span.MarkError()
+		}
+	}
+
+	body := &Body{Code: e.Code, Msg: e.Msg, Data: data}
+
+	format := Negotiate(r)
+	buf, err := encode(format, body)
+	if err != nil {
+		format, e = FORMAT_JSON, ErrSystem
+		buf, _ = encode(format, &Body{Code: e.Code, Msg: e.Msg})
+	}
+
+	switch format {
+	case FORMAT_LUA:
+		w.Header().Set("Content-Type", CONTENT_TYPE_LUA)
+	case FORMAT_MSGPACK:
+		w.Header().Set("Content-Type", CONTENT_TYPE_MSGPACK)
+	default:
+		w.Header().Set("Content-Type", CONTENT_TYPE_JSON)
+	}
+
+	w.WriteHeader(e.Status)
+	w.Write(buf)
+}
+
+func encode(format string, body *Body) ([]byte, error) {
+	switch format {
+	case FORMAT_LUA:
+		return encodeLua(body)
+	case FORMAT_MSGPACK:
+		return encodeMsgpack(body)
+	}
+
+	return json.Marshal(body)
+}
+
+// encodeLua 输出"return {status=..,msg=..,data={..}}", 与utils.OutputLua一致
+func encodeLua(body *Body) ([]byte, error) {
+	ret := utils.NewLuaResult(body.Msg, int(body.Code))
+	if body.Data != nil {
+		data, err := generic(body.Data)
+		if err != nil {
+			return nil, err
+		}
+		ret["data"] = toLua(data)
+	}
+
+	return []byte("return " + utils.BuildLuaResponse(ret)), nil
+}
+
+// toLua 转换为BuildLuaResponse支持的类型, 数组下标从1开始
+func toLua(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		m := make(map[interface{}]interface{}, len(val))
+		for k, item := range val {
+			m[k] = toLua(item)
+		}
+		return m
+	case []interface{}:
+		m := make(map[interface{}]interface{}, len(val))
+		for i, item := range val {
+			m[i+1] = toLua(item)
+		}
+		return m
+	case float64:
+		// BuildLuaResponse不支持浮点数, 以字符串输出
+		return strconv.FormatFloat(val, 'f', -1, 64)
+	}
+
+	return v
+}
+
+func encodeMsgpack(body *Body) ([]byte, error) {
+	b := msgp.AppendMapHeader(nil, 3)
+	b = msgp.AppendString(b, "code")
+	b = msgp.AppendInt32(b, body.Code)
+	b = msgp.AppendString(b, "msg")
+	b = msgp.AppendString(b, body.Msg)
+	b = msgp.AppendString(b, "data")
+
+	// 实现了msgp.Marshaler的类型直接编码
+	if m, ok := body.Data.(msgp.Marshaler); ok {
+		return m.MarshalMsg(b)
+	}
+
+	data, err := generic(body.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	return msgp.AppendIntf(b, data)
+}
+
+// generic 经JSON转换为map[string]interface{}等通用类型, 整数转换为int64
+func generic(v interface{}) (interface{}, error) {
+	if v == nil {
+		return nil, nil
+	}
+
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var out interface{}
+	if err := dec.Decode(&out); err != nil {
+		return nil, err
+	}
+
+	return normalize(out), nil
+}
+
+func normalize(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, item := range val {
+			val[k] = normalize(item)
+		}
+	case []interface{}:
+		for i, item := range val {
+			val[i] = normalize(item)
+		}
+	case json.Number:
+		if n, err := val.Int64(); err == nil {
+			return n
+		}
+		f, _ := val.Float64()
+		return f
+	}
+
+	return v
+}
@@ -3,12 +3,14 @@ package http
 import (
 	"errors"
 	"fmt"
+	"net/http"
 	"os"
-	"strings"
+	"reflect"
 	"sync"
 
 	"github.com/kkkkiven/fishpkg/logs"
 	"github.com/kkkkiven/fishpkg/servicesdk/http/helper"
+	"github.com/kkkkiven/fishpkg/servicesdk/pkg/discovery"
 	"github.com/kkkkiven/fishpkg/servicesdk/pkg/etcd"
 	p "github.com/kkkkiven/fishpkg/sprotocol/http"
 	"github.com/kkkkiven/fishpkg/utils"
@@ -20,11 +22,14 @@ import (
 type HConfig struct {
 	Name         string      `yaml:"name"`          // 服务名称
 	Timeout      int64       `yaml:"timeout"`       // 超时时间
-	DiscoverMode string      `yaml:"discover_mode"` // 服务发现方式：static 或者 etcd
+	DiscoverMode string      `yaml:"discover_mode"` // 服务发现方式：etcd, static, file 或者 dns
 	GatewayDir   string      `yaml:"gateway_dir"`   // 网关ETCD目录
 	ServiceDir   string      `yaml:"service_dir"`   // 服务ETCD目录
 	GatewayAddr  []string    `yaml:"gateway_addr"`  // 网关地址
+	GatewayFile  string      `yaml:"gateway_file"`  // 网关地址文件, file模式使用
+	GatewaySrv   string      `yaml:"gateway_srv"`   // 网关SRV记录名, dns模式使用
 	ProberAddr   string      `yaml:"prober_addr"`   // 探测地址
+	Version      string      `yaml:"version"`       // 接口版本, 用于OpenAPI文档
 	Etcd         *EntityEtcd `yaml:"etcd"`          // ETCD配置
 }
 
@@ -47,14 +52,22 @@ type _Service struct {
 	discoverMode string
 	proberAddr   string
 	gatewayAddr  []string
+	gatewayFile  string
+	gatewaySrv   string
 	iface        []_Iface
 	timeout      int64
+	version      string
 
 	// ETCD相关
 	gatewayDir string
 	serviceDir string
 	etcdConf   *EntityEtcd
 	etcdConn   *etcd.Client
+
+	// 服务发现
+	discovery discovery.Discovery
+	published bool       // 已发布到服务发现, 此后新增接口时重新发布接口文档
+	openAPIMu sync.Mutex // 保证接口文档按生成顺序发布
 }
 
 var srv *_Service = &_Service{}
@@ -89,6 +102,12 @@ func SetTimeout(t int64) option {
 	}
 }
 
+func SetVersion(v string) option {
+	return func(s *_Service) {
+		s.version = v
+	}
+}
+
 func SetDiscoverMode(m string) option {
 	return func(s *_Service) {
 		s.discoverMode = m
@@ -108,6 +127,18 @@ func SetGatewayAddr(ips []string) option {
 	}
 }
 
+func SetGatewayFile(f string) option {
+	return func(s *_Service) {
+		s.gatewayFile = f
+	}
+}
+
+func SetGatewaySrv(name string) option {
+	return func(s *_Service) {
+		s.gatewaySrv = name
+	}
+}
+
 func SetGatewayDir(gdir string) option {
 	return func(s *_Service) {
 		s.gatewayDir = gdir
@@ -217,11 +248,50 @@ func (s *_Service) GatewayAddr() []string {
 	return s.gatewayAddr
 }
 
+func (s *_Service) Discovery() discovery.Discovery {
+	s.RLock()
+	defer s.RUnlock()
+
+	return s.discovery
+}
+
+// newDiscovery 根据服务发现方式创建实现, 调用方需持有写锁
+func (s *_Service) newDiscovery() (discovery.Discovery, error) {
+	switch discovery.NormalizeMode(s.discoverMode) {
+	case discovery.MODE_ETCD:
+		if s.etcdConf == nil || len(s.etcdConf.Addrs) == 0 {
+			return nil, errors.New("bad etcd config")
+		}
+
+		conn, err := etcd.New(s.etcdConf.Addrs, s.etcdConf.User, s.etcdConf.Pass)
+		if err != nil {
+			return nil, errors.New(fmt.Sprintf("new etcd err: %v", err.Error()))
+		}
+		s.etcdConn = conn
+
+		return discovery.NewEtcd(conn, s.gatewayDir, ETCD_KEY_EXPIRE, ETCD_KEY_KEEPALIVE_PERIOD), nil
+	case discovery.MODE_STATIC:
+		return discovery.NewStatic(s.gatewayAddr), nil
+	case discovery.MODE_FILE:
+		return discovery.NewFile(s.gatewayFile, 0), nil
+	case discovery.MODE_DNS:
+		return discovery.NewDNS(s.gatewaySrv, 0), nil
+	}
+
+	return nil, fmt.Errorf("unsupported discover mode: %v", s.discoverMode)
+}
+
 func (s *_Service) AddIface(iface _Iface) {
 	s.Lock()
-	defer s.Unlock()
-
 	s.iface = append(s.iface, iface)
+	published := s.published
+	s.Unlock()
+
+	if published {
+		if err := s.publishOpenAPI(); err != nil {
+			logs.Errorf("Publish openapi err: %v", err.Error())
+		}
+	}
 }
 
 func Init(cfg *HConfig) error {
@@ -241,22 +311,12 @@ func Init(cfg *HConfig) error {
 		srv.discoverMode = DEFAULT_DISCOVER_MODE
 	}
 
-	if strings.ToUpper(srv.discoverMode) == DEFAULT_DISCOVER_MODE {
-		if cfg.Etcd == nil || len(cfg.Etcd.Addrs) == 0 {
-			return errors.New("bad etcd config")
-		}
-
-		conn, err := etcd.New(cfg.Etcd.Addrs, cfg.Etcd.User, cfg.Etcd.Pass)
-		if err != nil {
-			return errors.New(fmt.Sprintf("new etcd err: %v", err.Error()))
-		}
-
+	if cfg.Etcd != nil && len(cfg.Etcd.Addrs) != 0 {
 		srv.etcdConf = &EntityEtcd{}
 		srv.etcdConf.User = cfg.Etcd.User
 		srv.etcdConf.Pass = cfg.Etcd.Pass
 		srv.etcdConf.Addrs = make([]string, len(cfg.Etcd.Addrs))
 		copy(srv.etcdConf.Addrs, cfg.Etcd.Addrs)
-		srv.etcdConn = conn
 	}
 
 	srv.ip = helper.GetLocalAddress("")
@@ -265,8 +325,11 @@ func Init(cfg *HConfig) error {
 	}
 
 	srv.gatewayAddr = cfg.GatewayAddr
+	srv.gatewayFile = cfg.GatewayFile
+	srv.gatewaySrv = cfg.GatewaySrv
 	srv.name = cfg.Name
 	srv.timeout = cfg.Timeout
+	srv.version = cfg.Version
 	srv.id = utils.Ip2long(srv.ip)
 	srv.weight = utils.Atoi(os.Getenv(DEFAULT_WEIGHT_ENV))
 	logs.Infof("Node weight: [%v:%v]", DEFAULT_WEIGHT_ENV, srv.weight)
@@ -287,6 +350,13 @@ func Init(cfg *HConfig) error {
 		srv.serviceDir += "/"
 	}
 
+	var err error
+	if srv.discovery, err = srv.newDiscovery(); err != nil {
+		return err
+	}
+
+	srv.mountOpenAPI()
+
 	return nil
 }
 
@@ -302,18 +372,6 @@ func InitOpt(opts ...option) error {
 		srv.discoverMode = DEFAULT_DISCOVER_MODE
 	}
 
-	if strings.ToUpper(srv.discoverMode) == DEFAULT_DISCOVER_MODE {
-		if srv.etcdConf == nil || len(srv.etcdConf.Addrs) == 0 {
-			return errors.New("bad etcd config")
-		}
-
-		conn, err := etcd.New(srv.etcdConf.Addrs, srv.etcdConf.User, srv.etcdConf.Pass)
-		if err != nil {
-			return errors.New(fmt.Sprintf("new etcd err: %v", err.Error()))
-		}
-		srv.etcdConn = conn
-	}
-
 	if srv.name == "" {
 		return errors.New("the server name is empty")
 	}
@@ -333,38 +391,54 @@ func InitOpt(opts ...option) error {
 		srv.gatewayDir += "/"
 	}
 
-	if srv.gatewayDir == "" {
+	if srv.serviceDir == "" {
 		srv.serviceDir = DEFAULT_SERVER_DIR
 	}
 	if srv.serviceDir[len(srv.serviceDir)-1] != '/' {
 		srv.serviceDir += "/"
 	}
 
+	var err error
+	if srv.discovery, err = srv.newDiscovery(); err != nil {
+		return err
+	}
+
+	srv.mountOpenAPI()
+
 	return nil
 }
 
 func Run() error {
-	if strings.ToUpper(srv.DiscoverMode()) == DEFAULT_DISCOVER_MODE {
-		if srv.EtcdConf() == nil {
-			return errors.New("please init first")
-		}
+	if srv.Discovery() == nil {
+		return errors.New("please init first")
+	}
 
-		if len(srv.Iface()) == 0 {
-			return errors.New("please add handler first")
-		}
+	// 静态网关地址不注册服务, 直接连接网关
+	if discovery.NormalizeMode(srv.DiscoverMode()) == discovery.MODE_STATIC {
+		return dialGateway(srv.GatewayAddr())
+	}
 
-		if err := srv.publish(); err != nil {
-			return err
-		}
+	if len(srv.Iface()) == 0 {
+		return errors.New("please add handler first")
+	}
 
-		return fetchGateway()
+	if err := srv.publish(); err != nil {
+		return err
 	}
 
-	return dialGateway(srv.GatewayAddr())
+	return fetchGateway()
 }
 
 func Stop() error {
-	return srv.revoke()
+	d := srv.Discovery()
+	if d == nil {
+		return nil
+	}
+
+	err := srv.revoke()
+	d.Close()
+
+	return err
 }
 
 // ==========================================
@@ -388,8 +462,15 @@ type _GWResponse struct {
 }
 
 type _Iface struct {
-	Path string `json:"path"`
-	Desc string `json:"desc"`
+	Method string   `json:"method,omitempty"` // 为空时匹配全部请求方法
+	Path   string   `json:"path"`
+	Desc   string   `json:"desc"`
+	Tags   []string `json:"tags,omitempty"`
+	Auth   []string `json:"auth,omitempty"` // 认证方式, 对应AddSecurityScheme注册的名称
+
+	groups []string
+	req    reflect.Type
+	rsp    reflect.Type
 }
 
 type _UnRegRequest struct {
@@ -481,27 +562,76 @@ func (s *_Service) publish() error {
 	pub.Weight = s.weight
 	pub.Ip = s.ip
 	key := fmt.Sprintf("%vhttp_%v", s.serviceDir, s.id)
-	etcdConn := s.etcdConn
+	d := s.discovery
 	s.RUnlock()
 
 	body, _ := json.Marshal(pub)
-	if err := etcdConn.SetKvAndKeepAlive(key, string(body), ETCD_KEY_EXPIRE, ETCD_KEY_KEEPALIVE_PERIOD); err != nil {
+	if err := d.Register(key, string(body)); err != nil {
+		return err
+	}
+
+	s.Lock()
+	s.published = true
+	s.Unlock()
+
+	if err := s.publishOpenAPI(); err != nil {
 		return err
 	}
 
 	// go s.watch()
 
-	logs.Debugf("Publish to etcd: [key=%v,value=%v]", key, string(body))
+	logs.Debugf("Publish service: [key=%v,value=%v]", key, string(body))
 	return nil
 }
 
 func (s *_Service) revoke() error {
 	s.RLock()
 	key := fmt.Sprintf("%vhttp_%v", s.serviceDir, s.id)
-	etcdConn := s.etcdConn
+	d := s.discovery
 	s.RUnlock()
 
-	return etcdConn.Del(key)
+	s.Lock()
+	s.published = false
+	s.Unlock()
+
+	d.Deregister(s.openAPIKey())
+
+	return d.Deregister(key)
+}
+
+// openAPIKey 接口文档的发布路径
+func (s *_Service) openAPIKey() string {
+	s.RLock()
+	defer s.RUnlock()
+
+	return fmt.Sprintf("%vopenapi_%v", s.serviceDir, s.id)
+}
+
+// publishOpenAPI 生成接口文档并发布到服务发现, 供网关或接口平台汇总, 不随网关注册消息发送
+func (s *_Service) publishOpenAPI() error {
+	s.openAPIMu.Lock()
+	defer s.openAPIMu.Unlock()
+
+	doc, err := OpenAPI()
+	if err != nil {
+		return err
+	}
+
+	return s.Discovery().Register(s.openAPIKey(), string(doc))
+}
+
+// openAPIMounted 已注册的接口文档路由
+var openAPIMounted = make(map[string]bool, 0)
+
+// mountOpenAPI 注册接口文档路由/<服务名>/openapi.json, 重新初始化更换服务名时注册新的路由, 调用方需持有锁
+func (s *_Service) mountOpenAPI() {
+	path := "/" + s.name + OPENAPI_PATH
+	if openAPIMounted[path] {
+		return
+	}
+
+	openAPIMounted[path] = true
+	p.Handle(http.MethodGet, path, openAPIHandler)
 }
 
 // func (s *_Service) watch() {
@@ -0,0 +1,80 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/kkkkiven/fishpkg/logs"
+	p "github.com/kkkkiven/fishpkg/sprotocol/http"
+)
+
+const websocketGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+// UpgradeFunc 协议升级处理函数, conn为握手完成后的双向连接, 函数返回后自动关闭
+type UpgradeFunc func(ctx context.Context, conn net.Conn, r *http.Request, ps Params)
+
+// AddUpgradeHandler 注册协议升级(如WebSocket)接口, 完成101握手后以net.Conn交给fn处理, 非升级请求返回426
+// 网关模式下连接经网关连接多路复用, 本地模式下为net/http接管的连接; WebSocket帧的编解码由fn负责
+func AddUpgradeHandler(path, desc string, fn UpgradeFunc, opts ...ifaceOption) {
+	Handle(http.MethodGet, path, desc, func(ctx context.Context, w http.ResponseWriter, r *http.Request, ps Params) {
+		if !p.IsUpgrade(r) {
+			w.Header().Set("Connection", "Upgrade")
+			http.Error(w, http.StatusText(http.StatusUpgradeRequired), http.StatusUpgradeRequired)
+			return
+		}
+
+		websocket := strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+		if websocket && (r.Header.Get("Sec-WebSocket-Key") == "" || r.Header.Get("Sec-WebSocket-Version") != "13") {
+			w.Header().Set("Sec-WebSocket-Version", "13")
+			http.Error(w, "bad websocket handshake", http.StatusBadRequest)
+			return
+		}
+
+		h, ok := w.(http.Hijacker)
+		if !ok {
+			http.Error(w, "upgrade not supported", http.StatusInternalServerError)
+			return
+		}
+
+		conn, brw, err := h.Hijack()
+		if err != nil {
+			logs.Errorf("- %v - Hijack err: %v", r.RemoteAddr, err.Error())
+			return
+		}
+		defer conn.Close()
+
+		brw.WriteString("HTTP/1.1 101 Switching Protocols\r\nUpgrade: " + r.Header.Get("Upgrade") + "\r\nConnection: Upgrade\r\n")
+		if websocket {
+			brw.WriteString("Sec-WebSocket-Accept: " + websocketAccept(r.Header.Get("Sec-WebSocket-Key")) + "\r\n")
+		}
+		brw.WriteString("\r\n")
+		if err := brw.Flush(); err != nil {
+			logs.Errorf("- %v - Upgrade err: %v", r.RemoteAddr, err.Error())
+			return
+		}
+
+		fn(ctx, &_UpgradedConn{Conn: conn, rd: brw.Reader}, r, ps)
+	}, opts...)
+}
+
+// websocketAccept 计算Sec-WebSocket-Accept
+func websocketAccept(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + websocketGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+// _UpgradedConn 优先读取接管连接时已缓存的数据
+type _UpgradedConn struct {
+	net.Conn
+	rd *bufio.Reader
+}
+
+func (this *_UpgradedConn) Read(b []byte) (int, error) {
+	return this.rd.Read(b)
+}
@@ -0,0 +1,298 @@
+// 管理端口, 提供健康检查、就绪检查、调试信息、pprof与日志级别调整
+package admin
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"sync"
+	"time"
+
+	"github.com/kkkkiven/fishpkg/logs"
+	"github.com/kkkkiven/fishpkg/metrics"
+	"github.com/pkg/errors"
+)
+
+// 管理接口路径
+const (
+	PATH_HEALTHZ   = "/healthz"
+	PATH_READYZ    = "/readyz"
+	PATH_LOG_LEVEL = "/debug/loglevel"
+	PATH_PPROF     = "/debug/pprof/"
+	PATH_METRICS   = metrics.DEFAULT_PATH
+
+	DEFAULT_CHECK_TIMEOUT = 3 * time.Second
+)
+
+// Check 就绪检查函数, 未就绪时返回错误
+type Check func() error
+
+type _Check struct {
+	name string
+	fn   Check
+}
+
+// Server 管理端口服务
+type Server struct {
+	mu       sync.RWMutex
+	addr     string
+	mux      *http.ServeMux
+	checks   []_Check
+	handlers map[string]http.Handler
+	srv      *http.Server
+}
+
+// NewServer 创建管理端口服务, 注册默认接口
+func NewServer(addr string) *Server {
+	s := &Server{addr: addr, mux: http.NewServeMux(), handlers: make(map[string]http.Handler)}
+
+	s.mux.HandleFunc(PATH_HEALTHZ, s.healthz)
+	s.mux.HandleFunc(PATH_READYZ, s.readyz)
+	s.mux.HandleFunc(PATH_LOG_LEVEL, s.logLevel)
+	s.mux.Handle(PATH_METRICS, metrics.Handler())
+
+	s.mux.HandleFunc(PATH_PPROF, pprof.Index)
+	s.mux.HandleFunc(PATH_PPROF+"cmdline", pprof.Cmdline)
+	s.mux.HandleFunc(PATH_PPROF+"profile", pprof.Profile)
+	s.mux.HandleFunc(PATH_PPROF+"symbol", pprof.Symbol)
+	s.mux.HandleFunc(PATH_PPROF+"trace", pprof.Trace)
+
+	return s
+}
+
+// Addr 获取监听地址
+func (s *Server) Addr() string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.addr
+}
+
+// SetAddr 设置监听地址, 需在Start前调用
+func (s *Server) SetAddr(addr string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.addr = addr
+}
+
+// AddCheck 添加就绪检查, 同名检查会被替换
+func (s *Server) AddCheck(name string, fn Check) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, c := range s.checks {
+		if c.name == name {
+			s.checks[i].fn = fn
+			return
+		}
+	}
+
+	s.checks = append(s.checks, _Check{name: name, fn: fn})
+}
+
+// Handle 注册自定义接口, 同路径接口会被替换
+func (s *Server) Handle(pattern string, h http.Handler) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.handlers[pattern]; ok {
+		s.handlers[pattern] = h
+		return
+	}
+
+	// ServeMux不允许重复注册, 只注册一次分发函数, 替换时仅更新handlers
+	s.handlers[pattern] = h
+	s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		s.mu.RLock()
+		h := s.handlers[pattern]
+		s.mu.RUnlock()
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// HandleFunc 注册自定义接口, 同路径接口会被替换
+func (s *Server) HandleFunc(pattern string, fn func(http.ResponseWriter, *http.Request)) {
+	s.Handle(pattern, http.HandlerFunc(fn))
+}
+
+// HandleJSON 注册以JSON格式输出fn返回值的只读接口, 同路径接口会被替换
+func (s *Server) HandleJSON(pattern string, fn func() interface{}) {
+	s.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, fn())
+	})
+}
+
+// ServeHTTP 处理请求, 便于挂载到已有的HTTP服务
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Start 开始监听, 监听成功后在后台提供服务
+func (s *Server) Start() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.srv != nil {
+		return errors.New("admin server already started")
+	}
+
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return errors.WithMessage(err, "admin listen failed")
+	}
+
+	s.addr = ln.Addr().String()
+	s.srv = &http.Server{Handler: s.mux}
+
+	go func(srv *http.Server) {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logs.Errorf("Admin server err: %v", err.Error())
+		}
+	}(s.srv)
+
+	logs.Infof("Admin server serving on %v", s.addr)
+	return nil
+}
+
+// Stop 停止服务
+func (s *Server) Stop() error {
+	s.mu.Lock()
+	srv := s.srv
+	s.srv = nil
+	s.mu.Unlock()
+
+	if srv == nil {
+		return nil
+	}
+
+	return srv.Close()
+}
+
+// healthz 存活检查, 进程可响应即为存活
+func (s *Server) healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Write([]byte("ok"))
+}
+
+// readyz 就绪检查, 并行执行全部检查项, 任一失败时返回503
+func (s *Server) readyz(w http.ResponseWriter, r *http.Request) {
+	s.mu.RLock()
+	checks := append([]_Check(nil), s.checks...)
+	s.mu.RUnlock()
+
+	results := make(map[string]string, len(checks))
+
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, c := range checks {
+		wg.Add(1)
+		go func(c _Check) {
+			defer wg.Done()
+
+			msg := "ok"
+			if err := runCheck(c.fn); err != nil {
+				msg = err.Error()
+			}
+
+			mu.Lock()
+			results[c.name] = msg
+			mu.Unlock()
+		}(c)
+	}
+	wg.Wait()
+
+	code := http.StatusOK
+	for _, msg := range results {
+		if msg != "ok" {
+			code = http.StatusServiceUnavailable
+			break
+		}
+	}
+
+	writeJSON(w, code, results)
+}
+
+// runCheck 执行检查, 超时视为未就绪
+func runCheck(fn Check) error {
+	ch := make(chan error, 1)
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				ch <- errors.Errorf("panic: %v", err)
+			}
+		}()
+		ch <- fn()
+	}()
+
+	select {
+	case err := <-ch:
+		return err
+	case <-time.After(DEFAULT_CHECK_TIMEOUT):
+		return errors.New("check timeout")
+	}
+}
+
+// logLevel GET获取日志级别, PUT/POST以level参数调整日志级别, 带package参数时调整按包设置的级别,
+// DELETE清除package参数指定的按包级别
+func (s *Server) logLevel(w http.ResponseWriter, r *http.Request) {
+	pkg := r.FormValue("package")
+
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut, http.MethodPost:
+		level, err := logs.ParseLevel(r.FormValue("level"))
+		if err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+			return
+		}
+
+		if pkg != "" {
+			logs.SetPackageLevel(pkg, level)
+			logs.Infof("Log level of package[%v]: %v", pkg, logs.LevelString(level))
+			break
+		}
+
+		old := logs.GetLevel()
+		logs.SetLevel(level)
+		logs.Infof("Log level: [%v -> %v]", logs.LevelString(old), logs.LevelString(level))
+	case http.MethodDelete:
+		if pkg == "" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "missing package"})
+			return
+		}
+
+		logs.ClearPackageLevel(pkg)
+		logs.Infof("Log level of package[%v] cleared", pkg)
+	default:
+		w.Header().Set("Allow", "GET, PUT, POST, DELETE")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	packages := make(map[string]string, 0)
+	for name, level := range logs.PackageLevels() {
+		packages[name] = logs.LevelString(level)
+	}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"level":    logs.LevelString(logs.GetLevel()),
+		"packages": packages,
+	})
+}
+
+func writeJSON(w http.ResponseWriter, code int, v interface{}) {
+	body, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(code)
+	w.Write(body)
+}
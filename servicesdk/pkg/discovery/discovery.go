@@ -0,0 +1,172 @@
+package discovery
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kkkkiven/fishpkg/logs"
+)
+
+// 服务发现方式
+const (
+	MODE_ETCD   = "ETCD"   // ETCD注册与监听
+	MODE_STATIC = "STATIC" // 静态网关地址
+	MODE_FILE   = "FILE"   // 本地文件监听, 用于本地开发
+	MODE_DNS    = "DNS"    // DNS SRV记录轮询
+)
+
+// Discovery 服务发现接口
+type Discovery interface {
+	// Register 注册服务节点
+	Register(key, value string) error
+
+	// Deregister 注销服务节点
+	Deregister(key string) error
+
+	// WatchKey 监听指定key的变化, 用于运行时调整注册信息
+	WatchKey(key string, onPut func(value string)) error
+
+	// Get 获取指定key的注册信息, key不存在时返回空串
+	Get(key string) (string, error)
+
+	// List 获取指定前缀下已注册的服务节点信息
+	List(prefix string) ([]string, error)
+
+	// WatchGateway 获取当前网关列表并监听变化, 网关上线回调onAdd, 下线回调onDel
+	WatchGateway(onAdd, onDel func(addr string)) error
+
+	// Close 关闭
+	Close() error
+}
+
+// Allocator 实例ID分配接口, 由支持租约的服务发现实现
+type Allocator interface {
+	// Allocate 在[min, max]范围内为owner独占分配一个ID, 持有至Close
+	// 租约过期后ID被其他owner占用时回调onLost, 此后不再持有该ID
+	Allocate(prefix, owner string, min, max uint32, onLost func(id uint32)) (uint32, error)
+}
+
+// Checker 健康检查接口, 由需要维持连接或租约的服务发现实现
+type Checker interface {
+	// Check 检查注册状态, 异常时返回错误
+	Check() error
+}
+
+// ErrNotSupported 当前服务发现方式不支持该操作
+var ErrNotSupported = errors.New("not supported by this discover mode")
+
+// NormalizeMode 格式化服务发现方式
+func NormalizeMode(mode string) string {
+	return strings.ToUpper(strings.TrimSpace(mode))
+}
+
+// _AddrSet 网关地址集合, 用于轮询类实现计算增删
+type _AddrSet struct {
+	sync.Mutex
+	m map[string]struct{}
+}
+
+func newAddrSet() *_AddrSet {
+	return &_AddrSet{m: make(map[string]struct{}, 0)}
+}
+
+// reset 替换为新的地址集合, 返回新增与删除的地址
+func (this *_AddrSet) reset(addrs []string) (added, deleted []string) {
+	this.Lock()
+	defer this.Unlock()
+
+	m := make(map[string]struct{}, len(addrs))
+	for _, addr := range addrs {
+		m[addr] = struct{}{}
+		if _, ok := this.m[addr]; !ok {
+			added = append(added, addr)
+		}
+	}
+
+	for addr := range this.m {
+		if _, ok := m[addr]; !ok {
+			deleted = append(deleted, addr)
+		}
+	}
+
+	this.m = m
+
+	sort.Strings(added)
+	sort.Strings(deleted)
+
+	return
+}
+
+// _Poller 定时拉取网关列表, 用于文件与DNS等不支持推送的实现
+type _Poller struct {
+	name     string
+	interval time.Duration
+	resolve  func() ([]string, error)
+	set      *_AddrSet
+	closed   chan struct{}
+	once     sync.Once
+}
+
+func newPoller(name string, interval time.Duration, resolve func() ([]string, error)) *_Poller {
+	return &_Poller{
+		name:     name,
+		interval: interval,
+		resolve:  resolve,
+		set:      newAddrSet(),
+		closed:   make(chan struct{}),
+	}
+}
+
+// start 首次拉取失败时返回错误, 之后在后台按间隔轮询
+func (this *_Poller) start(onAdd, onDel func(addr string)) error {
+	addrs, err := this.resolve()
+	if err != nil {
+		return err
+	}
+
+	added, _ := this.set.reset(addrs)
+	for _, addr := range added {
+		onAdd(addr)
+	}
+
+	go this.run(onAdd, onDel)
+
+	return nil
+}
+
+func (this *_Poller) run(onAdd, onDel func(addr string)) {
+	ticker := time.NewTicker(this.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-this.closed:
+			return
+		case <-ticker.C:
+			addrs, err := this.resolve()
+			if err != nil {
+				logs.Errorf("Resolve gateway from %v err: %v", this.name, err.Error())
+				continue
+			}
+
+			added, deleted := this.set.reset(addrs)
+			for _, addr := range added {
+				logs.Infof("Add gateway[%v] from %v", addr, this.name)
+				onAdd(addr)
+			}
+			for _, addr := range deleted {
+				logs.Infof("Delete gateway[%v] from %v", addr, this.name)
+				onDel(addr)
+			}
+		}
+	}
+}
+
+func (this *_Poller) stop() {
+	this.once.Do(func() {
+		close(this.closed)
+	})
+}
@@ -0,0 +1,81 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+// DEFAULT_DNS_INTERVAL DNS轮询间隔
+const DEFAULT_DNS_INTERVAL = 10 * time.Second
+
+// DNSDiscovery 基于DNS SRV记录的服务发现, 服务注册由外部(如k8s)完成
+type DNSDiscovery struct {
+	name   string
+	poller *_Poller
+}
+
+// NewDNS 创建DNS SRV服务发现, name为完整SRV记录名, 如 _gw._tcp.example.com
+func NewDNS(name string, interval time.Duration) *DNSDiscovery {
+	if interval <= 0 {
+		interval = DEFAULT_DNS_INTERVAL
+	}
+
+	d := &DNSDiscovery{name: name}
+	d.poller = newPoller(name, interval, d.lookup)
+	return d
+}
+
+func (this *DNSDiscovery) Register(key, value string) error {
+	return nil
+}
+
+func (this *DNSDiscovery) Deregister(key string) error {
+	return nil
+}
+
+func (this *DNSDiscovery) WatchKey(key string, onPut func(value string)) error {
+	return nil
+}
+
+func (this *DNSDiscovery) Get(key string) (string, error) {
+	return "", ErrNotSupported
+}
+
+func (this *DNSDiscovery) List(prefix string) ([]string, error) {
+	return nil, ErrNotSupported
+}
+
+func (this *DNSDiscovery) WatchGateway(onAdd, onDel func(addr string)) error {
+	if this.name == "" {
+		return errors.New("gateway srv name isn't specified")
+	}
+
+	return this.poller.start(onAdd, onDel)
+}
+
+func (this *DNSDiscovery) Close() error {
+	this.poller.stop()
+	return nil
+}
+
+func (this *DNSDiscovery) lookup() ([]string, error) {
+	_, srvs, err := net.LookupSRV("", "", this.name)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs := make([]string, 0, len(srvs))
+	for _, s := range srvs {
+		host := strings.TrimSuffix(s.Target, ".")
+		ips, err := net.LookupHost(host)
+		if err != nil || len(ips) == 0 {
+			continue
+		}
+		addrs = append(addrs, fmt.Sprintf("%v:%v", ips[0], s.Port))
+	}
+
+	return addrs, nil
+}
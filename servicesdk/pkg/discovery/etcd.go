@@ -0,0 +1,250 @@
+package discovery
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kkkkiven/fishpkg/logs"
+	"github.com/kkkkiven/fishpkg/servicesdk/pkg/etcd"
+)
+
+// EtcdDiscovery 基于ETCD的服务发现
+type EtcdDiscovery struct {
+	conn       *etcd.Client
+	gatewayDir string
+	ttl        int64
+	period     int64
+	closed     chan struct{}
+
+	mu  sync.Mutex
+	kvs map[string]*etcd.KeepAliveKv
+}
+
+// NewEtcd 创建ETCD服务发现, ttl为注册key过期时间, period为保活间隔(秒)
+func NewEtcd(conn *etcd.Client, gatewayDir string, ttl, period int64) *EtcdDiscovery {
+	return &EtcdDiscovery{
+		conn:       conn,
+		gatewayDir: gatewayDir,
+		ttl:        ttl,
+		period:     period,
+		closed:     make(chan struct{}),
+		kvs:        make(map[string]*etcd.KeepAliveKv, 0),
+	}
+}
+
+// Conn 获取ETCD连接
+func (this *EtcdDiscovery) Conn() *etcd.Client {
+	return this.conn
+}
+
+// Register 注册服务节点, 重复注册同一key时沿用原租约更新value
+func (this *EtcdDiscovery) Register(key, value string) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if kv, ok := this.kvs[key]; ok {
+		return kv.Update(value)
+	}
+
+	this.kvs[key] = this.conn.NewKeepAliveKv(key, value, this.ttl, this.period)
+	return nil
+}
+
+func (this *EtcdDiscovery) Deregister(key string) error {
+	this.mu.Lock()
+	if kv, ok := this.kvs[key]; ok {
+		kv.Stop()
+		delete(this.kvs, key)
+	}
+	this.mu.Unlock()
+
+	return this.conn.Del(key)
+}
+
+func (this *EtcdDiscovery) WatchKey(key string, onPut func(value string)) error {
+	go func() {
+		for {
+			select {
+			case <-this.closed:
+				return
+			case <-time.After(1 * time.Second):
+			}
+
+			rch := this.conn.WatchKey(key)
+			for wresp := range rch {
+				if wresp.Canceled {
+					logs.Infof("Watch %v canceled", key)
+					break
+				}
+
+				if err := wresp.Err(); err != nil {
+					logs.Errorf("Watch %v err: %s", key, err.Error())
+					break
+				}
+
+				for _, ev := range wresp.Events {
+					if ev.Type.String() == "PUT" {
+						onPut(string(ev.Kv.Value))
+					}
+				}
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (this *EtcdDiscovery) Get(key string) (string, error) {
+	return this.conn.Get(key)
+}
+
+// Allocate 以owner哈希为起点顺序尝试独占 prefix+id, 分配成功的key随租约保活, 进程退出后自动释放
+func (this *EtcdDiscovery) Allocate(prefix, owner string, min, max uint32, onLost func(id uint32)) (uint32, error) {
+	if min == 0 || max < min {
+		return 0, fmt.Errorf("bad id range: [%v, %v]", min, max)
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(owner))
+
+	size := uint64(max-min) + 1
+	start := uint64(h.Sum32()) % size
+
+	for i := uint64(0); i < size; i++ {
+		id := min + uint32((start+i)%size)
+		key := fmt.Sprintf("%v%v", prefix, id)
+
+		kv, err := this.conn.NewExclusiveKv(key, owner, this.ttl, this.period)
+		if err == etcd.ErrKeyExists {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+
+		this.mu.Lock()
+		this.kvs[key] = kv
+		this.mu.Unlock()
+
+		go this.watchLost(key, id, kv, onLost)
+
+		logs.Infof("Allocate id: [key=%v,owner=%v]", key, owner)
+		return id, nil
+	}
+
+	return 0, fmt.Errorf("no free id in [%v, %v]", min, max)
+}
+
+// watchLost 等待分配的ID丢失, 丢失后不再保活也不删除该key
+func (this *EtcdDiscovery) watchLost(key string, id uint32, kv *etcd.KeepAliveKv, onLost func(id uint32)) {
+	select {
+	case <-this.closed:
+		return
+	case <-kv.Lost():
+	}
+
+	this.mu.Lock()
+	if this.kvs[key] == kv {
+		delete(this.kvs, key)
+	}
+	this.mu.Unlock()
+
+	logs.Errorf("Lost id: [key=%v]", key)
+	if onLost != nil {
+		onLost(id)
+	}
+}
+
+func (this *EtcdDiscovery) List(prefix string) ([]string, error) {
+	_, values, err := this.conn.GetKvWithPrefix(prefix)
+	return values, err
+}
+
+func (this *EtcdDiscovery) WatchGateway(onAdd, onDel func(addr string)) error {
+	pfx := this.gatewayDir
+
+	keys, _, err := this.conn.GetKvWithPrefix(pfx)
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		onAdd(strings.TrimPrefix(k, pfx))
+	}
+
+	go this.watch(onAdd, onDel)
+
+	return nil
+}
+
+func (this *EtcdDiscovery) watch(onAdd, onDel func(addr string)) {
+	pfx := this.gatewayDir
+
+	for {
+		select {
+		case <-this.closed:
+			return
+		case <-time.After(1 * time.Second):
+		}
+
+		rch := this.conn.WatchKeyWithPrefix(pfx)
+		logs.Debugf("Wath: %v", pfx)
+		for wresp := range rch {
+			if wresp.Canceled {
+				logs.Infof("Watch %v canceled", pfx)
+				break
+			}
+
+			if err := wresp.Err(); err != nil {
+				logs.Errorf("Watch %v err: %s", pfx, err.Error())
+				break
+			}
+
+			for _, ev := range wresp.Events {
+				switch ev.Type.String() {
+				case "PUT":
+					logs.Infof("Add ETCD [key:%s,value:%s]", string(ev.Kv.Key), string(ev.Kv.Value))
+					onAdd(strings.TrimPrefix(string(ev.Kv.Key), pfx))
+				case "DELETE":
+					logs.Infof("Delete ETCD [key:%s,value:%s]", string(ev.Kv.Key), string(ev.Kv.Value))
+					onDel(strings.TrimPrefix(string(ev.Kv.Key), pfx))
+				}
+			}
+		}
+	}
+}
+
+// Check 检查已注册key的租约是否均有效
+func (this *EtcdDiscovery) Check() error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	for key, kv := range this.kvs {
+		if !kv.Alive() {
+			return fmt.Errorf("lease of key[%v] is not alive", key)
+		}
+	}
+
+	return nil
+}
+
+func (this *EtcdDiscovery) Close() error {
+	select {
+	case <-this.closed:
+		return nil
+	default:
+		close(this.closed)
+	}
+
+	this.mu.Lock()
+	for key, kv := range this.kvs {
+		kv.Stop()
+		delete(this.kvs, key)
+	}
+	this.mu.Unlock()
+
+	return this.conn.Close()
+}
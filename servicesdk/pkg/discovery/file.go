@@ -0,0 +1,90 @@
+package discovery
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kkkkiven/fishpkg/logs"
+)
+
+// DEFAULT_FILE_INTERVAL 文件检查间隔
+const DEFAULT_FILE_INTERVAL = 3 * time.Second
+
+// FileDiscovery 基于本地文件的服务发现, 文件每行一个网关地址, '#'开头为注释
+type FileDiscovery struct {
+	path   string
+	poller *_Poller
+}
+
+// NewFile 创建文件服务发现
+func NewFile(path string, interval time.Duration) *FileDiscovery {
+	if interval <= 0 {
+		interval = DEFAULT_FILE_INTERVAL
+	}
+
+	f := &FileDiscovery{path: path}
+	f.poller = newPoller(path, interval, f.read)
+	return f
+}
+
+// Register 本地开发模式无注册中心, 仅打印日志
+func (this *FileDiscovery) Register(key, value string) error {
+	logs.Debugf("Publish to file discovery ignored: [key=%v,value=%v]", key, value)
+	return nil
+}
+
+func (this *FileDiscovery) Deregister(key string) error {
+	return nil
+}
+
+func (this *FileDiscovery) WatchKey(key string, onPut func(value string)) error {
+	return nil
+}
+
+func (this *FileDiscovery) Get(key string) (string, error) {
+	return "", ErrNotSupported
+}
+
+func (this *FileDiscovery) List(prefix string) ([]string, error) {
+	return nil, ErrNotSupported
+}
+
+func (this *FileDiscovery) WatchGateway(onAdd, onDel func(addr string)) error {
+	if this.path == "" {
+		return errors.New("gateway file isn't specified")
+	}
+
+	return this.poller.start(onAdd, onDel)
+}
+
+func (this *FileDiscovery) Close() error {
+	this.poller.stop()
+	return nil
+}
+
+func (this *FileDiscovery) read() ([]string, error) {
+	fp, err := os.Open(this.path)
+	if err != nil {
+		return nil, err
+	}
+	defer fp.Close()
+
+	var addrs []string
+	scanner := bufio.NewScanner(fp)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		addrs = append(addrs, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return addrs, nil
+}
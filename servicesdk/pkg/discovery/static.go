@@ -0,0 +1,53 @@
+package discovery
+
+import (
+	"errors"
+)
+
+// StaticDiscovery 静态网关地址, 不做注册
+type StaticDiscovery struct {
+	addrs []string
+}
+
+// NewStatic 创建静态服务发现
+func NewStatic(addrs []string) *StaticDiscovery {
+	s := &StaticDiscovery{}
+	s.addrs = append(s.addrs, addrs...)
+	return s
+}
+
+func (this *StaticDiscovery) Register(key, value string) error {
+	return nil
+}
+
+func (this *StaticDiscovery) Deregister(key string) error {
+	return nil
+}
+
+func (this *StaticDiscovery) WatchKey(key string, onPut func(value string)) error {
+	return nil
+}
+
+func (this *StaticDiscovery) Get(key string) (string, error) {
+	return "", ErrNotSupported
+}
+
+func (this *StaticDiscovery) List(prefix string) ([]string, error) {
+	return nil, ErrNotSupported
+}
+
+func (this *StaticDiscovery) WatchGateway(onAdd, onDel func(addr string)) error {
+	if len(this.addrs) == 0 {
+		return errors.New("gateway addr isn't specified")
+	}
+
+	for _, addr := range this.addrs {
+		onAdd(addr)
+	}
+
+	return nil
+}
+
+func (this *StaticDiscovery) Close() error {
+	return nil
+}
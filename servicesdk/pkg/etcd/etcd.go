@@ -2,7 +2,9 @@ package etcd
 
 import (
 	"context"
+	"errors"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/kkkkiven/fishpkg/logs"
@@ -15,6 +17,9 @@ var (
 	defDialTimeout = 5 * time.Second
 )
 
+// ErrKeyExists 独占写入时key已存在
+var ErrKeyExists = errors.New("key already exists")
+
 type Client struct {
 	obj *clientv3.Client
 }
@@ -175,3 +180,215 @@ func (cli *Client) SetKvAndKeepAlive(key, value string, ttl, interval int64) err
 
 	return nil
 }
+
+// KeepAliveKv 带租约保活的kv, 可在不中断租约的情况下更新value
+type KeepAliveKv struct {
+	cli      *Client
+	key      string
+	ttl      int64
+	interval int64
+
+	// 独占模式下仅在key不存在时写入
+	exclusive bool
+
+	mu    sync.Mutex
+	value string
+	lease clientv3.LeaseID
+	alive time.Time // 最近一次租约建立或续约成功的时间
+
+	stop chan struct{}
+	once sync.Once
+	lost chan struct{} // 独占模式下key被其他owner占用时关闭
+}
+
+// NewKeepAliveKv 设置kv并在后台保活, 租约丢失时自动以最新value重建
+func (cli *Client) NewKeepAliveKv(key, value string, ttl, interval int64) *KeepAliveKv {
+	kv := &KeepAliveKv{
+		cli:      cli,
+		key:      key,
+		ttl:      ttl,
+		interval: interval,
+		value:    value,
+		stop:     make(chan struct{}),
+		lost:     make(chan struct{}),
+	}
+
+	go kv.run()
+
+	return kv
+}
+
+// NewExclusiveKv 仅在key不存在时设置kv并在后台保活, key已存在时返回ErrKeyExists
+func (cli *Client) NewExclusiveKv(key, value string, ttl, interval int64) (*KeepAliveKv, error) {
+	kv := &KeepAliveKv{
+		cli:       cli,
+		key:       key,
+		ttl:       ttl,
+		interval:  interval,
+		exclusive: true,
+		value:     value,
+		stop:      make(chan struct{}),
+		lost:      make(chan struct{}),
+	}
+
+	if err := kv.grant(); err != nil {
+		return nil, err
+	}
+
+	go kv.run()
+
+	return kv, nil
+}
+
+// Value 获取当前value
+func (kv *KeepAliveKv) Value() string {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	return kv.value
+}
+
+// Update 更新value, 沿用当前租约
+func (kv *KeepAliveKv) Update(value string) error {
+	kv.mu.Lock()
+	kv.value = value
+	lease := kv.lease
+	kv.mu.Unlock()
+
+	// 租约尚未建立, 由保活协程写入最新value
+	if lease == 0 {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.TODO(), defReqTimeout)
+	defer cancel()
+
+	_, err := kv.cli.obj.Put(ctx, kv.key, value, clientv3.WithLease(lease))
+	return err
+}
+
+// Alive 租约是否有效, 最近一次续约距今超过ttl时视为失效
+func (kv *KeepAliveKv) Alive() bool {
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	return kv.lease != 0 && time.Since(kv.alive) < time.Duration(kv.ttl)*time.Second
+}
+
+// Lost 独占kv的租约过期后key被其他owner占用时关闭, 此后不再保活
+func (kv *KeepAliveKv) Lost() <-chan struct{} {
+	return kv.lost
+}
+
+// Stop 停止保活
+func (kv *KeepAliveKv) Stop() {
+	kv.once.Do(func() {
+		close(kv.stop)
+	})
+}
+
+func (kv *KeepAliveKv) grant() error {
+	ctx, cancel := context.WithTimeout(context.TODO(), defReqTimeout)
+	defer cancel()
+
+	rsp, err := kv.cli.obj.Grant(ctx, kv.ttl)
+	if err != nil {
+		return err
+	}
+
+	kv.mu.Lock()
+	defer kv.mu.Unlock()
+
+	ctx, cancel = context.WithTimeout(context.TODO(), defReqTimeout)
+	defer cancel()
+
+	if !kv.exclusive {
+		if _, err := kv.cli.obj.Put(ctx, kv.key, kv.value, clientv3.WithLease(rsp.ID)); err != nil {
+			return err
+		}
+
+		kv.lease = rsp.ID
+		kv.alive = time.Now()
+		return nil
+	}
+
+	txn, err := kv.cli.obj.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(kv.key), "=", 0)).
+		Then(clientv3.OpPut(kv.key, kv.value, clientv3.WithLease(rsp.ID))).
+		Commit()
+	if err != nil {
+		return err
+	}
+
+	if !txn.Succeeded {
+		kv.cli.obj.Revoke(context.TODO(), rsp.ID)
+		return ErrKeyExists
+	}
+
+	kv.lease = rsp.ID
+	kv.alive = time.Now()
+	return nil
+}
+
+func (kv *KeepAliveKv) run() {
+	logs.Debugf("Keepalive key: %v, value: %v", kv.key, kv.Value())
+
+	ticker := time.NewTicker(time.Duration(kv.interval) * time.Second)
+	defer ticker.Stop()
+
+	for {
+		kv.mu.Lock()
+		granted := kv.lease != 0
+		kv.mu.Unlock()
+
+		if granted {
+			goto LOOP
+		}
+
+		if err := kv.grant(); err != nil {
+			// 独占的key已被其他owner占用, 停止保活并通知调用方
+			if err == ErrKeyExists {
+				logs.Errorf("Keepalive key: %v is taken by others", kv.key)
+				close(kv.lost)
+				return
+			}
+
+			logs.Errorf("Keepalive err: %v", err.Error())
+
+			select {
+			case <-kv.stop:
+				return
+			case <-ticker.C:
+			}
+			continue
+		}
+
+	LOOP:
+		for {
+			select {
+			case <-kv.stop:
+				return
+			case <-ticker.C:
+				kv.mu.Lock()
+				lease := kv.lease
+				kv.mu.Unlock()
+
+				if _, err := kv.cli.obj.KeepAliveOnce(context.TODO(), lease); err != nil {
+					logs.Errorf("Keepalive key:%s, err: %v", kv.key, err.Error())
+					if ok := strings.Contains(err.Error(), "requested lease not found"); ok {
+						logs.Debugf("Rekeepalive key: %v, value: %v", kv.key, kv.Value())
+						kv.mu.Lock()
+						kv.lease = 0
+						kv.mu.Unlock()
+						break LOOP
+					}
+					continue
+				}
+
+				kv.mu.Lock()
+				kv.alive = time.Now()
+				kv.mu.Unlock()
+			}
+		}
+	}
+}
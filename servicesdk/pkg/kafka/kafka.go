@@ -2,6 +2,7 @@ package kafka
 
 import (
 	"hash/fnv"
+	"sync/atomic"
 	"time"
 
 	"github.com/kkkkiven/fishpkg/logs"
@@ -13,6 +14,9 @@ import (
 	"github.com/pkg/errors"
 )
 
+// ErrProducerBusy 异步生产者输入队列已满
+var ErrProducerBusy = errors.New("kafka producer busy")
+
 type Option struct {
 	Brokers []string
 	Group   string
@@ -155,8 +159,10 @@ func (this *Producer) SendMessage(topic string, message []byte, keys ...string)
 	}
 
 	if _, _, err := this.producer.SendMessage(msg); err != nil {
+		countProduced(topic, RESULT_ERROR)
 		return err
 	}
+	countProduced(topic, RESULT_SUCCESS)
 
 	return nil
 }
@@ -195,6 +201,57 @@ type AsyncProducer struct {
 	onError   func(*sarama.ProducerError)
 	timeout   time.Duration
 	chClose   chan struct{}
+
+	// 最近一次发送成功与失败的时间(纳秒), 用于健康检查
+	lastSuccess int64
+	lastError   int64
+}
+
+// loop 处理发送结果
+func (this *AsyncProducer) loop(producer sarama.AsyncProducer, chClose chan struct{}) {
+	errors := producer.Errors()
+	success := producer.Successes()
+
+	for {
+		select {
+		case err := <-errors:
+			countProducerError(err)
+			atomic.StoreInt64(&this.lastError, time.Now().UnixNano())
+			if this.onError != nil {
+				this.onError(err)
+			}
+		case msg := <-success:
+			countProducerSuccess(msg)
+			atomic.StoreInt64(&this.lastSuccess, time.Now().UnixNano())
+			if this.onSuccess != nil {
+				this.onSuccess(msg)
+			}
+		case <-chClose:
+			return
+		}
+	}
+}
+
+// Check 健康检查, 最近一次发送结果为失败且发生在超时时间内时返回错误
+func (this *AsyncProducer) Check() error {
+	if this == nil || this.producer == nil {
+		return errors.New("producer not initialized")
+	}
+
+	lastErr := atomic.LoadInt64(&this.lastError)
+	if lastErr <= atomic.LoadInt64(&this.lastSuccess) {
+		return nil
+	}
+
+	window := this.timeout
+	if window < time.Minute {
+		window = time.Minute
+	}
+	if time.Since(time.Unix(0, lastErr)) > window {
+		return nil
+	}
+
+	return errors.New("last send failed")
 }
 
 func NewAsyncProducer(brokers []string, timeout time.Duration, onSuccess func(*sarama.ProducerMessage), onError func(*sarama.ProducerError)) (*AsyncProducer, error) {
@@ -209,28 +266,10 @@ func NewAsyncProducer(brokers []string, timeout time.Duration, onSuccess func(*s
 		return nil, errors.WithMessage(err, "new async producer failed")
 	}
 
-	chClose := make(chan struct{})
-
-	errors := producer.Errors()
-	success := producer.Successes()
-	go func() {
-		for {
-			select {
-			case err := <-errors:
-				if onError != nil {
-					onError(err)
-				}
-			case msg := <-success:
-				if onSuccess != nil {
-					onSuccess(msg)
-				}
-			case <-chClose:
-				return
-			}
-		}
-	}()
+	p := &AsyncProducer{producer: producer, onSuccess: onSuccess, onError: onError, timeout: timeout, chClose: make(chan struct{})}
+	go p.loop(producer, p.chClose)
 
-	return &AsyncProducer{producer: producer, onSuccess: onSuccess, onError: onError, timeout: timeout, chClose: chClose}, nil
+	return p, nil
 }
 
 func NewAsyncHashProducer(brokers []string, timeout time.Duration, onSuccess func(*sarama.ProducerMessage), onError func(*sarama.ProducerError)) (*AsyncProducer, error) {
@@ -246,28 +285,10 @@ func NewAsyncHashProducer(brokers []string, timeout time.Duration, onSuccess fun
 		return nil, errors.WithMessage(err, "new async producer failed")
 	}
 
-	chClose := make(chan struct{})
-
-	errors := producer.Errors()
-	success := producer.Successes()
-	go func() {
-		for {
-			select {
-			case err := <-errors:
-				if onError != nil {
-					onError(err)
-				}
-			case msg := <-success:
-				if onSuccess != nil {
-					onSuccess(msg)
-				}
-			case <-chClose:
-				return
-			}
-		}
-	}()
+	p := &AsyncProducer{producer: producer, onSuccess: onSuccess, onError: onError, timeout: timeout, chClose: make(chan struct{})}
+	go p.loop(producer, p.chClose)
 
-	return &AsyncProducer{producer: producer, onSuccess: onSuccess, onError: onError, timeout: timeout, chClose: chClose}, nil
+	return p, nil
 }
 
 func (this *AsyncProducer) SendMessage(topic string, message []byte, keys ...string) error {
@@ -286,6 +307,31 @@ func (this *AsyncProducer) SendMessage(topic string, message []byte, keys ...str
 	return nil
 }
 
+// TrySendMessage 非阻塞发送, 生产者输入队列已满时直接返回ErrProducerBusy
+func (this *AsyncProducer) TrySendMessage(topic string, message []byte, keys ...string) error {
+	msg := &sarama.ProducerMessage{}
+	msg.Topic = topic
+	msg.Value = sarama.ByteEncoder(message)
+	if len(keys) != 0 {
+		msg.Key = sarama.StringEncoder(keys[0])
+	}
+
+	return this.trySend(msg)
+}
+
+func (this *AsyncProducer) trySend(msg *sarama.ProducerMessage) error {
+	if this.producer == nil {
+		return errors.Errorf("please new producer first")
+	}
+
+	select {
+	case this.producer.Input() <- msg:
+		return nil
+	default:
+		return ErrProducerBusy
+	}
+}
+
 func (this *AsyncProducer) Reset(brokers []string) error {
 	if this == nil {
 		return errors.New("please new producer first")
@@ -308,24 +354,7 @@ func (this *AsyncProducer) Reset(brokers []string) error {
 	this.producer = producer
 	this.chClose = make(chan struct{})
 
-	errors := producer.Errors()
-	success := producer.Successes()
-	go func() {
-		for {
-			select {
-			case err := <-errors:
-				if this.onError != nil {
-					this.onError(err)
-				}
-			case msg := <-success:
-				if this.onSuccess != nil {
-					this.onSuccess(msg)
-				}
-			case <-this.chClose:
-				return
-			}
-		}
-	}()
+	go this.loop(producer, this.chClose)
 
 	return nil
 }
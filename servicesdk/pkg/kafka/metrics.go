@@ -0,0 +1,47 @@
+package kafka
+
+import (
+	"github.com/kkkkiven/fishpkg/metrics"
+
+	"github.com/Shopify/sarama"
+)
+
+// 生产结果标签
+const (
+	RESULT_SUCCESS = "success"
+	RESULT_ERROR   = "error"
+)
+
+var metricProduced = metrics.NewCounterVec("kafka_producer_messages_total",
+	"Messages produced to kafka by topic and result.", "topic", "result")
+
+var metricSinkDropped = metrics.NewCounterVec("kafka_log_sink_dropped_total",
+	"Log lines dropped by the kafka log sink because the producer queue was full.", "topic")
+
+// countSinkDropped 记录日志输出丢弃的行数
+func countSinkDropped(topic string) {
+	metricSinkDropped.WithLabelValues(topic).Inc()
+}
+
+// countProduced 记录生产结果
+func countProduced(topic, result string) {
+	metricProduced.WithLabelValues(topic, result).Inc()
+}
+
+// countProducerError 记录异步生产失败
+func countProducerError(err *sarama.ProducerError) {
+	if err == nil || err.Msg == nil {
+		return
+	}
+
+	countProduced(err.Msg.Topic, RESULT_ERROR)
+}
+
+// countProducerSuccess 记录异步生产成功
+func countProducerSuccess(msg *sarama.ProducerMessage) {
+	if msg == nil {
+		return
+	}
+
+	countProduced(msg.Topic, RESULT_SUCCESS)
+}
@@ -0,0 +1,63 @@
+package kafka
+
+import (
+	"strings"
+
+	"github.com/kkkkiven/fishpkg/logs"
+
+	"github.com/Shopify/sarama"
+)
+
+var _ logs.Sink = &LogSink{}
+
+// LogSink 将日志发送到kafka主题, 生产者由调用方创建与关闭
+// 日志写入协程只有一个, 生产者队列满时丢弃日志并计数, 不阻塞写入
+// 生产者的onSuccess/onError回调应通过IsLogSinkMessage忽略本输出的消息, 否则记录日志会再次写入本输出
+type LogSink struct {
+	producer *AsyncProducer
+	topic    string
+	key      string
+}
+
+// logSinkMeta 标记由LogSink发送的消息
+type logSinkMeta struct{}
+
+// IsLogSinkMessage 判断消息是否由LogSink发送
+func IsLogSinkMessage(msg *sarama.ProducerMessage) bool {
+	if msg == nil {
+		return false
+	}
+
+	_, ok := msg.Metadata.(logSinkMeta)
+	return ok
+}
+
+// NewLogSink 创建kafka日志输出, key用于分区, 为空时随机分区
+func NewLogSink(producer *AsyncProducer, topic, key string) *LogSink {
+	return &LogSink{producer: producer, topic: topic, key: key}
+}
+
+func (this *LogSink) Write(level int, line string) error {
+	msg := &sarama.ProducerMessage{}
+	msg.Topic = this.topic
+	msg.Value = sarama.ByteEncoder(strings.TrimRight(line, "\n"))
+	msg.Metadata = logSinkMeta{}
+	if this.key != "" {
+		msg.Key = sarama.StringEncoder(this.key)
+	}
+
+	if err := this.producer.trySend(msg); err != nil {
+		countSinkDropped(this.topic)
+		return err
+	}
+
+	return nil
+}
+
+func (this *LogSink) Flush() error {
+	return nil
+}
+
+func (this *LogSink) Close() error {
+	return nil
+}
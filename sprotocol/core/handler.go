@@ -6,7 +6,10 @@ import (
 	"reflect"
 	"runtime"
 	"runtime/debug"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/kkkkiven/fishpkg/logs"
 	pb "github.com/kkkkiven/fishpkg/sprotocol/core/spropb"
@@ -25,15 +28,40 @@ type wrapper struct {
 	name    string // 函数名称，用于全链路跟踪展示被调函数名称
 }
 
-var handlerMap map[uint16]wrapper
+// Router 消息路由, 持有函数id到处理函数的映射及中间件
+type Router struct {
+	mu         sync.RWMutex
+	handlerMap map[uint16]wrapper
+	middleware []func(Handler) Handler
+}
 
-func init() {
-	handlerMap = make(map[uint16]wrapper)
+// NewRouter 创建路由
+func NewRouter() *Router {
+	return &Router{
+		handlerMap: make(map[uint16]wrapper),
+		middleware: make([]func(Handler) Handler, 0),
+	}
+}
+
+var defaultRouter = NewRouter()
+
+// DefaultRouter 获取默认路由
+func DefaultRouter() *Router {
+	return defaultRouter
 }
 
 func routerHandler(ctx context.Context, so *Socket, msg *Message) error {
+	return so.getRouter().Dispatch(ctx, so, msg)
+}
+
+// Dispatch 分发消息到处理函数
+func (r *Router) Dispatch(ctx context.Context, so *Socket, msg *Message) error {
 	fid := msg.GetFunctionID()
-	hw, ok := handlerMap[fid]
+
+	r.mu.RLock()
+	hw, ok := r.handlerMap[fid]
+	r.mu.RUnlock()
+
 	if !ok {
 		rspMsg := &pb.RspCommon{}
 		rspMsg.Code = RC_HANDLER_NOT_FOUND
@@ -50,19 +78,24 @@ func routerHandler(ctx context.Context, so *Socket, msg *Message) error {
 		if span != nil {
 			span.Tag("code", RC_HANDLER_NOT_FOUND)
 			span.Tag("msg", M(RC_HANDLER_NOT_FOUND))
+			span.MarkError()
 			span.End()
 		}
 
 		return errors.Errorf("handler[%v] not found", fid)
 	}
 
-	go doCall(ctx, so, msg, hw.handler)
+	go doCall(ctx, so, msg, r.chain(hw.handler))
 	return nil
 }
 
-func doCall(ctx context.Context, so *Socket, msg *Message, handler Handler) {
+func doCall(ctx context.Context, so *Socket, msg *Message, h Handler) {
+	start := time.Now()
+
 	defer func() {
 		if err := recover(); nil != err {
+			observeHandler(msg.GetFunctionID(), start, "panic")
+
 			rspMsg := &pb.RspCommon{}
 			rspMsg.Code = RC_HANDLER_PANIC
 			rspMsg.Msg = M(RC_HANDLER_PANIC)
@@ -78,6 +111,7 @@ func doCall(ctx context.Context, so *Socket, msg *Message, handler Handler) {
 			if span != nil {
 				span.Tag("code", RC_HANDLER_PANIC)
 				span.Tag("msg", M(RC_HANDLER_PANIC))
+				span.MarkError()
 				span.End()
 			}
 
@@ -85,8 +119,8 @@ func doCall(ctx context.Context, so *Socket, msg *Message, handler Handler) {
 		}
 	}()
 
-	h := chain(handler)
 	h(ctx, so, msg)
+	observeHandler(msg.GetFunctionID(), start, "ok")
 
 	span := tracer.GetSpan(ctx)
 	if span != nil {
@@ -96,9 +130,17 @@ func doCall(ctx context.Context, so *Socket, msg *Message, handler Handler) {
 	}
 }
 
-// AddHandler 添加消息处理函数
+// AddHandler 添加消息处理函数到默认路由
 func AddHandler(funcID uint16, handler Handler) error {
-	if _, ok := handlerMap[funcID]; ok {
+	return defaultRouter.AddHandler(funcID, handler)
+}
+
+// AddHandler 添加消息处理函数
+func (r *Router) AddHandler(funcID uint16, handler Handler) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.handlerMap[funcID]; ok {
 		return errors.Errorf("handler[%v] already exists", funcID)
 	}
 
@@ -111,14 +153,41 @@ func AddHandler(funcID uint16, handler Handler) error {
 		name:    funcName,
 	}
 
-	handlerMap[funcID] = hw
+	r.handlerMap[funcID] = hw
 
 	return nil
 }
 
+// GetHandlerIDs 获取默认路由已注册的函数id列表
+func GetHandlerIDs() []uint16 {
+	return defaultRouter.GetHandlerIDs()
+}
+
+// GetHandlerIDs 获取已注册的函数id列表
+func (r *Router) GetHandlerIDs() []uint16 {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := make([]uint16, 0, len(r.handlerMap))
+	for id := range r.handlerMap {
+		ids = append(ids, id)
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+// GetHandlerName 获取函数id对应的处理函数名称, 未注册时返回空串
+func (r *Router) GetHandlerName(id uint16) string {
+	return r.getFuncName(id)
+}
+
 // getFuncName 获取函数id对应的函数名称
-func getFuncName(id uint16) string {
-	if hw, ok := handlerMap[id]; ok {
+func (r *Router) getFuncName(id uint16) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if hw, ok := r.handlerMap[id]; ok {
 		return hw.name
 	}
 	return ""
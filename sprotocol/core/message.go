@@ -35,6 +35,10 @@ const (
 	MF_PACKAGE
 )
 
+// MF_TRACE_DEFERRED 链路处于尾部采样中, 尚未决定是否保留, 接收方缓存span并按自身采样规则决定
+// 复用保留位, 旧版本接收方忽略该标志并按已采样处理
+const MF_TRACE_DEFERRED = MF_RESERVE
+
 type Message struct {
 	context interface{} // context: store customer data
 
@@ -388,6 +392,20 @@ func (this *Message) SetSpanID(id uint64) {
 	this.spanID = id
 }
 
+// IsTraceDeferred 链路是否处于尾部采样中
+func (this *Message) IsTraceDeferred() bool {
+	return this.msgFlag&MF_TRACE_DEFERRED != 0
+}
+
+// SetTraceDeferred 标记链路处于尾部采样中
+func (this *Message) SetTraceDeferred(deferred bool) {
+	if deferred {
+		this.msgFlag |= MF_TRACE_DEFERRED
+	} else {
+		this.msgFlag &^= MF_TRACE_DEFERRED
+	}
+}
+
 // GetBody 获取消息体
 func (this *Message) GetBody() []byte {
 	return this.msgBody
@@ -0,0 +1,44 @@
+package core
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/kkkkiven/fishpkg/metrics"
+)
+
+var (
+	metricBytes = metrics.NewCounterVec("sprotocol_socket_bytes_total",
+		"Bytes read from and written to core sockets.", "direction")
+	metricMessages = metrics.NewCounterVec("sprotocol_socket_messages_total",
+		"Messages received and sent by core sockets.", "direction", "type")
+	metricDecodeErrors = metrics.NewCounterVec("sprotocol_socket_decode_errors_total",
+		"Messages that failed to decode.")
+	metricHandlerSeconds = metrics.NewHistogramVec("sprotocol_handler_duration_seconds",
+		"Handler latency by function id.", nil, "func_id", "result")
+)
+
+// 指标方向标签
+const (
+	METRIC_IN  = "in"
+	METRIC_OUT = "out"
+)
+
+// msgTypeName 消息类型标签
+func msgTypeName(t byte) string {
+	switch t {
+	case MT_NORMAL:
+		return "normal"
+	case MT_REQUEST:
+		return "request"
+	case MT_RESPONSE:
+		return "response"
+	}
+
+	return "unknown"
+}
+
+// observeHandler 记录处理函数耗时
+func observeHandler(funcId uint16, start time.Time, result string) {
+	metricHandlerSeconds.WithLabelValues(strconv.Itoa(int(funcId)), result).Observe(time.Since(start).Seconds())
+}
@@ -1,17 +1,23 @@
 package core
 
-var middleware []func(Handler) Handler
-
-func init() {
-	middleware = make([]func(Handler) Handler, 0)
+// Use 添加中间件到默认路由
+func Use(mws ...func(Handler) Handler) {
+	defaultRouter.Use(mws...)
 }
 
 // Use 添加中间件
-func Use(mws ...func(Handler) Handler) {
-	middleware = append(middleware, mws...)
+func (r *Router) Use(mws ...func(Handler) Handler) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.middleware = append(r.middleware, mws...)
 }
 
-func chain(endpoint Handler) Handler {
+func (r *Router) chain(endpoint Handler) Handler {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	middleware := r.middleware
 	if len(middleware) == 0 {
 		return endpoint
 	}
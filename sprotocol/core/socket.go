@@ -53,6 +53,8 @@ type Socket struct {
 	notify     Notify
 	filter     Filter
 	msgHandler func(context.Context, *Socket, *Message)
+	router     *Router
+	tracer     *tracer.Tracer
 
 	isWebsocket bool
 	remoteIP    uint32
@@ -69,6 +71,9 @@ type Socket struct {
 
 var socketID uint64
 
+// ErrSendTimeout 等待响应超时
+var ErrSendTimeout = errors.New("send timeout")
+
 type option func(so *Socket)
 
 // NewSocket
@@ -154,6 +159,20 @@ func SetMsgHandler(handler func(context.Context, *Socket, *Message)) option {
 	}
 }
 
+// SetRouter 设置消息路由, 未设置时使用默认路由
+func SetRouter(r *Router) option {
+	return func(so *Socket) {
+		so.router = r
+	}
+}
+
+// SetTracer 设置链路追踪器, 未设置时使用默认追踪器
+func SetTracer(tr *tracer.Tracer) option {
+	return func(so *Socket) {
+		so.tracer = tr
+	}
+}
+
 // SetRemoteIP 设置远程ip
 func SetRemoteIP(ip uint32) option {
 	return func(so *Socket) {
@@ -256,6 +275,24 @@ func (this *Socket) SetMsgHandler(handler func(context.Context, *Socket, *Messag
 	this.msgHandler = handler
 }
 
+// getRouter 获取消息路由, 路由仅在创建时设置, 无需加锁
+func (this *Socket) getRouter() *Router {
+	if this.router != nil {
+		return this.router
+	}
+
+	return defaultRouter
+}
+
+// getTracer 获取链路追踪器, 追踪器仅在创建时设置, 无需加锁
+func (this *Socket) getTracer() *tracer.Tracer {
+	if this.tracer != nil {
+		return this.tracer
+	}
+
+	return tracer.Default()
+}
+
 // SetRemoteIP 设置远程ip
 func (this *Socket) SetRemoteIP(ip uint32) {
 	this.mu.Lock()
@@ -358,6 +395,7 @@ func (this *Socket) readLoop() {
 		}
 
 		logs.Tracef("- %v - ->> READ(%v bytes): %v", this.conn.RemoteAddr().String(), size, rd[:size])
+		metricBytes.WithLabelValues(METRIC_IN).Add(float64(size))
 
 		buf = append(buf, rd[:size]...)
 
@@ -376,12 +414,14 @@ func (this *Socket) readLoop() {
 			)
 			buf, msg, err = Decode(buf[:])
 			if err != nil {
+				metricDecodeErrors.WithLabelValues().Inc()
 				logs.Errorf("- %v - Decode message err: %s", this.conn.RemoteAddr().String(), err.Error())
 				return
 			}
 			if msg == nil {
 				break
 			}
+			metricMessages.WithLabelValues(METRIC_IN, msgTypeName(msg.GetMessageType())).Inc()
 
 			if this.filter != nil {
 				if this.filter.OnMessage(this, msg) {
@@ -426,7 +466,11 @@ func (this *Socket) dispatch(msg *Message) {
 		ctx  context.Context
 	)
 	if (msg.GetMessageFlag() & MF_TRACE) != 0 {
-		span, ctx = tracer.CreateSpan(int64(msg.GetTraceID()), int64(msg.GetSpanID()))
+		if msg.IsTraceDeferred() {
+			span, ctx = this.getTracer().CreateDeferredSpan(int64(msg.GetTraceID()), int64(msg.GetSpanID()))
+		} else {
+			span, ctx = this.getTracer().CreateSpan(int64(msg.GetTraceID()), int64(msg.GetSpanID()))
+		}
 		span.SetRemoteEndpoint("", msg.GetFromSvrType(), msg.GetFromSvrID(), "", 0)
 		span.Tag("funcId", msg.GetFunctionID())
 	}
@@ -484,6 +528,7 @@ func (this *Socket) post(data []byte) error {
 			}
 
 			this.lastWriteTime = time.Now().Unix()
+			metricBytes.WithLabelValues(METRIC_OUT).Add(float64(size))
 
 			pos += size
 
@@ -510,10 +555,18 @@ func (this *Socket) Send(ctx context.Context, msg *Message) (*Message, error) {
 func (this *Socket) sendTimeout(ctx context.Context, msg *Message, tmout int64) (rsp *Message, err error) {
 	if msg.GetMessageType() == MT_RESPONSE || msg.GetMessageType() == MT_NORMAL {
 		err = this.post(msg.Encode())
+		if err == nil {
+			metricMessages.WithLabelValues(METRIC_OUT, msgTypeName(msg.GetMessageType())).Inc()
+		}
 		return
 	}
 
-	span, _ := tracer.CreateSubSpan(ctx)
+	var span *tracer.Span
+	if parent := tracer.GetSpan(ctx); parent != nil {
+		span, _ = tracer.CreateFuncSubSpan(ctx, msg.GetFunctionID())
+	} else {
+		span, _ = this.getTracer().CreateFuncSubSpan(ctx, msg.GetFunctionID())
+	}
 
 	waitCh := make(chan *Message, 1)
 	defer close(waitCh)
@@ -535,19 +588,24 @@ func (this *Socket) sendTimeout(ctx context.Context, msg *Message, tmout int64)
 	if span != nil {
 		span.SetRemoteEndpoint("", msg.GetToSvrType(), msg.GetToSvrID(), "", 0)
 		span.Tag("funcId", msg.GetFunctionID())
+
+		// 尾部采样中的链路同样向下游传播, 由下游缓存span等待决策
 		msg.SetTraceID(uint64(span.GetTraceID()))
 		msg.SetSpanID(uint64(span.GetSpanID()))
+		msg.SetTraceDeferred(!span.IsSampled())
 	}
 
 	if err = this.post(msg.Encode()); err != nil {
 		if span != nil {
 			span.Tag("code", RC_SYS_ERR)
 			span.Tag("msg", err.Error())
+			span.MarkError()
 			span.End()
 		}
 
 		return
 	}
+	metricMessages.WithLabelValues(METRIC_OUT, msgTypeName(msg.GetMessageType())).Inc()
 
 	select {
 	case rsp = <-waitCh:
@@ -557,6 +615,7 @@ func (this *Socket) sendTimeout(ctx context.Context, msg *Message, tmout int64)
 				if span != nil {
 					span.Tag("code", rspMsg.Code)
 					span.Tag("msg", rspMsg.Msg)
+					span.MarkError()
 					span.End()
 				}
 
@@ -575,10 +634,11 @@ func (this *Socket) sendTimeout(ctx context.Context, msg *Message, tmout int64)
 		if span != nil {
 			span.Tag("code", RC_TIMEOUT)
 			span.Tag("msg", M(RC_TIMEOUT))
+			span.MarkError()
 			span.End()
 		}
 
-		err = errors.Errorf("send timeout")
+		err = ErrSendTimeout
 	}
 
 	return
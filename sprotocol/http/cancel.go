@@ -0,0 +1,80 @@
+package http
+
+import (
+	"context"
+)
+
+// openCall 为请求创建可取消的context, 需在读协程中调用以免先于注册到达的MT_CANCEL丢失
+func (this *Socket) openCall(reqID uint32) context.Context {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	this.callLocker.Lock()
+	this.calls[reqID] = cancel
+	this.callLocker.Unlock()
+
+	return ctx
+}
+
+// closeCall 请求处理结束后释放context
+func (this *Socket) closeCall(reqID uint32) {
+	this.callLocker.Lock()
+	cancel, ok := this.calls[reqID]
+	delete(this.calls, reqID)
+	this.callLocker.Unlock()
+
+	if ok {
+		cancel()
+	}
+}
+
+// cancelCall 对端取消请求, 取消handler的context并中断请求体读取
+func (this *Socket) cancelCall(msg *Message) {
+	reqID := msg.GetRequestID()
+
+	this.callLocker.Lock()
+	cancel, ok := this.calls[reqID]
+	this.callLocker.Unlock()
+
+	if !ok {
+		return
+	}
+
+	cancel()
+
+	this.streamLocker.Lock()
+	b, ok := this.streams[reqID]
+	this.streamLocker.Unlock()
+
+	if ok {
+		b.fail(context.Canceled)
+	}
+}
+
+// abortCalls 连接断开时取消全部未完成的请求
+func (this *Socket) abortCalls() {
+	this.callLocker.Lock()
+	defer this.callLocker.Unlock()
+
+	for _, cancel := range this.calls {
+		cancel()
+	}
+}
+
+// _CallContext 取值于链路追踪context, 取消信号及截止时间来自调用context
+type _CallContext struct {
+	context.Context
+	values context.Context
+}
+
+// mergeContext 合并取消信号与链路追踪信息
+func mergeContext(call, values context.Context) context.Context {
+	return &_CallContext{Context: call, values: values}
+}
+
+func (this *_CallContext) Value(key interface{}) interface{} {
+	if v := this.values.Value(key); v != nil {
+		return v
+	}
+
+	return this.Context.Value(key)
+}
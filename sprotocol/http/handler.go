@@ -2,17 +2,16 @@ package http
 
 import (
 	"context"
-	"encoding/binary"
-	"encoding/hex"
 	"fmt"
 	"net/http"
 	"runtime/debug"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
 	"github.com/kkkkiven/fishpkg/utils"
 
-	xutils "github.com/kkkkiven/fishpkg/servicesdk/pkg/utils"
 	"github.com/kkkkiven/fishpkg/sprotocol/tracer"
 
 	"github.com/kkkkiven/fishpkg/logs"
@@ -47,13 +46,16 @@ func (this HandlerFunc) Serve(ctx context.Context, rw http.ResponseWriter, r *ht
 	this(ctx, rw, r, params)
 }
 
+// METHOD_ANY 匹配全部请求方法
+const METHOD_ANY = "*"
+
 var (
-	tree *node
-	mu   sync.RWMutex
+	trees map[string]*node // 按请求方法划分的路由树
+	mu    sync.RWMutex
 )
 
 func init() {
-	tree = new(node)
+	trees = make(map[string]*node, 0)
 }
 
 type Wrapper struct {
@@ -61,8 +63,18 @@ type Wrapper struct {
 	Handler func(context.Context, http.ResponseWriter, *http.Request, Params)
 }
 
-// AddHandler 添加hander
+// AddHandler 添加hander, 匹配全部请求方法
 func AddHandler(path string, handler func(context.Context, http.ResponseWriter, *http.Request, Params), groups ...string) {
+	Handle(METHOD_ANY, path, handler, groups...)
+}
+
+// Handle 添加指定请求方法的handler, method为空或METHOD_ANY时匹配全部请求方法
+func Handle(method, path string, handler func(context.Context, http.ResponseWriter, *http.Request, Params), groups ...string) {
+	method = strings.ToUpper(method)
+	if method == "" {
+		method = METHOD_ANY
+	}
+
 	mu.Lock()
 	defer mu.Unlock()
 
@@ -70,14 +82,81 @@ func AddHandler(path string, handler func(context.Context, http.ResponseWriter,
 	w.Groups = append(w.Groups, groups...)
 	w.Handler = handler
 
-	tree.addRoute(path, w)
+	root := trees[method]
+	if root == nil {
+		root = new(node)
+		trees[method] = root
+	}
+
+	root.addRoute(path, w)
+}
+
+// hasPath 路径是否在任一请求方法下注册
+func hasPath(path string) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	for _, root := range trees {
+		if w, _, _ := root.getValue(path); w != nil && w.Handler != nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// lookup 按请求方法查找handler, HEAD未注册时使用GET的handler; 未找到时返回该路径允许的请求方法
+func lookup(method, path string) (*Wrapper, Params, []string) {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	methods := []string{method}
+	if method == http.MethodHead {
+		methods = append(methods, http.MethodGet)
+	}
+	methods = append(methods, METHOD_ANY)
+
+	for _, m := range methods {
+		if root := trees[m]; root != nil {
+			if w, ps, _ := root.getValue(path); w != nil && w.Handler != nil {
+				return w, ps, nil
+			}
+		}
+	}
+
+	var allow []string
+	for m, root := range trees {
+		if w, _, _ := root.getValue(path); w != nil && w.Handler != nil {
+			allow = append(allow, m)
+		}
+	}
+	if len(allow) == 0 {
+		return nil, nil, nil
+	}
+
+	has := func(m string) bool {
+		for _, a := range allow {
+			if a == m {
+				return true
+			}
+		}
+		return false
+	}
+	if has(http.MethodGet) && !has(http.MethodHead) {
+		allow = append(allow, http.MethodHead)
+	}
+	if !has(http.MethodOptions) {
+		allow = append(allow, http.MethodOptions)
+	}
+	sort.Strings(allow)
+
+	return nil, nil, allow
 }
 
 // routerHandler 路由：/server/iface
 func routerHandler(so *Socket, msg *Message) error {
 	p := msg.GetStringExData()
-	w, ps, _ := tree.getValue(p)
-	if w == nil || w.Handler == nil {
+	if !hasPath(p) {
 		rsp := NewResponseMessage()
 		rsp.SetRequestID(msg.GetRequestID())
 		rsp.SetStatus(RC_HANDLER_NOT_FOUND)
@@ -85,24 +164,36 @@ func routerHandler(so *Socket, msg *Message) error {
 		return errors.New(fmt.Sprintf("Handler[%v] not found", p))
 	}
 
+	// 请求体以MT_STREAM分片发送时, 需在读协程中注册以免丢失后续分片
+	var body *_BodyStream
+	if msg.GetMessageFlag()&MF_STREAM != 0 {
+		body = so.openStream(msg.GetRequestID())
+	}
+
+	// 同样在读协程中注册, 以便处理随后到达的MT_CANCEL
+	call := so.openCall(msg.GetRequestID())
+
 	// 调用函数
-	go doCall(w, ps, so, msg)
+	go doCall(call, so, msg, body)
 
 	return nil
 }
 
-// doCall 执行调用过程
-func doCall(w *Wrapper, params Params, so *Socket, msg *Message) {
+// doCall 执行调用过程, body不为空时请求消息中仅包含请求头, 请求体由body流式读取
+// call在对端取消请求或连接断开时结束, handler的context随之取消
+func doCall(call context.Context, so *Socket, msg *Message, body *_BodyStream) {
 	var (
-		h   Handler
 		r   *http.Request
 		rw  *ResponseWriter
 		req *HTTPRequest = &HTTPRequest{}
 		err error
 	)
 
-	// 构造调用链
-	h = chain(HandlerFunc(w.Handler), w.Groups...)
+	defer so.closeCall(msg.GetRequestID())
+
+	if body != nil {
+		defer so.closeStream(msg.GetRequestID())
+	}
 
 	// 调用过程
 	if _, err = req.UnmarshalMsg(msg.GetBody()); err != nil {
@@ -123,9 +214,60 @@ func doCall(w *Wrapper, params Params, so *Socket, msg *Message) {
 		return
 	}
 
+	if body != nil {
+		r.Body = body
+	}
+
 	rw = NewResponseWriter()
-	ctx, span := genSpan(r)
+	rw.bind(so, msg.GetRequestID(), r)
+	ctx, span := genSpan(r, rw)
+	ctx = mergeContext(call, ctx)
+	r = r.WithContext(ctx)
 	defer func() {
+		// 已升级的连接由handler关闭, 不再发送响应
+		if rw.Hijacked() {
+			if err := recover(); err != nil {
+				rw.conn.Close()
+
+				hint := fmt.Sprintf("Panic: %+v\n%s", err, string(debug.Stack()))
+				span.Tag("msg", hint)
+				span.MarkError()
+				logs.Errorf("- %v - %s", so.GetConn().RemoteAddr().String(), hint)
+			}
+			span.Tag("code", http.StatusSwitchingProtocols)
+			span.End()
+			return
+		}
+
+		// 已流式发送响应头时, 以最后一个分片结束响应
+		if rw.Flushed() {
+			status := byte(RC_OK)
+			if err := recover(); err != nil {
+				status = RC_HANDLER_PANIC
+
+				hint := fmt.Sprintf("Panic: %+v\n%s", err, string(debug.Stack()))
+				span.Tag("msg", hint)
+				span.MarkError()
+				logs.Errorf("- %v - %s", so.GetConn().RemoteAddr().String(), hint)
+			}
+
+			if err := rw.finish(status); err != nil {
+				span.Tag("msg", err.Error())
+				span.MarkError()
+			}
+			span.Tag("code", rw.Status)
+			span.End()
+			return
+		}
+
+		// HEAD请求不返回响应体
+		if r.Method == http.MethodHead && len(rw.Body) != 0 {
+			if rw.Header().Get("Content-Length") == "" {
+				rw.Header().Set("Content-Length", strconv.Itoa(len(rw.Body)))
+			}
+			rw.Body = rw.Body[:0]
+		}
+
 		body, _ := rw.MarshalMsg(nil)
 		rsp := NewResponseMessage()
 		rsp.SetRequestID(msg.GetRequestID())
@@ -137,67 +279,106 @@ func doCall(w *Wrapper, params Params, so *Socket, msg *Message) {
 			hint := fmt.Sprintf("Panic: %+v\n%s", err, string(debug.Stack()))
 			span.Tag("msg", hint)
 			span.Tag("code", http.StatusInternalServerError)
+			span.MarkError()
 			span.End()
 
 			logs.Errorf("- %v - %s", so.GetConn().RemoteAddr().String(), hint)
 		} else {
 			span.Tag("code", rw.Status)
+			if rw.Status >= http.StatusInternalServerError {
+				span.MarkError()
+			}
 			span.End()
 		}
 
+		// 对端已取消请求, 不再返回响应
+		if call.Err() != nil {
+			logs.Debugf("- %v - Request[%v] canceled", so.GetConn().RemoteAddr().String(), msg.GetRequestID())
+			return
+		}
+
 		so.Send(rsp)
 	}()
 
-	h.Serve(ctx, rw, r, params)
+	serve(ctx, rw, r, msg.GetStringExData())
 }
 
-func genSpan(r *http.Request) (ctx context.Context, span *tracer.Span) {
-	var tid, pid uint64
+// serve 按请求方法路由并执行调用链, 未找到handler时自动响应404、405或OPTIONS
+func serve(ctx context.Context, rw http.ResponseWriter, r *http.Request, path string) {
+	w, params, allow := lookup(r.Method, path)
+	if w == nil {
+		// 自动响应同样经过全局中间件, 以便跨域等中间件处理OPTIONS请求
+		h := chain(HandlerFunc(func(ctx context.Context, rw http.ResponseWriter, r *http.Request, _ Params) {
+			switch {
+			case len(allow) == 0:
+				http.NotFound(rw, r)
+			case r.Method == http.MethodOptions:
+				// 自动响应OPTIONS请求
+				rw.Header().Set("Allow", strings.Join(allow, ", "))
+				rw.WriteHeader(http.StatusOK)
+			default:
+				rw.Header().Set("Allow", strings.Join(allow, ", "))
+				http.Error(rw, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			}
+		}))
 
-	tids := r.Header.Get(strings.ToUpper(X_TRACE_ID))
-	if tids != "" {
-		if len(tids) < 32 {
-			tids = xutils.Get16MD5Encode(tids)
-		} else {
-			tids = tids[8:24]
-		}
+		h.Serve(ctx, rw, r, nil)
+		return
+	}
 
-		tidb, err := hex.DecodeString(tids)
-		if err == nil && len(tidb) > 0 {
-			tid = binary.BigEndian.Uint64(tidb)
-		}
+	// 构造调用链
+	h := chain(HandlerFunc(w.Handler), w.Groups...)
+
+	h.Serve(ctx, rw, r, params)
+}
+
+// genSpan 解析上游链路信息并创建span, 上游已采样时强制采样, 未采样时不创建
+func genSpan(r *http.Request, rw http.ResponseWriter) (context.Context, *tracer.Span) {
+	tc, ok := extractTrace(r.Header)
+	if !ok {
+		return context.TODO(), nil
 	}
 
-	pids := r.Header.Get(strings.ToUpper(X_SPAN_ID))
-	if pids != "" {
-		if len(pids) < 32 {
-			pids = xutils.Get16MD5Encode(pids)
-		} else {
-			pids = pids[8:24]
-		}
+	var (
+		span *tracer.Span
+		ctx  context.Context
+	)
 
-		pidb, err := hex.DecodeString(pids)
-		if err == nil && len(pidb) > 0 {
-			pid = binary.BigEndian.Uint64(pidb)
-		}
+	switch tc.sampled {
+	case SAMPLED_ACCEPT:
+		span, ctx = tracer.CreateSpan(int64(tc.traceId), int64(tc.spanId))
+	case SAMPLED_DENY:
+		return tracer.NoopContext(), nil
+	default:
+		span, ctx = tracer.CreateProbSpan(int64(tc.traceId), int64(tc.spanId))
 	}
 
-	if tid != 0 {
-		span, _ = tracer.CreateProbSpan(int64(tid), int64(pid))
-		span.Tag("method", r.Method)
-		span.Tag("path", r.RequestURI)
-		addrs := strings.Split(r.RemoteAddr, ":")
-		if len(addrs) == 2 {
-			project := r.Header.Get(strings.ToUpper(X_PROJECT_NAME))
-			if project == "" {
-				project = "nginx"
-			}
+	if span == nil {
+		return ctx, nil
+	}
 
-			span.SetRemoteEndpoint(project, 0, 0, addrs[0], uint16(utils.AtoUi(addrs[1])))
+	span.SetTraceIdHigh(int64(tc.traceHigh))
+	span.Tag("method", r.Method)
+	span.Tag("path", r.RequestURI)
+	addrs := strings.Split(r.RemoteAddr, ":")
+	if len(addrs) == 2 {
+		project := r.Header.Get(X_PROJECT_NAME)
+		if project == "" {
+			project = "nginx"
 		}
 
-		_, ctx = tracer.CreatePropagateSpan(int64(tid), span.GetSpanID())
+		span.SetRemoteEndpoint(project, 0, 0, addrs[0], uint16(utils.AtoUi(addrs[1])))
 	}
 
-	return context.TODO(), nil
+	injectTrace(rw.Header(), tc, uint64(span.GetSpanID()), span.IsSampled())
+
+	return context.WithValue(span.PropagateContext(), ctxSpanKey{}, span), span
+}
+
+type ctxSpanKey struct{}
+
+// SpanFromContext 获取handler context中当前请求的span, 未采样时返回nil
+func SpanFromContext(ctx context.Context) *tracer.Span {
+	span, _ := ctx.Value(ctxSpanKey{}).(*tracer.Span)
+	return span
 }
@@ -0,0 +1,104 @@
+package http
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/kkkkiven/fishpkg/logs"
+
+	"github.com/pkg/errors"
+)
+
+// LocalHandler 本地模式, 不经网关直接以net/http处理请求, 路由、中间件、链路追踪及panic恢复与网关模式一致
+type LocalHandler struct{}
+
+// NewLocalHandler 创建本地模式handler
+func NewLocalHandler() *LocalHandler {
+	return &LocalHandler{}
+}
+
+func (this *LocalHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rw := &_LocalWriter{ResponseWriter: w}
+
+	ctx, span := genSpan(r, rw)
+	// 客户端断开时取消handler的context
+	ctx = mergeContext(r.Context(), ctx)
+	r = r.WithContext(ctx)
+	defer func() {
+		if err := recover(); err != nil {
+			hint := fmt.Sprintf("Panic: %+v\n%s", err, string(debug.Stack()))
+			span.Tag("msg", hint)
+			span.Tag("code", http.StatusInternalServerError)
+			span.MarkError()
+			span.End()
+
+			logs.Errorf("- %v - %s", r.RemoteAddr, hint)
+
+			if !rw.wrote {
+				http.Error(rw, M(RC_HANDLER_PANIC), http.StatusInternalServerError)
+			}
+			return
+		}
+
+		span.Tag("code", rw.status)
+		if rw.status >= http.StatusInternalServerError {
+			span.MarkError()
+		}
+		span.End()
+	}()
+
+	serve(ctx, rw, r, r.URL.Path)
+}
+
+// _LocalWriter 记录响应状态码
+type _LocalWriter struct {
+	http.ResponseWriter
+	status int
+	wrote  bool
+}
+
+func (this *_LocalWriter) WriteHeader(statusCode int) {
+	if !this.wrote {
+		this.status = statusCode
+		this.wrote = true
+	}
+
+	this.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (this *_LocalWriter) Write(p []byte) (int, error) {
+	if !this.wrote {
+		this.WriteHeader(http.StatusOK)
+	}
+
+	return this.ResponseWriter.Write(p)
+}
+
+// Flush 实现http.Flusher
+func (this *_LocalWriter) Flush() {
+	if f, ok := this.ResponseWriter.(http.Flusher); ok {
+		if !this.wrote {
+			this.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+// Hijack 实现http.Hijacker, 以支持WebSocket等协议升级
+func (this *_LocalWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := this.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack not supported")
+	}
+
+	conn, brw, err := h.Hijack()
+	if err == nil && !this.wrote {
+		this.status = http.StatusSwitchingProtocols
+		this.wrote = true
+	}
+
+	return conn, brw, err
+}
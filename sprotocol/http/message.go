@@ -17,12 +17,17 @@ const (
 	MT_RESPONSE
 	MT_PING
 	MT_PONG
+	MT_STREAM // 消息体分片, 与请求或响应消息的请求id相同
+	MT_CANCEL // 取消请求, 与被取消的请求消息的请求id相同
 )
 
 // message flags
 const (
 	MF_ENCODE byte = 1 << iota
 	MF_COMPRESS
+	MF_STREAM  // 消息体未结束, 后续以MT_STREAM分片发送
+	MF_EOF     // 最后一个MT_STREAM分片
+	MF_UPGRADE // 响应消息: 连接已升级(如WebSocket), 此后双向的MT_STREAM分片为连接上的原始数据, 接收方需在读协程中即建立转发
 )
 
 type Message struct {
@@ -30,12 +35,12 @@ type Message struct {
 	m1      byte   // magic word one '#'
 	m2      byte   // magic word two '@'
 	version byte   // protocol version
-	msgType byte   // message type: normal, request, response, ping, pong
-	msgFlag byte   // flag: encode, compress,
+	msgType byte   // message type: normal, request, response, ping, pong, stream, cancel
+	msgFlag byte   // flag: encode, compress, stream, eof, upgrade
 	reqID   uint32 // request id
 	bodyLen uint32 // body length
 
-	status byte // response status, ref for response message
+	status byte // response status, ref for response and stream message
 
 	// route head
 	exLen  uint16 // extended data length, ref for request message
@@ -84,6 +89,18 @@ func NewPongMessage() *Message {
 	return msg
 }
 
+// NewCancelMessage 创建取消请求消息
+func NewCancelMessage() *Message {
+	msg := NewMessage(MT_CANCEL)
+	return msg
+}
+
+// NewStreamMessage 创建消息体分片
+func NewStreamMessage() *Message {
+	msg := NewMessage(MT_STREAM)
+	return msg
+}
+
 // Decode 解码
 func Decode(buf []byte) ([]byte, *Message) {
 	pos := 0
@@ -135,6 +152,8 @@ func Decode(buf []byte) ([]byte, *Message) {
 		}
 		msg.exData = buf[pos : pos+int(msg.exLen)]
 		pos += int(msg.exLen)
+	case MT_STREAM:
+		fallthrough
 	case MT_RESPONSE:
 		if size-pos < 1 {
 			return buf, nil
@@ -143,6 +162,7 @@ func Decode(buf []byte) ([]byte, *Message) {
 		pos += 1
 	case MT_PING:
 	case MT_PONG:
+	case MT_CANCEL:
 	default:
 		return buf[2:], nil
 	}
@@ -174,10 +194,13 @@ func (this *Message) Encode() []byte {
 		if len(this.exData) > 0 {
 			buf = append(buf, this.exData[:]...)
 		}
+	case MT_STREAM:
+		fallthrough
 	case MT_RESPONSE:
 		buf = put8bit(buf, this.status)
 	case MT_PING:
 	case MT_PONG:
+	case MT_CANCEL:
 	default:
 		return nil
 	}
@@ -0,0 +1,36 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/kkkkiven/fishpkg/logs"
+	p "github.com/kkkkiven/fishpkg/sprotocol/http"
+)
+
+var accessLog = logs.New("sprotocol/http/access")
+
+// AccessLog 以INFO级别记录访问日志, 包含请求方法、路径、状态码、响应大小、耗时及客户端ip,
+// 可通过logs.SetPackageLevel("sprotocol/http/access", ...)单独调整级别
+func AccessLog() func(p.Handler) p.Handler {
+	return func(next p.Handler) p.Handler {
+		return p.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request, ps p.Params) {
+			start := time.Now()
+			rw := &_ResponseWriter{ResponseWriter: w}
+
+			defer func() {
+				accessLog.Ctx(ctx).Info("access",
+					logs.String("method", r.Method),
+					logs.String("path", r.URL.RequestURI()),
+					logs.Int("status", rw.Status()),
+					logs.Int("size", rw.size),
+					logs.Duration("latency", time.Since(start)),
+					logs.String("ip", ClientIP(r)),
+				)
+			}()
+
+			next.Serve(ctx, rw, r, ps)
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+
+	p "github.com/kkkkiven/fishpkg/sprotocol/http"
+)
+
+// BodyLimit 限制请求体大小, Content-Length超过limit时直接以413拒绝, 否则读取超过limit时返回错误
+func BodyLimit(limit int64) func(p.Handler) p.Handler {
+	return func(next p.Handler) p.Handler {
+		return p.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request, ps p.Params) {
+			if r.ContentLength > limit {
+				writeError(ctx, w, r, http.StatusRequestEntityTooLarge, "")
+				return
+			}
+
+			if r.Body != nil {
+				r.Body = http.MaxBytesReader(w, r.Body, limit)
+			}
+
+			next.Serve(ctx, w, r, ps)
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package middleware
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	p "github.com/kkkkiven/fishpkg/sprotocol/http"
+)
+
+// CORSConfig 跨域配置
+type CORSConfig struct {
+	AllowOrigins     []string // 允许的来源, "*"为全部(不能与AllowCredentials同时使用), 支持"*.example.com"形式的子域名通配
+	AllowMethods     []string // 为空时使用GET、POST、PUT、DELETE、PATCH、HEAD
+	AllowHeaders     []string // 为空时允许预检请求中声明的全部请求头
+	ExposeHeaders    []string
+	AllowCredentials bool
+	MaxAge           time.Duration // 预检结果缓存时间
+}
+
+var defaultCORSMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete, http.MethodPatch, http.MethodHead,
+}
+
+// CORS 跨域处理, 预检请求直接响应204; 需通过p.Use注册为全局中间件, 以处理路由自动响应的OPTIONS请求
+// AllowOrigins包含"*"且AllowCredentials为true时panic, 携带凭证的跨域请求需明确列出来源
+func CORS(c CORSConfig) func(p.Handler) p.Handler {
+	wildcard := false
+	for _, o := range c.AllowOrigins {
+		if o == "*" {
+			wildcard = true
+		}
+	}
+	if wildcard && c.AllowCredentials {
+		panic("cors: AllowOrigins \"*\" cannot be used with AllowCredentials")
+	}
+
+	methods := c.AllowMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	allowMethods := strings.Join(methods, ", ")
+	allowHeaders := strings.Join(c.AllowHeaders, ", ")
+	exposeHeaders := strings.Join(c.ExposeHeaders, ", ")
+
+	return func(next p.Handler) p.Handler {
+		return p.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request, ps p.Params) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.Serve(ctx, w, r, ps)
+				return
+			}
+
+			h := w.Header()
+			h.Add("Vary", "Origin")
+
+			if !c.allowOrigin(origin) {
+				if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+					writeError(ctx, w, r, http.StatusForbidden, "")
+					return
+				}
+				next.Serve(ctx, w, r, ps)
+				return
+			}
+
+			if wildcard {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if c.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			// 预检请求
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+				h.Set("Access-Control-Allow-Methods", allowMethods)
+
+				if allowHeaders != "" {
+					h.Set("Access-Control-Allow-Headers", allowHeaders)
+				} else if req := r.Header.Get("Access-Control-Request-Headers"); req != "" {
+					h.Set("Access-Control-Allow-Headers", req)
+				}
+
+				if c.MaxAge > 0 {
+					h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+				}
+
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			if exposeHeaders != "" {
+				h.Set("Access-Control-Expose-Headers", exposeHeaders)
+			}
+
+			next.Serve(ctx, w, r, ps)
+		})
+	}
+}
+
+func (c *CORSConfig) allowOrigin(origin string) bool {
+	for _, o := range c.AllowOrigins {
+		switch {
+		case o == "*" || strings.EqualFold(o, origin):
+			return true
+		case strings.HasPrefix(o, "*."):
+			// 匹配scheme之后的域名部分
+			host := origin
+			if i := strings.Index(host, "://"); i >= 0 {
+				host = host[i+3:]
+			}
+			if strings.HasSuffix(strings.ToLower(host), strings.ToLower(o[1:])) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,155 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+
+	p "github.com/kkkkiven/fishpkg/sprotocol/http"
+)
+
+// DEFAULT_GZIP_MIN_SIZE 响应体小于该大小时不压缩
+const DEFAULT_GZIP_MIN_SIZE = 1024
+
+// Gzip 客户端支持时gzip压缩响应体, level为gzip.DefaultCompression等, minSize为0时使用DEFAULT_GZIP_MIN_SIZE
+func Gzip(level, minSize int) func(p.Handler) p.Handler {
+	if minSize <= 0 {
+		minSize = DEFAULT_GZIP_MIN_SIZE
+	}
+
+	pool := &sync.Pool{New: func() interface{} {
+		gz, err := gzip.NewWriterLevel(nil, level)
+		if err != nil {
+			gz, _ = gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		}
+		return gz
+	}}
+
+	return func(next p.Handler) p.Handler {
+		return p.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request, ps p.Params) {
+			if r.Method == http.MethodHead || p.IsUpgrade(r) || !strings.Contains(r.Header.Get("Accept-Encoding"), "gzip") {
+				next.Serve(ctx, w, r, ps)
+				return
+			}
+
+			w.Header().Add("Vary", "Accept-Encoding")
+
+			gw := &_GzipWriter{ResponseWriter: w, pool: pool, minSize: minSize}
+			defer gw.close()
+
+			next.Serve(ctx, gw, r, ps)
+		})
+	}
+}
+
+// _GzipWriter 缓存响应体直到达到minSize后开始压缩, 已设置Content-Encoding或无响应体的状态码不压缩
+type _GzipWriter struct {
+	http.ResponseWriter
+	pool    *sync.Pool
+	minSize int
+
+	gz      *gzip.Writer
+	buf     []byte
+	status  int
+	decided bool // 已确定是否压缩
+	skip    bool
+}
+
+func (w *_GzipWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+func (w *_GzipWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if !w.decided {
+		w.buf = append(w.buf, b...)
+		if len(w.buf) < w.minSize {
+			return len(b), nil
+		}
+
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+		return len(b), nil
+	}
+
+	if w.skip {
+		return w.ResponseWriter.Write(b)
+	}
+
+	return w.gz.Write(b)
+}
+
+// start 确定是否压缩并写出响应头与已缓存的数据
+func (w *_GzipWriter) start(compress bool) error {
+	w.decided = true
+
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || w.status < http.StatusOK ||
+		w.status == http.StatusNoContent || w.status == http.StatusNotModified {
+		compress = false
+	}
+
+	if !compress {
+		w.skip = true
+		w.ResponseWriter.WriteHeader(w.status)
+		_, err := w.ResponseWriter.Write(w.buf)
+		w.buf = nil
+		return err
+	}
+
+	h.Set("Content-Encoding", "gzip")
+	h.Del("Content-Length")
+	w.ResponseWriter.WriteHeader(w.status)
+
+	w.gz = w.pool.Get().(*gzip.Writer)
+	w.gz.Reset(w.ResponseWriter)
+	_, err := w.gz.Write(w.buf)
+	w.buf = nil
+	return err
+}
+
+// Flush 实现http.Flusher, 流式响应在首次Flush时开始压缩
+func (w *_GzipWriter) Flush() {
+	if !w.decided {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.start(true)
+	}
+
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *_GzipWriter) close() {
+	if !w.decided {
+		if w.status == 0 && len(w.buf) == 0 {
+			return
+		}
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		w.start(false)
+		return
+	}
+
+	if w.gz != nil {
+		w.gz.Close()
+		w.gz.Reset(nil)
+		w.pool.Put(w.gz)
+		w.gz = nil
+	}
+}
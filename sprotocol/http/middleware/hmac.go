@@ -0,0 +1,120 @@
+package middleware
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	p "github.com/kkkkiven/fishpkg/sprotocol/http"
+	"github.com/kkkkiven/fishpkg/utils"
+)
+
+// 签名请求头
+const (
+	HEADER_APP_ID    = "X-App-Id"
+	HEADER_TIMESTAMP = "X-Timestamp"
+	HEADER_SIGNATURE = "X-Signature"
+)
+
+const (
+	// DEFAULT_SIGN_SKEW 默认允许的时间戳偏差
+	DEFAULT_SIGN_SKEW = 5 * time.Minute
+
+	// DEFAULT_SIGN_MAX_BODY 默认参与签名的请求体上限, 签名需读取完整请求体
+	DEFAULT_SIGN_MAX_BODY = 4 * 1024 * 1024
+)
+
+// SecretFunc 根据appid获取签名密钥, 不存在时返回false
+type SecretFunc func(appID string) (string, bool)
+
+// HMACSign 校验请求签名, 签名为HMAC-SHA1(hex)
+// 签名串: method\npath\n按key排序的query\ntimestamp(秒)\nbody
+// skew为0时使用DEFAULT_SIGN_SKEW, maxBody为0时使用DEFAULT_SIGN_MAX_BODY, 请求体超过maxBody时以413拒绝
+func HMACSign(secret SecretFunc, skew time.Duration, maxBody int64) func(p.Handler) p.Handler {
+	if skew <= 0 {
+		skew = DEFAULT_SIGN_SKEW
+	}
+	if maxBody <= 0 {
+		maxBody = DEFAULT_SIGN_MAX_BODY
+	}
+
+	return func(next p.Handler) p.Handler {
+		return p.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request, ps p.Params) {
+			appID := r.Header.Get(HEADER_APP_ID)
+			ts := r.Header.Get(HEADER_TIMESTAMP)
+			sign := r.Header.Get(HEADER_SIGNATURE)
+			if appID == "" || ts == "" || sign == "" {
+				writeError(ctx, w, r, http.StatusUnauthorized, "missing signature")
+				return
+			}
+
+			key, ok := secret(appID)
+			if !ok {
+				writeError(ctx, w, r, http.StatusUnauthorized, "unknown app id")
+				return
+			}
+
+			sec, err := strconv.ParseInt(ts, 10, 64)
+			if err != nil {
+				writeError(ctx, w, r, http.StatusUnauthorized, "invalid timestamp")
+				return
+			}
+			if d := time.Since(time.Unix(sec, 0)); d > skew || d < -skew {
+				writeError(ctx, w, r, http.StatusUnauthorized, "timestamp expired")
+				return
+			}
+
+			if r.ContentLength > maxBody {
+				writeError(ctx, w, r, http.StatusRequestEntityTooLarge, "")
+				return
+			}
+
+			var body []byte
+			if r.Body != nil {
+				// 多读一个字节以判断是否超过上限, 流式请求体没有Content-Length
+				if body, err = ioutil.ReadAll(io.LimitReader(r.Body, maxBody+1)); err != nil {
+					writeError(ctx, w, r, http.StatusBadRequest, "")
+					return
+				}
+				if int64(len(body)) > maxBody {
+					writeError(ctx, w, r, http.StatusRequestEntityTooLarge, "")
+					return
+				}
+				r.Body.Close()
+				// 还原请求体供后续读取
+				r.Body = ioutil.NopCloser(bytes.NewReader(body))
+			}
+
+			expect := utils.HashHmac(SignString(r.Method, r.URL.Path, r.URL.Query().Encode(), ts, body), key)
+			if !hmac.Equal([]byte(expect), []byte(strings.ToLower(sign))) {
+				writeError(ctx, w, r, http.StatusUnauthorized, "invalid signature")
+				return
+			}
+
+			next.Serve(ctx, w, r, ps)
+		})
+	}
+}
+
+// SignString 生成待签名串, query需已按key排序(url.Values.Encode)
+func SignString(method, path, query, timestamp string, body []byte) string {
+	var b strings.Builder
+	b.Grow(len(method) + len(path) + len(query) + len(timestamp) + len(body) + 4)
+	b.WriteString(strings.ToUpper(method))
+	b.WriteByte('\n')
+	b.WriteString(path)
+	b.WriteByte('\n')
+	b.WriteString(query)
+	b.WriteByte('\n')
+	b.WriteString(timestamp)
+	b.WriteByte('\n')
+	b.Write(body)
+
+	return b.String()
+}
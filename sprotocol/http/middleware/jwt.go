@@ -0,0 +1,165 @@
+package middleware
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	p "github.com/kkkkiven/fishpkg/sprotocol/http"
+)
+
+var (
+	ErrTokenMissing   = errors.New("missing token")
+	ErrTokenMalformed = errors.New("malformed token")
+	ErrTokenAlg       = errors.New("unsupported token algorithm")
+	ErrTokenSignature = errors.New("invalid token signature")
+	ErrTokenExpired   = errors.New("token expired")
+	ErrTokenNotValid  = errors.New("token not valid yet")
+	ErrTokenIssuer    = errors.New("invalid token issuer")
+	ErrTokenAudience  = errors.New("invalid token audience")
+)
+
+// JWTConfig JWT校验配置, 仅支持HS256
+type JWTConfig struct {
+	Secret   []byte        // 签名密钥
+	Issuer   string        // 非空时校验iss
+	Audience string        // 非空时校验aud
+	Leeway   time.Duration // exp/nbf允许的时钟偏差
+}
+
+// JWTClaims 令牌声明
+type JWTClaims map[string]interface{}
+
+type ctxClaimsKey struct{}
+
+// JWT 校验Authorization: Bearer令牌, 通过后将声明存入ctx, 可通过Claims/Subject获取
+func JWT(c JWTConfig) func(p.Handler) p.Handler {
+	return func(next p.Handler) p.Handler {
+		return p.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request, ps p.Params) {
+			claims, err := c.Parse(bearer(r))
+			if err != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token", error_description="`+err.Error()+`"`)
+				writeError(ctx, w, r, http.StatusUnauthorized, err.Error())
+				return
+			}
+
+			next.Serve(context.WithValue(ctx, ctxClaimsKey{}, claims), w, r, ps)
+		})
+	}
+}
+
+// Claims 获取JWT中间件存入的声明
+func Claims(ctx context.Context) JWTClaims {
+	claims, _ := ctx.Value(ctxClaimsKey{}).(JWTClaims)
+	return claims
+}
+
+// Subject 获取JWT声明中的sub
+func Subject(ctx context.Context) string {
+	sub, _ := Claims(ctx)["sub"].(string)
+	return sub
+}
+
+func bearer(r *http.Request) string {
+	auth := r.Header.Get("Authorization")
+	if len(auth) > 7 && strings.EqualFold(auth[:7], "Bearer ") {
+		return strings.TrimSpace(auth[7:])
+	}
+
+	return ""
+}
+
+// Parse 校验令牌并返回声明
+func (c *JWTConfig) Parse(token string) (JWTClaims, error) {
+	if token == "" {
+		return nil, ErrTokenMissing
+	}
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, ErrTokenMalformed
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, ErrTokenMalformed
+	}
+	if header.Alg != "HS256" {
+		return nil, ErrTokenAlg
+	}
+
+	sign, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	mac := hmac.New(sha256.New, c.Secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(sign, mac.Sum(nil)) {
+		return nil, ErrTokenSignature
+	}
+
+	var claims JWTClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, ErrTokenMalformed
+	}
+
+	now := time.Now()
+	if exp, ok := claims.time("exp"); ok && now.After(exp.Add(c.Leeway)) {
+		return nil, ErrTokenExpired
+	}
+	if nbf, ok := claims.time("nbf"); ok && now.Add(c.Leeway).Before(nbf) {
+		return nil, ErrTokenNotValid
+	}
+	if c.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != c.Issuer {
+			return nil, ErrTokenIssuer
+		}
+	}
+	if c.Audience != "" && !claims.hasAudience(c.Audience) {
+		return nil, ErrTokenAudience
+	}
+
+	return claims, nil
+}
+
+func decodeSegment(seg string, v interface{}) error {
+	raw, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(raw, v)
+}
+
+func (c JWTClaims) time(name string) (time.Time, bool) {
+	if v, ok := c[name].(float64); ok {
+		return time.Unix(int64(v), 0), true
+	}
+
+	return time.Time{}, false
+}
+
+// hasAudience aud可以是字符串或字符串数组
+func (c JWTClaims) hasAudience(aud string) bool {
+	switch v := c["aud"].(type) {
+	case string:
+		return v == aud
+	case []interface{}:
+		for _, item := range v {
+			if s, _ := item.(string); s == aud {
+				return true
+			}
+		}
+	}
+
+	return false
+}
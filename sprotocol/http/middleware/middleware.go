@@ -0,0 +1,174 @@
+package middleware
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ErrorWriter 中间件拒绝请求时的响应函数
+type ErrorWriter func(ctx context.Context, w http.ResponseWriter, r *http.Request, status int, msg string)
+
+var errorWriter ErrorWriter = defaultErrorWriter
+
+// SetErrorWriter 设置中间件拒绝请求时的响应函数, 用于统一响应格式
+func SetErrorWriter(fn ErrorWriter) {
+	if fn == nil {
+		fn = defaultErrorWriter
+	}
+
+	errorWriter = fn
+}
+
+func defaultErrorWriter(ctx context.Context, w http.ResponseWriter, r *http.Request, status int, msg string) {
+	http.Error(w, msg, status)
+}
+
+// writeError 拒绝请求
+func writeError(ctx context.Context, w http.ResponseWriter, r *http.Request, status int, msg string) {
+	if msg == "" {
+		msg = http.StatusText(status)
+	}
+
+	errorWriter(ctx, w, r, status, msg)
+}
+
+var trustedProxies []*net.IPNet
+
+// SetTrustedProxies 设置可信代理的ip或网段(CIDR), 为空时不信任任何代理
+// 仅当请求的直接来源为可信代理时, ClientIP才使用X-Forwarded-For与X-Real-IP
+func SetTrustedProxies(proxies ...string) error {
+	nets := make([]*net.IPNet, 0, len(proxies))
+	for _, proxy := range proxies {
+		if strings.Contains(proxy, "/") {
+			_, n, err := net.ParseCIDR(proxy)
+			if err != nil {
+				return err
+			}
+			nets = append(nets, n)
+			continue
+		}
+
+		ip := net.ParseIP(proxy)
+		if ip == nil {
+			return errors.New("invalid proxy address: " + proxy)
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			ip = ip4
+		}
+		nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(len(ip)*8, len(ip)*8)})
+	}
+
+	trustedProxies = nets
+	return nil
+}
+
+// isTrustedProxy ip是否为可信代理
+func isTrustedProxy(ip string) bool {
+	addr := net.ParseIP(ip)
+	if addr == nil {
+		return false
+	}
+
+	for _, n := range trustedProxies {
+		if n.Contains(addr) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClientIP 获取客户端ip, 默认取RemoteAddr(经网关转发时为网关记录的客户端地址)
+// RemoteAddr为可信代理时, 从右向左取X-Forwarded-For中首个非可信代理的地址, 无X-Forwarded-For时取X-Real-IP
+func ClientIP(r *http.Request) string {
+	ip := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		ip = host
+	}
+
+	if !isTrustedProxy(ip) {
+		return ip
+	}
+
+	if xff := r.Header.Values("X-Forwarded-For"); len(xff) != 0 {
+		hops := strings.Split(strings.Join(xff, ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if hop == "" {
+				continue
+			}
+
+			ip = hop
+			if !isTrustedProxy(hop) {
+				break
+			}
+		}
+
+		return ip
+	}
+
+	if realIP := strings.TrimSpace(r.Header.Get("X-Real-IP")); realIP != "" {
+		return realIP
+	}
+
+	return ip
+}
+
+// _ResponseWriter 记录状态码与响应大小, 保留http.Flusher与http.Hijacker
+type _ResponseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int
+}
+
+func (w *_ResponseWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *_ResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+func (w *_ResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack 实现http.Hijacker, 以支持WebSocket等协议升级
+func (w *_ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijack not supported")
+	}
+
+	conn, brw, err := h.Hijack()
+	if err == nil && w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+
+	return conn, brw, err
+}
+
+// Status 响应状态码, 未设置时为200
+func (w *_ResponseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+
+	return w.status
+}
@@ -0,0 +1,130 @@
+package middleware
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	p "github.com/kkkkiven/fishpkg/sprotocol/http"
+)
+
+// DEFAULT_LIMITER_IDLE 令牌桶空闲超过该时间后回收
+const DEFAULT_LIMITER_IDLE = 10 * time.Minute
+
+// KeyFunc 获取限流维度的键, 返回空时不限流
+type KeyFunc func(ctx context.Context, r *http.Request) string
+
+// ByIP 按客户端ip限流
+func ByIP(ctx context.Context, r *http.Request) string {
+	return ClientIP(r)
+}
+
+// ByHeader 按请求头限流, 如用户id
+func ByHeader(name string) KeyFunc {
+	return func(ctx context.Context, r *http.Request) string {
+		return r.Header.Get(name)
+	}
+}
+
+// ByUser 按JWT中的用户(sub)限流, 需在JWT中间件之后注册
+func ByUser(ctx context.Context, r *http.Request) string {
+	return Subject(ctx)
+}
+
+// RateLimit 令牌桶限流, 每个键每秒补充rate个令牌, 最多积累burst个, 超出时以429拒绝
+func RateLimit(rate float64, burst int, key KeyFunc) func(p.Handler) p.Handler {
+	l := NewLimiter(rate, burst)
+
+	return func(next p.Handler) p.Handler {
+		return p.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request, ps p.Params) {
+			k := key(ctx, r)
+			if k == "" {
+				next.Serve(ctx, w, r, ps)
+				return
+			}
+
+			if ok, wait := l.Allow(k); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				writeError(ctx, w, r, http.StatusTooManyRequests, "")
+				return
+			}
+
+			next.Serve(ctx, w, r, ps)
+		})
+	}
+}
+
+// Limiter 按键区分的令牌桶
+type Limiter struct {
+	mu      sync.Mutex
+	rate    float64
+	burst   float64
+	buckets map[string]*_Bucket
+	sweep   time.Time
+}
+
+type _Bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// NewLimiter 创建令牌桶
+func NewLimiter(rate float64, burst int) *Limiter {
+	if burst < 1 {
+		burst = 1
+	}
+
+	return &Limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		buckets: make(map[string]*_Bucket, 0),
+		sweep:   time.Now(),
+	}
+}
+
+// Allow 消耗一个令牌, 不足时返回需等待的时间
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	now := time.Now()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.clean(now)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &_Bucket{tokens: l.burst, last: now}
+		l.buckets[key] = b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+
+	if l.rate <= 0 {
+		return false, time.Second
+	}
+
+	return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// clean 定期回收空闲且已补满的令牌桶
+func (l *Limiter) clean(now time.Time) {
+	if now.Sub(l.sweep) < DEFAULT_LIMITER_IDLE {
+		return
+	}
+	l.sweep = now
+
+	for k, b := range l.buckets {
+		if now.Sub(b.last) > DEFAULT_LIMITER_IDLE {
+			delete(l.buckets, k)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/kkkkiven/fishpkg/logs"
+	p "github.com/kkkkiven/fishpkg/sprotocol/http"
+)
+
+// Recovery 捕获handler中的panic并以500响应, 避免网关收到RC_HANDLER_PANIC
+func Recovery() func(p.Handler) p.Handler {
+	return func(next p.Handler) p.Handler {
+		return p.HandlerFunc(func(ctx context.Context, w http.ResponseWriter, r *http.Request, ps p.Params) {
+			defer func() {
+				if err := recover(); err != nil {
+					hint := fmt.Sprintf("Panic: %+v\n%s", err, string(debug.Stack()))
+
+					span := p.SpanFromContext(ctx)
+					span.Tag("msg", hint)
+					span.MarkError()
+
+					logs.Errorf("- %v - %s", r.RemoteAddr, hint)
+
+					writeError(ctx, w, r, http.StatusInternalServerError, "")
+				}
+			}()
+
+			next.Serve(ctx, w, r, ps)
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package http
+
+import (
+	"encoding/binary"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// DEFAULT_PING_MISSES 连续未收到pong的次数达到该值时视为连接失效
+const DEFAULT_PING_MISSES = 3
+
+// ErrPingPending 上一次ping尚未返回
+var ErrPingPending = errors.New("ping pending")
+
+// RTTStats 连接往返时延统计
+type RTTStats struct {
+	Last   time.Duration // 最近一次
+	Avg    time.Duration // 平滑平均值(EWMA, 1/8)
+	Min    time.Duration
+	Max    time.Duration
+	Sent   uint64 // 发送的ping数
+	Recv   uint64 // 收到的pong数
+	Missed int    // 连续未收到pong的次数
+}
+
+// Ping 发送ping并等待pong, 返回往返时延, 同一时刻只允许一个ping
+func (this *Socket) Ping() (time.Duration, error) {
+	if !atomic.CompareAndSwapInt32(&this.pinging, 0, 1) {
+		return 0, ErrPingPending
+	}
+	defer atomic.StoreInt32(&this.pinging, 0)
+
+	buf := make([]byte, 8)
+	start := time.Now()
+	binary.BigEndian.PutUint64(buf, uint64(start.UnixNano()))
+
+	msg := NewPingMessage()
+	msg.SetBody(buf)
+
+	this.rttLocker.Lock()
+	this.rtt.Sent++
+	this.rttLocker.Unlock()
+
+	rsp, err := this.sendTimeout(msg, this.GetTimeout())
+	if err == nil && rsp.GetMessageType() != MT_PONG {
+		err = errors.New("unexpected pong")
+	}
+	if err != nil {
+		this.rttLocker.Lock()
+		this.rtt.Missed++
+		this.rttLocker.Unlock()
+		return 0, err
+	}
+
+	rtt := time.Since(start)
+	this.recordRTT(rtt)
+
+	return rtt, nil
+}
+
+// RTT 获取往返时延统计
+func (this *Socket) RTT() RTTStats {
+	this.rttLocker.Lock()
+	defer this.rttLocker.Unlock()
+
+	return this.rtt
+}
+
+// recordRTT 记录一次成功的往返
+func (this *Socket) recordRTT(rtt time.Duration) {
+	this.rttLocker.Lock()
+	defer this.rttLocker.Unlock()
+
+	s := &this.rtt
+	s.Recv++
+	s.Missed = 0
+	s.Last = rtt
+
+	if s.Recv == 1 {
+		s.Avg, s.Min, s.Max = rtt, rtt, rtt
+		return
+	}
+
+	s.Avg += (rtt - s.Avg) / 8
+	if rtt < s.Min {
+		s.Min = rtt
+	}
+	if rtt > s.Max {
+		s.Max = rtt
+	}
+}
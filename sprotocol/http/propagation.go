@@ -0,0 +1,277 @@
+package http
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+
+	xutils "github.com/kkkkiven/fishpkg/servicesdk/pkg/utils"
+)
+
+// 链路追踪传播头
+const (
+	TRACEPARENT        = "traceparent"
+	TRACESTATE         = "tracestate"
+	B3                 = "b3"
+	X_B3_TRACE_ID      = "X-B3-TraceId"
+	X_B3_SPAN_ID       = "X-B3-SpanId"
+	X_B3_PARENT_SPANID = "X-B3-ParentSpanId"
+	X_B3_SAMPLED       = "X-B3-Sampled"
+	X_B3_FLAGS         = "X-B3-Flags"
+)
+
+// 传播格式
+const (
+	FORMAT_W3C      = "w3c"
+	FORMAT_B3       = "b3"
+	FORMAT_B3_MULTI = "b3multi"
+	FORMAT_LEGACY   = "legacy"
+)
+
+// 上游采样决定
+const (
+	SAMPLED_UNKNOWN = iota // 未指定, 按采样率决定
+	SAMPLED_ACCEPT         // 上游已采样
+	SAMPLED_DENY           // 上游未采样
+)
+
+// traceContext 从请求头解析出的上游链路信息
+type traceContext struct {
+	format     string
+	traceHigh  uint64
+	traceId    uint64
+	spanId     uint64
+	sampled    int
+	traceState string
+}
+
+// extractTrace 依次按W3C、B3单头、B3多头、旧版X-TRACE-ID解析请求头
+func extractTrace(h http.Header) (*traceContext, bool) {
+	if tc, ok := extractW3C(h); ok {
+		return tc, true
+	}
+
+	if tc, ok := extractB3(h); ok {
+		return tc, true
+	}
+
+	if tc, ok := extractB3Multi(h); ok {
+		return tc, true
+	}
+
+	return extractLegacy(h)
+}
+
+// extractW3C 解析 traceparent: version-traceid-parentid-flags
+func extractW3C(h http.Header) (*traceContext, bool) {
+	v := strings.TrimSpace(h.Get(TRACEPARENT))
+	if v == "" {
+		return nil, false
+	}
+
+	parts := strings.Split(v, "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" {
+		return nil, false
+	}
+
+	// 版本00必须严格为4段, 更高版本允许追加字段
+	if parts[0] == "00" && len(parts) != 4 {
+		return nil, false
+	}
+
+	high, low, ok := parseTraceId(parts[1], true)
+	if !ok {
+		return nil, false
+	}
+
+	span, ok := parseSpanId(parts[2])
+	if !ok {
+		return nil, false
+	}
+
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil || len(flags) != 1 {
+		return nil, false
+	}
+
+	tc := &traceContext{
+		format:     FORMAT_W3C,
+		traceHigh:  high,
+		traceId:    low,
+		spanId:     span,
+		sampled:    SAMPLED_DENY,
+		traceState: strings.TrimSpace(strings.Join(h.Values(TRACESTATE), ",")),
+	}
+	if flags[0]&0x01 != 0 {
+		tc.sampled = SAMPLED_ACCEPT
+	}
+
+	return tc, true
+}
+
+// extractB3 解析 b3: traceid-spanid[-sampled[-parentspanid]], 仅含采样标记的头视为无链路信息
+func extractB3(h http.Header) (*traceContext, bool) {
+	v := strings.TrimSpace(h.Get(B3))
+	if v == "" {
+		return nil, false
+	}
+
+	parts := strings.Split(v, "-")
+	if len(parts) < 2 {
+		return nil, false
+	}
+
+	high, low, ok := parseTraceId(parts[0], false)
+	if !ok {
+		return nil, false
+	}
+
+	span, ok := parseSpanId(parts[1])
+	if !ok {
+		return nil, false
+	}
+
+	tc := &traceContext{format: FORMAT_B3, traceHigh: high, traceId: low, spanId: span}
+	if len(parts) > 2 {
+		tc.sampled = parseB3Sampled(parts[2])
+	}
+
+	return tc, true
+}
+
+// extractB3Multi 解析 X-B3-* 多头格式
+func extractB3Multi(h http.Header) (*traceContext, bool) {
+	tid := strings.TrimSpace(h.Get(X_B3_TRACE_ID))
+	sid := strings.TrimSpace(h.Get(X_B3_SPAN_ID))
+	if tid == "" || sid == "" {
+		return nil, false
+	}
+
+	high, low, ok := parseTraceId(tid, false)
+	if !ok {
+		return nil, false
+	}
+
+	span, ok := parseSpanId(sid)
+	if !ok {
+		return nil, false
+	}
+
+	tc := &traceContext{format: FORMAT_B3_MULTI, traceHigh: high, traceId: low, spanId: span}
+	if h.Get(X_B3_FLAGS) == "1" {
+		tc.sampled = SAMPLED_ACCEPT
+	} else {
+		tc.sampled = parseB3Sampled(strings.TrimSpace(h.Get(X_B3_SAMPLED)))
+	}
+
+	return tc, true
+}
+
+// extractLegacy 兼容旧版 X-TRACE-ID/X-SPAN-ID, 短ID取MD5, 32位ID取中间16位
+func extractLegacy(h http.Header) (*traceContext, bool) {
+	tid := legacyId(h.Get(X_TRACE_ID))
+	if tid == 0 {
+		return nil, false
+	}
+
+	return &traceContext{
+		format:  FORMAT_LEGACY,
+		traceId: tid,
+		spanId:  legacyId(h.Get(X_SPAN_ID)),
+	}, true
+}
+
+func legacyId(s string) uint64 {
+	if s == "" {
+		return 0
+	}
+
+	if len(s) < 32 {
+		s = xutils.Get16MD5Encode(s)
+	} else {
+		s = s[8:24]
+	}
+
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != 8 {
+		return 0
+	}
+
+	return binary.BigEndian.Uint64(b)
+}
+
+func parseB3Sampled(s string) int {
+	switch s {
+	case "1", "d", "true":
+		return SAMPLED_ACCEPT
+	case "0", "false":
+		return SAMPLED_DENY
+	}
+
+	return SAMPLED_UNKNOWN
+}
+
+// parseTraceId 解析16或32位十六进制trace id, strict为true时仅接受32位, 全零视为无效
+func parseTraceId(s string, strict bool) (high, low uint64, ok bool) {
+	if len(s) != 32 && (strict || len(s) != 16) {
+		return 0, 0, false
+	}
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return 0, 0, false
+	}
+
+	if len(b) == 16 {
+		high = binary.BigEndian.Uint64(b[:8])
+		b = b[8:]
+	}
+	low = binary.BigEndian.Uint64(b)
+
+	return high, low, high != 0 || low != 0
+}
+
+// parseSpanId 解析16位十六进制span id, 全零视为无效
+func parseSpanId(s string) (uint64, bool) {
+	if len(s) != 16 {
+		return 0, false
+	}
+
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		return 0, false
+	}
+
+	id := binary.BigEndian.Uint64(b)
+	return id, id != 0
+}
+
+// injectTrace 将当前span写入响应头, 始终写入traceparent, 并按上游格式回写B3头
+func injectTrace(h http.Header, tc *traceContext, spanId uint64, sampled bool) {
+	flag := "0"
+	if sampled {
+		flag = "1"
+	}
+
+	traceId := fmt.Sprintf("%016x%016x", tc.traceHigh, tc.traceId)
+	h.Set(TRACEPARENT, fmt.Sprintf("00-%v-%016x-0%v", traceId, spanId, flag))
+
+	if tc.traceState != "" {
+		h.Set(TRACESTATE, tc.traceState)
+	}
+
+	if tc.traceHigh == 0 {
+		traceId = fmt.Sprintf("%016x", tc.traceId)
+	}
+
+	switch tc.format {
+	case FORMAT_B3:
+		h.Set(B3, fmt.Sprintf("%v-%016x-%v", traceId, spanId, flag))
+	case FORMAT_B3_MULTI:
+		h.Set(X_B3_TRACE_ID, traceId)
+		h.Set(X_B3_SPAN_ID, fmt.Sprintf("%016x", spanId))
+		h.Set(X_B3_SAMPLED, flag)
+	}
+}
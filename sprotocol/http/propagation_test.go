@@ -0,0 +1,203 @@
+package http
+
+import (
+	"net/http"
+	"testing"
+)
+
+const (
+	testTraceHigh = 0x4bf92f3577b34da6
+	testTraceLow  = 0xa3ce929d0e0e4736
+	testSpanId    = 0x00f067aa0ba902b7
+)
+
+func header(kv ...string) http.Header {
+	h := http.Header{}
+	for i := 0; i+1 < len(kv); i += 2 {
+		h.Add(kv[i], kv[i+1])
+	}
+	return h
+}
+
+func TestExtractW3C(t *testing.T) {
+	cases := []struct {
+		name    string
+		h       http.Header
+		ok      bool
+		high    uint64
+		low     uint64
+		sampled int
+		state   string
+	}{
+		{"sampled", header(TRACEPARENT, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"), true, testTraceHigh, testTraceLow, SAMPLED_ACCEPT, ""},
+		{"deny", header(TRACEPARENT, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00"), true, testTraceHigh, testTraceLow, SAMPLED_DENY, ""},
+		{"other flags", header(TRACEPARENT, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-03"), true, testTraceHigh, testTraceLow, SAMPLED_ACCEPT, ""},
+		{"64-bit trace id in 128-bit field", header(TRACEPARENT, "00-0000000000000000a3ce929d0e0e4736-00f067aa0ba902b7-01"), true, 0, testTraceLow, SAMPLED_ACCEPT, ""},
+		{"tracestate", header(TRACEPARENT, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", TRACESTATE, "a=1", TRACESTATE, "b=2"), true, testTraceHigh, testTraceLow, SAMPLED_ACCEPT, "a=1,b=2"},
+		{"future version with extra fields", header(TRACEPARENT, "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"), true, testTraceHigh, testTraceLow, SAMPLED_ACCEPT, ""},
+		{"missing", header(), false, 0, 0, 0, ""},
+		{"all-zero trace id", header(TRACEPARENT, "00-00000000000000000000000000000000-00f067aa0ba902b7-01"), false, 0, 0, 0, ""},
+		{"all-zero span id", header(TRACEPARENT, "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01"), false, 0, 0, 0, ""},
+		{"64-bit trace id", header(TRACEPARENT, "00-a3ce929d0e0e4736-00f067aa0ba902b7-01"), false, 0, 0, 0, ""},
+		{"version ff", header(TRACEPARENT, "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"), false, 0, 0, 0, ""},
+		{"version 00 with extra fields", header(TRACEPARENT, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra"), false, 0, 0, 0, ""},
+		{"too few fields", header(TRACEPARENT, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7"), false, 0, 0, 0, ""},
+		{"bad hex trace id", header(TRACEPARENT, "00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01"), false, 0, 0, 0, ""},
+		{"short span id", header(TRACEPARENT, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902-01"), false, 0, 0, 0, ""},
+		{"bad flags", header(TRACEPARENT, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1"), false, 0, 0, 0, ""},
+	}
+
+	for _, c := range cases {
+		tc, ok := extractW3C(c.h)
+		if ok != c.ok {
+			t.Errorf("%v: ok = %v, want %v", c.name, ok, c.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if tc.traceHigh != c.high || tc.traceId != c.low || tc.spanId != testSpanId {
+			t.Errorf("%v: got ids %x-%x-%x", c.name, tc.traceHigh, tc.traceId, tc.spanId)
+		}
+		if tc.sampled != c.sampled {
+			t.Errorf("%v: sampled = %v, want %v", c.name, tc.sampled, c.sampled)
+		}
+		if tc.traceState != c.state {
+			t.Errorf("%v: tracestate = %q, want %q", c.name, tc.traceState, c.state)
+		}
+		if tc.format != FORMAT_W3C {
+			t.Errorf("%v: format = %v", c.name, tc.format)
+		}
+	}
+}
+
+func TestExtractB3(t *testing.T) {
+	cases := []struct {
+		name    string
+		value   string
+		ok      bool
+		high    uint64
+		sampled int
+	}{
+		{"128-bit sampled", "4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1", true, testTraceHigh, SAMPLED_ACCEPT},
+		{"64-bit sampled", "a3ce929d0e0e4736-00f067aa0ba902b7-1", true, 0, SAMPLED_ACCEPT},
+		{"deny", "a3ce929d0e0e4736-00f067aa0ba902b7-0", true, 0, SAMPLED_DENY},
+		{"debug", "a3ce929d0e0e4736-00f067aa0ba902b7-d", true, 0, SAMPLED_ACCEPT},
+		{"with parent", "a3ce929d0e0e4736-00f067aa0ba902b7-1-05e3ac9a4f6e3b90", true, 0, SAMPLED_ACCEPT},
+		{"no sampling state", "a3ce929d0e0e4736-00f067aa0ba902b7", true, 0, SAMPLED_UNKNOWN},
+		{"sampling state only", "0", false, 0, 0},
+		{"all-zero trace id", "0000000000000000-00f067aa0ba902b7-1", false, 0, 0},
+		{"all-zero span id", "a3ce929d0e0e4736-0000000000000000-1", false, 0, 0},
+		{"bad trace id length", "a3ce929d0e0e47-00f067aa0ba902b7-1", false, 0, 0},
+		{"bad hex span id", "a3ce929d0e0e4736-00f067aa0ba902bz-1", false, 0, 0},
+	}
+
+	for _, c := range cases {
+		tc, ok := extractB3(header(B3, c.value))
+		if ok != c.ok {
+			t.Errorf("%v: ok = %v, want %v", c.name, ok, c.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if tc.traceHigh != c.high || tc.traceId != testTraceLow || tc.spanId != testSpanId {
+			t.Errorf("%v: got ids %x-%x-%x", c.name, tc.traceHigh, tc.traceId, tc.spanId)
+		}
+		if tc.sampled != c.sampled {
+			t.Errorf("%v: sampled = %v, want %v", c.name, tc.sampled, c.sampled)
+		}
+	}
+}
+
+func TestExtractB3Multi(t *testing.T) {
+	cases := []struct {
+		name    string
+		h       http.Header
+		ok      bool
+		high    uint64
+		sampled int
+	}{
+		{"128-bit sampled", header(X_B3_TRACE_ID, "4bf92f3577b34da6a3ce929d0e0e4736", X_B3_SPAN_ID, "00f067aa0ba902b7", X_B3_SAMPLED, "1"), true, testTraceHigh, SAMPLED_ACCEPT},
+		{"64-bit deny", header(X_B3_TRACE_ID, "a3ce929d0e0e4736", X_B3_SPAN_ID, "00f067aa0ba902b7", X_B3_SAMPLED, "0"), true, 0, SAMPLED_DENY},
+		{"debug flag wins", header(X_B3_TRACE_ID, "a3ce929d0e0e4736", X_B3_SPAN_ID, "00f067aa0ba902b7", X_B3_SAMPLED, "0", X_B3_FLAGS, "1"), true, 0, SAMPLED_ACCEPT},
+		{"no sampling state", header(X_B3_TRACE_ID, "a3ce929d0e0e4736", X_B3_SPAN_ID, "00f067aa0ba902b7"), true, 0, SAMPLED_UNKNOWN},
+		{"missing span id", header(X_B3_TRACE_ID, "a3ce929d0e0e4736", X_B3_SAMPLED, "1"), false, 0, 0},
+		{"all-zero trace id", header(X_B3_TRACE_ID, "00000000000000000000000000000000", X_B3_SPAN_ID, "00f067aa0ba902b7"), false, 0, 0},
+		{"bad span id", header(X_B3_TRACE_ID, "a3ce929d0e0e4736", X_B3_SPAN_ID, "xyz"), false, 0, 0},
+	}
+
+	for _, c := range cases {
+		tc, ok := extractB3Multi(c.h)
+		if ok != c.ok {
+			t.Errorf("%v: ok = %v, want %v", c.name, ok, c.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if tc.traceHigh != c.high || tc.traceId != testTraceLow || tc.spanId != testSpanId {
+			t.Errorf("%v: got ids %x-%x-%x", c.name, tc.traceHigh, tc.traceId, tc.spanId)
+		}
+		if tc.sampled != c.sampled {
+			t.Errorf("%v: sampled = %v, want %v", c.name, tc.sampled, c.sampled)
+		}
+	}
+}
+
+func TestExtractTracePriority(t *testing.T) {
+	h := header(
+		TRACEPARENT, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		B3, "1111111111111111-2222222222222222-1",
+	)
+	if tc, ok := extractTrace(h); !ok || tc.format != FORMAT_W3C {
+		t.Errorf("got %+v, want W3C", tc)
+	}
+
+	// 无效的traceparent回退到B3
+	h.Set(TRACEPARENT, "00-00000000000000000000000000000000-00f067aa0ba902b7-01")
+	if tc, ok := extractTrace(h); !ok || tc.format != FORMAT_B3 {
+		t.Errorf("got %+v, want B3", tc)
+	}
+}
+
+func TestInjectExtractRoundTrip(t *testing.T) {
+	const span = uint64(0x05e3ac9a4f6e3b90)
+
+	extractors := map[string]func(http.Header) (*traceContext, bool){
+		FORMAT_W3C:      extractW3C,
+		FORMAT_B3:       extractB3,
+		FORMAT_B3_MULTI: extractB3Multi,
+	}
+
+	for _, format := range []string{FORMAT_W3C, FORMAT_B3, FORMAT_B3_MULTI} {
+		for _, high := range []uint64{0, testTraceHigh} {
+			for _, sampled := range []bool{true, false} {
+				in := &traceContext{format: format, traceHigh: high, traceId: testTraceLow, spanId: testSpanId, traceState: "k=v"}
+				h := http.Header{}
+				injectTrace(h, in, span, sampled)
+
+				want := SAMPLED_DENY
+				if sampled {
+					want = SAMPLED_ACCEPT
+				}
+
+				// traceparent始终写入
+				if out, ok := extractW3C(h); !ok || out.traceHigh != high || out.traceId != testTraceLow ||
+					out.spanId != span || out.sampled != want || out.traceState != "k=v" {
+					t.Errorf("%v/%x/%v: traceparent round trip got %+v", format, high, sampled, out)
+				}
+
+				out, ok := extractors[format](h)
+				if !ok {
+					t.Errorf("%v/%x/%v: extract failed, header %v", format, high, sampled, h)
+					continue
+				}
+				if out.format != format || out.traceHigh != high || out.traceId != testTraceLow ||
+					out.spanId != span || out.sampled != want {
+					t.Errorf("%v/%x/%v: round trip got %+v", format, high, sampled, out)
+				}
+			}
+		}
+	}
+}
@@ -11,6 +11,17 @@ type ResponseWriter struct {
 	Body          []byte              `msg:"body"`
 	HandlerHeader map[string][]string `msg:"header"`
 	Status        int                 `msg:"code"`
+
+	// 流式响应
+	so      *Socket
+	reqID   uint32
+	head    bool // HEAD请求不发送响应体
+	flushed bool // 已发送响应头
+	err     error
+
+	// 协议升级
+	remote string
+	conn   *_TunnelConn
 }
 
 func NewResponseWriter() *ResponseWriter {
@@ -21,7 +32,19 @@ func NewResponseWriter() *ResponseWriter {
 }
 
 func (this *ResponseWriter) Write(p []byte) (int, error) {
+	if this.conn != nil {
+		return 0, http.ErrHijacked
+	}
+
+	if this.err != nil {
+		return 0, this.err
+	}
+
 	this.Body = append(this.Body, p...)
+	if this.flushed && len(this.Body) >= DEFAULT_STREAM_CHUNK {
+		this.Flush()
+	}
+
 	return len(p), nil
 }
 
@@ -32,6 +55,11 @@ func (this *ResponseWriter) Header() http.Header {
 func (this *ResponseWriter) WriteHeader(statusCode int) {
 	this.checkWriteHeaderCode(statusCode)
 
+	// 响应头已发送或连接已升级
+	if this.flushed || this.conn != nil {
+		return
+	}
+
 	this.Status = statusCode
 }
 
@@ -42,3 +70,71 @@ func (this *ResponseWriter) checkWriteHeaderCode(code int) {
 		}
 	}
 }
+
+// bind 关联请求, 之后可调用Flush以流式发送响应或Hijack升级连接
+func (this *ResponseWriter) bind(so *Socket, reqID uint32, r *http.Request) {
+	this.so = so
+	this.reqID = reqID
+	this.head = r.Method == http.MethodHead
+	this.remote = r.RemoteAddr
+}
+
+// Flush 实现http.Flusher, 首次调用发送带MF_STREAM标志的响应消息(包含状态码、响应头及已写入的数据),
+// 之后写入的数据以MT_STREAM分片发送, 缓存超过DEFAULT_STREAM_CHUNK时自动发送
+func (this *ResponseWriter) Flush() {
+	if this.so == nil || this.err != nil || this.conn != nil {
+		return
+	}
+
+	if this.head {
+		this.Body = this.Body[:0]
+	}
+
+	var msg *Message
+	if !this.flushed {
+		body, err := this.MarshalMsg(nil)
+		if err != nil {
+			this.err = err
+			return
+		}
+
+		msg = NewResponseMessage()
+		msg.SetMessageFlag(MF_STREAM)
+		msg.SetBody(body)
+		this.flushed = true
+	} else {
+		if len(this.Body) == 0 {
+			return
+		}
+
+		msg = NewStreamMessage()
+		msg.SetBody(this.Body)
+	}
+
+	msg.SetRequestID(this.reqID)
+	_, this.err = this.so.Send(msg)
+	this.Body = this.Body[:0]
+}
+
+// Flushed 是否已流式发送响应头
+func (this *ResponseWriter) Flushed() bool {
+	return this.flushed
+}
+
+// finish 发送剩余数据及带MF_EOF标志的最后一个分片, status非RC_OK时表示响应中断
+func (this *ResponseWriter) finish(status byte) error {
+	if this.err != nil {
+		return this.err
+	}
+
+	msg := NewStreamMessage()
+	msg.SetRequestID(this.reqID)
+	msg.SetMessageFlag(MF_EOF)
+	msg.SetStatus(status)
+	if !this.head {
+		msg.SetBody(this.Body)
+	}
+
+	_, this.err = this.so.Send(msg)
+	return this.err
+}
@@ -11,6 +11,7 @@ const (
 	RC_TIMEOUT           = 0x05 // 超时
 	RC_SVR_NOT_FOUND     = 0x06 // 服务未找到
 	RC_HYSTRIX_LIMIT     = 0x07 // 熔断限制
+	RC_STREAM_OVERFLOW   = 0x08 // 流缓存超限
 )
 
 func init() {
@@ -23,6 +24,7 @@ func init() {
 	mRsp[RC_TIMEOUT] = "timeout"
 	mRsp[RC_SVR_NOT_FOUND] = "service not found"
 	mRsp[RC_HYSTRIX_LIMIT] = "hystrix limited"
+	mRsp[RC_STREAM_OVERFLOW] = "stream buffer overflow"
 }
 
 // 获取错误码消息值
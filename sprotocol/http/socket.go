@@ -1,6 +1,7 @@
 package http
 
 import (
+	"context"
 	"net"
 	"runtime/debug"
 	"sync"
@@ -41,6 +42,7 @@ type Socket struct {
 	mu            sync.RWMutex
 	timeout       time.Duration
 	maxPack       int
+	maxStream     int
 	lastWriteTime int64
 	context       interface{}
 
@@ -51,6 +53,16 @@ type Socket struct {
 	requestID     uint32
 	requestQueue  map[uint32]chan *Message
 	requestLocker sync.Locker
+
+	streams      map[uint32]*_BodyStream // 流式请求体
+	streamLocker sync.Mutex
+
+	calls      map[uint32]context.CancelFunc // 处理中请求的取消函数
+	callLocker sync.Mutex
+
+	pinging   int32 // ping进行中
+	rtt       RTTStats
+	rttLocker sync.Mutex
 }
 
 var socketID uint64
@@ -77,8 +89,14 @@ func NewSocket(conn net.Conn, opts ...option) *Socket {
 		so.maxPack = DEFAULT_PACK_SIZE
 	}
 
+	if so.maxStream == 0 {
+		so.maxStream = DEFAULT_STREAM_BUFFER
+	}
+
 	so.requestQueue = make(map[uint32]chan *Message, 0)
 	so.requestLocker = new(sync.Mutex)
+	so.streams = make(map[uint32]*_BodyStream, 0)
+	so.calls = make(map[uint32]context.CancelFunc, 0)
 
 	return so
 }
@@ -101,6 +119,13 @@ func SetMaxPack(size int) option {
 	}
 }
 
+// SetMaxStream 设置单个流式请求体或升级连接未读取数据的上限, 小于0时不限制
+func SetMaxStream(size int) option {
+	return func(so *Socket) {
+		so.maxStream = size
+	}
+}
+
 func SetNotify(notify Notify) option {
 	return func(so *Socket) {
 		so.notify = notify
@@ -243,6 +268,8 @@ func (this *Socket) Run() {
 // readLoop 读协程
 func (this *Socket) readLoop() {
 	defer func() {
+		this.abortStreams()
+		this.abortCalls()
 		if this.notify != nil {
 			this.notify.OnClose(this)
 		}
@@ -321,11 +348,25 @@ func (this *Socket) dispatch(msg *Message) {
 		ch, ok := this.requestQueue[msg.GetRequestID()]
 		this.requestLocker.Unlock()
 		if ok {
-			ch <- msg
+			// 重复的响应直接丢弃, 不阻塞读协程
+			select {
+			case ch <- msg:
+			default:
+			}
 		}
 		return
 	}
 
+	if MT_STREAM == msg.GetMessageType() {
+		this.pushStream(msg)
+		return
+	}
+
+	if MT_CANCEL == msg.GetMessageType() {
+		this.cancelCall(msg)
+		return
+	}
+
 	if this.msgHandler != nil {
 		this.msgHandler(this, msg)
 		return
@@ -382,15 +423,36 @@ func (this *Socket) Send(msg *Message) (*Message, error) {
 	return this.sendTimeout(msg, int64(this.timeout))
 }
 
+// SendContext 发送数据, 等待响应直到ctx结束, ctx未设置截止时间时使用连接超时
+// 请求消息在ctx取消或超时后向对端发送MT_CANCEL, 以取消对端handler的context
+func (this *Socket) SendContext(ctx context.Context, msg *Message) (*Message, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Second*time.Duration(this.GetTimeout()))
+		defer cancel()
+	}
+
+	return this.sendContext(ctx, msg)
+}
+
 // SendTimeout 发送数据，超时等待响应
 func (this *Socket) sendTimeout(msg *Message, tmout int64) (rsp *Message, err error) {
-	if msg.GetMessageType() == MT_RESPONSE || msg.GetMessageType() == MT_NORMAL || msg.GetMessageType() == MT_PONG {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(tmout)*time.Second)
+	defer cancel()
+
+	return this.sendContext(ctx, msg)
+}
+
+// sendContext 发送数据, 等待响应直到ctx结束
+func (this *Socket) sendContext(ctx context.Context, msg *Message) (rsp *Message, err error) {
+	if msg.GetMessageType() == MT_RESPONSE || msg.GetMessageType() == MT_NORMAL || msg.GetMessageType() == MT_PONG ||
+		msg.GetMessageType() == MT_STREAM || msg.GetMessageType() == MT_CANCEL {
 		err = this.post(msg.Encode())
 		return
 	}
 
+	// 不关闭waitCh, 超时后迟到的响应仍可能写入, 由缓冲区接收后随waitCh回收
 	waitCh := make(chan *Message, 1)
-	defer close(waitCh)
 
 	this.requestLocker.Lock()
 	this.requestID++
@@ -412,8 +474,19 @@ func (this *Socket) sendTimeout(msg *Message, tmout int64) (rsp *Message, err er
 
 	select {
 	case rsp = <-waitCh:
-	case <-time.After(time.Duration(tmout) * time.Second):
-		err = errors.New("send timeout")
+	case <-ctx.Done():
+		// 通知对端放弃处理
+		if msg.GetMessageType() == MT_REQUEST {
+			cancel := NewCancelMessage()
+			cancel.SetRequestID(reqID)
+			this.post(cancel.Encode())
+		}
+
+		if ctx.Err() == context.DeadlineExceeded {
+			err = errors.WithMessage(ctx.Err(), "send timeout")
+		} else {
+			err = ctx.Err()
+		}
 	}
 
 	return
@@ -0,0 +1,224 @@
+package http
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/kkkkiven/fishpkg/logs"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DEFAULT_STREAM_CHUNK 流式响应缓存超过该大小时自动发送分片
+	DEFAULT_STREAM_CHUNK = 32 * 1024
+
+	// DEFAULT_STREAM_BUFFER 单个流式请求体或升级连接未读取数据的上限, 超过时中断该流
+	DEFAULT_STREAM_BUFFER = 4 * 1024 * 1024
+)
+
+var (
+	errStreamClosed   = errors.New("stream closed")
+	errStreamOverflow = errors.New(M(RC_STREAM_OVERFLOW))
+)
+
+// _BodyStream 流式请求体, 由读协程写入MT_STREAM分片, handler通过Request.Body读取
+type _BodyStream struct {
+	mu     sync.Mutex
+	cond   *sync.Cond
+	chunks [][]byte
+	size   int // 未读取的字节数
+	limit  int // size上限, 0表示不限制
+	eof    bool
+	err    error
+	closed bool
+
+	deadline time.Time   // 读超时, 用于升级后的连接
+	timer    *time.Timer // 到期唤醒等待中的Read
+}
+
+func newBodyStream(limit int) *_BodyStream {
+	b := &_BodyStream{limit: limit}
+	b.cond = sync.NewCond(&b.mu)
+	return b
+}
+
+// push 写入分片, 读协程调用, 不阻塞
+// 未读取数据超过上限时丢弃缓存并中断读取, 返回false
+func (b *_BodyStream) push(data []byte, eof bool) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed || b.eof || b.err != nil {
+		return true
+	}
+
+	if b.limit > 0 && b.size+len(data) > b.limit {
+		b.chunks = nil
+		b.size = 0
+		b.err = errStreamOverflow
+		b.cond.Broadcast()
+		return false
+	}
+
+	if len(data) > 0 {
+		b.chunks = append(b.chunks, data)
+		b.size += len(data)
+	}
+	b.eof = eof
+	b.cond.Broadcast()
+
+	return true
+}
+
+// fail 中断读取, 已写入的分片读完后返回err
+func (b *_BodyStream) fail(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.eof || b.err != nil {
+		return
+	}
+
+	b.err = err
+	b.cond.Broadcast()
+}
+
+func (b *_BodyStream) Read(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for len(b.chunks) == 0 && !b.eof && b.err == nil && !b.closed && !b.expired() {
+		b.cond.Wait()
+	}
+
+	if b.closed {
+		return 0, errStreamClosed
+	}
+
+	if len(b.chunks) == 0 && !b.eof && b.err == nil {
+		return 0, os.ErrDeadlineExceeded
+	}
+
+	if len(b.chunks) == 0 {
+		if b.err != nil {
+			return 0, b.err
+		}
+		return 0, io.EOF
+	}
+
+	n := copy(p, b.chunks[0])
+	b.size -= n
+	if n == len(b.chunks[0]) {
+		b.chunks[0] = nil
+		b.chunks = b.chunks[1:]
+	} else {
+		b.chunks[0] = b.chunks[0][n:]
+	}
+
+	return n, nil
+}
+
+// setDeadline 设置读超时, 零值表示不超时
+func (b *_BodyStream) setDeadline(t time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.deadline = t
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+
+	if !t.IsZero() {
+		b.timer = time.AfterFunc(time.Until(t), func() {
+			b.mu.Lock()
+			b.cond.Broadcast()
+			b.mu.Unlock()
+		})
+	}
+
+	b.cond.Broadcast()
+}
+
+func (b *_BodyStream) expired() bool {
+	return !b.deadline.IsZero() && !time.Now().Before(b.deadline)
+}
+
+// Close 丢弃未读取的分片
+func (b *_BodyStream) Close() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.closed = true
+	b.chunks = nil
+	b.size = 0
+	if b.timer != nil {
+		b.timer.Stop()
+		b.timer = nil
+	}
+	b.cond.Broadcast()
+
+	return nil
+}
+
+// openStream 为携带MF_STREAM标志的请求创建请求体, 需在读协程中调用以保证分片顺序
+func (this *Socket) openStream(reqID uint32) *_BodyStream {
+	b := newBodyStream(this.maxStream)
+
+	this.streamLocker.Lock()
+	this.streams[reqID] = b
+	this.streamLocker.Unlock()
+
+	return b
+}
+
+// closeStream 请求处理结束后移除请求体
+func (this *Socket) closeStream(reqID uint32) {
+	this.streamLocker.Lock()
+	b, ok := this.streams[reqID]
+	delete(this.streams, reqID)
+	this.streamLocker.Unlock()
+
+	if ok {
+		b.Close()
+	}
+}
+
+// pushStream 分发MT_STREAM分片, 状态非RC_OK时表示对端中断发送
+// 缓存超限时以RC_STREAM_OVERFLOW状态的EOF分片通知对端停止发送
+func (this *Socket) pushStream(msg *Message) {
+	this.streamLocker.Lock()
+	b, ok := this.streams[msg.GetRequestID()]
+	this.streamLocker.Unlock()
+
+	if !ok {
+		return
+	}
+
+	if msg.GetStatus() != RC_OK {
+		b.fail(errors.New(M(msg.GetStatus())))
+		return
+	}
+
+	if !b.push(msg.GetBody(), msg.GetMessageFlag()&MF_EOF != 0) {
+		logs.Waringf("- %v - Stream[%v] buffer exceeds %v bytes", this.GetConn().RemoteAddr().String(), msg.GetRequestID(), this.maxStream)
+
+		rsp := NewStreamMessage()
+		rsp.SetRequestID(msg.GetRequestID())
+		rsp.SetMessageFlag(MF_EOF)
+		rsp.SetStatus(RC_STREAM_OVERFLOW)
+		this.Send(rsp)
+	}
+}
+
+// abortStreams 连接断开时中断全部未完成的请求体
+func (this *Socket) abortStreams() {
+	this.streamLocker.Lock()
+	defer this.streamLocker.Unlock()
+
+	for _, b := range this.streams {
+		b.fail(io.ErrUnexpectedEOF)
+	}
+}
@@ -0,0 +1,53 @@
+package http
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestBodyStreamLimit(t *testing.T) {
+	b := newBodyStream(8)
+
+	if !b.push([]byte("12345"), false) {
+		t.Fatal("push within limit failed")
+	}
+
+	buf := make([]byte, 3)
+	if n, err := b.Read(buf); err != nil || n != 3 {
+		t.Fatalf("Read = %v, %v", n, err)
+	}
+
+	// 已读取的数据不计入上限
+	if !b.push([]byte("abcdef"), false) {
+		t.Fatal("push after read failed")
+	}
+
+	if b.push([]byte("x"), false) {
+		t.Fatal("push over limit succeeded")
+	}
+
+	if _, err := b.Read(buf); err != errStreamOverflow {
+		t.Fatalf("Read err = %v, want %v", err, errStreamOverflow)
+	}
+
+	// 中断后的分片被忽略
+	if !b.push([]byte("y"), true) {
+		t.Fatal("push after overflow reported overflow again")
+	}
+}
+
+func TestBodyStreamUnlimited(t *testing.T) {
+	b := newBodyStream(-1)
+
+	for i := 0; i < 4; i++ {
+		if !b.push(make([]byte, DEFAULT_STREAM_CHUNK), false) {
+			t.Fatal("push on unlimited stream failed")
+		}
+	}
+	b.push(nil, true)
+
+	data, err := ioutil.ReadAll(b)
+	if err != nil || len(data) != 4*DEFAULT_STREAM_CHUNK {
+		t.Fatalf("ReadAll = %v, %v", len(data), err)
+	}
+}
@@ -0,0 +1,198 @@
+package http
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// IsUpgrade 是否为协议升级请求(如WebSocket握手)
+func IsUpgrade(r *http.Request) bool {
+	for _, v := range r.Header["Connection"] {
+		for _, token := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return r.Header.Get("Upgrade") != ""
+			}
+		}
+	}
+
+	return false
+}
+
+// Hijack 实现http.Hijacker, 将请求升级为经网关连接多路复用的双向数据流
+// 发送带MF_UPGRADE标志的响应后, 写入连接的数据(包括握手响应)由网关原样转发给客户端,
+// 客户端发送的数据以MT_STREAM分片到达并由Read读取, 网关以MF_EOF或非RC_OK状态的分片关闭连接
+// handler返回后连接仍然有效, 需由调用方Close
+func (this *ResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if this.so == nil {
+		return nil, nil, errors.New("hijack not supported")
+	}
+
+	if this.conn != nil {
+		return nil, nil, http.ErrHijacked
+	}
+
+	if this.flushed {
+		return nil, nil, errors.New("response already sent")
+	}
+
+	stream, err := this.so.hijackStream(this.reqID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	msg := NewResponseMessage()
+	msg.SetRequestID(this.reqID)
+	msg.SetMessageFlag(MF_UPGRADE)
+	if _, err := this.so.Send(msg); err != nil {
+		this.so.closeStream(this.reqID)
+		return nil, nil, err
+	}
+
+	this.conn = &_TunnelConn{
+		so:     this.so,
+		reqID:  this.reqID,
+		stream: stream,
+		remote: _TunnelAddr(this.remote),
+	}
+
+	return this.conn, bufio.NewReadWriter(bufio.NewReader(this.conn), bufio.NewWriter(this.conn)), nil
+}
+
+// Hijacked 连接是否已升级
+func (this *ResponseWriter) Hijacked() bool {
+	return this.conn != nil
+}
+
+// hijackStream 为升级的连接创建读取流, 请求体正在流式读取时不允许升级
+func (this *Socket) hijackStream(reqID uint32) (*_BodyStream, error) {
+	this.streamLocker.Lock()
+	defer this.streamLocker.Unlock()
+
+	if _, ok := this.streams[reqID]; ok {
+		return nil, errors.New("request body is streaming")
+	}
+
+	b := newBodyStream(this.maxStream)
+	this.streams[reqID] = b
+
+	return b, nil
+}
+
+// _TunnelConn 升级后的连接, 实现net.Conn, 与请求共用请求id在网关连接上多路复用
+type _TunnelConn struct {
+	so     *Socket
+	reqID  uint32
+	stream *_BodyStream
+	remote _TunnelAddr
+
+	mu            sync.Mutex // 保证单次Write的分片连续
+	writeDeadline time.Time
+	closed        bool
+}
+
+func (this *_TunnelConn) Read(b []byte) (int, error) {
+	n, err := this.stream.Read(b)
+	if err == errStreamClosed {
+		err = net.ErrClosed
+	}
+
+	return n, err
+}
+
+// Write 按DEFAULT_STREAM_CHUNK分片发送
+func (this *_TunnelConn) Write(b []byte) (int, error) {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if this.closed {
+		return 0, net.ErrClosed
+	}
+
+	n := 0
+	for n < len(b) {
+		if !this.writeDeadline.IsZero() && !time.Now().Before(this.writeDeadline) {
+			return n, os.ErrDeadlineExceeded
+		}
+
+		end := n + DEFAULT_STREAM_CHUNK
+		if end > len(b) {
+			end = len(b)
+		}
+
+		msg := NewStreamMessage()
+		msg.SetRequestID(this.reqID)
+		msg.SetBody(b[n:end])
+		if _, err := this.so.Send(msg); err != nil {
+			return n, err
+		}
+
+		n = end
+	}
+
+	return n, nil
+}
+
+// Close 发送带MF_EOF标志的分片通知网关关闭客户端连接
+func (this *_TunnelConn) Close() error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	if this.closed {
+		return nil
+	}
+	this.closed = true
+
+	this.so.closeStream(this.reqID)
+
+	msg := NewStreamMessage()
+	msg.SetRequestID(this.reqID)
+	msg.SetMessageFlag(MF_EOF)
+	_, err := this.so.Send(msg)
+
+	return err
+}
+
+func (this *_TunnelConn) LocalAddr() net.Addr {
+	return this.so.GetConn().LocalAddr()
+}
+
+func (this *_TunnelConn) RemoteAddr() net.Addr {
+	return this.remote
+}
+
+func (this *_TunnelConn) SetDeadline(t time.Time) error {
+	this.SetReadDeadline(t)
+	return this.SetWriteDeadline(t)
+}
+
+func (this *_TunnelConn) SetReadDeadline(t time.Time) error {
+	this.stream.setDeadline(t)
+	return nil
+}
+
+// SetWriteDeadline 仅在分片之间检查, 单个分片的发送超时由网关连接控制
+func (this *_TunnelConn) SetWriteDeadline(t time.Time) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	this.writeDeadline = t
+	return nil
+}
+
+// _TunnelAddr 客户端地址
+type _TunnelAddr string
+
+func (this _TunnelAddr) Network() string {
+	return "tcp"
+}
+
+func (this _TunnelAddr) String() string {
+	return string(this)
+}
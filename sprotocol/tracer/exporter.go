@@ -0,0 +1,285 @@
+package tracer
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/kkkkiven/fishpkg/logs"
+)
+
+const (
+	DEFAULT_QUEUE_SIZE     = 2048
+	DEFAULT_BATCH_SIZE     = 256
+	DEFAULT_FLUSH_INTERVAL = 5 * time.Second
+	DEFAULT_MAX_RETRY      = 3
+	DEFAULT_EXPORT_TIMEOUT = 10 * time.Second
+)
+
+// 导出器类型
+const (
+	EXPORTER_ZIPKIN = "ZIPKIN" // Zipkin v2 HTTP
+	EXPORTER_OTLP   = "OTLP"   // OTLP/HTTP JSON
+	EXPORTER_FILE   = "FILE"   // 本地文件
+	EXPORTER_MEMORY = "MEMORY" // 内存, 用于测试
+)
+
+// Exporter span导出接口, Export在Batcher协程中串行调用
+type Exporter interface {
+	// Export 导出一批已结束的span, 返回错误时由Batcher重试, 返回后spans会被复用, 不可持有
+	Export(spans []*Span) error
+
+	// Shutdown 关闭导出器并释放资源
+	Shutdown() error
+}
+
+// NewExporter 按类型创建导出器, endpoint为导出地址或文件路径
+func NewExporter(typ, endpoint string, headers map[string]string) (Exporter, error) {
+	switch strings.ToUpper(strings.TrimSpace(typ)) {
+	case EXPORTER_ZIPKIN:
+		return NewZipkinExporter(endpoint, 0), nil
+	case EXPORTER_OTLP:
+		return NewOTLPExporter(endpoint, headers, 0), nil
+	case EXPORTER_FILE:
+		return NewFileExporter(endpoint)
+	case EXPORTER_MEMORY:
+		return NewMemoryExporter(), nil
+	}
+
+	return nil, fmt.Errorf("unsupported exporter: %v", typ)
+}
+
+// Batcher 为Exporter提供有界队列、批量发送与失败重试
+type Batcher struct {
+	exporter  Exporter
+	queueSize int
+	batchSize int
+	interval  time.Duration
+	maxRetry  int
+
+	queue   chan *Span
+	flush   chan chan struct{}
+	closed  chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	dropped uint64
+}
+
+type option func(*Batcher)
+
+// SetQueueSize 设置队列长度, 队列满时丢弃新的span
+func SetQueueSize(n int) option {
+	return func(b *Batcher) {
+		b.queueSize = n
+	}
+}
+
+// SetBatchSize 设置单次导出的最大span数量
+func SetBatchSize(n int) option {
+	return func(b *Batcher) {
+		b.batchSize = n
+	}
+}
+
+// SetFlushInterval 设置定时导出间隔
+func SetFlushInterval(d time.Duration) option {
+	return func(b *Batcher) {
+		b.interval = d
+	}
+}
+
+// SetMaxRetry 设置导出失败重试次数
+func SetMaxRetry(n int) option {
+	return func(b *Batcher) {
+		b.maxRetry = n
+	}
+}
+
+// NewBatcher 创建Batcher并启动后台导出协程
+func NewBatcher(exp Exporter, opts ...option) *Batcher {
+	b := &Batcher{
+		exporter:  exp,
+		queueSize: DEFAULT_QUEUE_SIZE,
+		batchSize: DEFAULT_BATCH_SIZE,
+		interval:  DEFAULT_FLUSH_INTERVAL,
+		maxRetry:  DEFAULT_MAX_RETRY,
+	}
+
+	for _, opt := range opts {
+		opt(b)
+	}
+
+	if b.queueSize <= 0 {
+		b.queueSize = DEFAULT_QUEUE_SIZE
+	}
+	if b.batchSize <= 0 {
+		b.batchSize = DEFAULT_BATCH_SIZE
+	}
+	if b.interval <= 0 {
+		b.interval = DEFAULT_FLUSH_INTERVAL
+	}
+	if b.maxRetry < 0 {
+		b.maxRetry = 0
+	}
+
+	b.queue = make(chan *Span, b.queueSize)
+	b.flush = make(chan chan struct{})
+	b.closed = make(chan struct{})
+	b.done = make(chan struct{})
+
+	go b.run()
+
+	return b
+}
+
+// Enqueue 将span放入队列, 队列已满或已关闭时丢弃并返回false
+func (b *Batcher) Enqueue(s *Span) bool {
+	select {
+	case <-b.closed:
+		return false
+	default:
+	}
+
+	select {
+	case b.queue <- s:
+		return true
+	default:
+		atomic.AddUint64(&b.dropped, 1)
+		return false
+	}
+}
+
+// Dropped 获取因队列已满被丢弃的span数量
+func (b *Batcher) Dropped() uint64 {
+	return atomic.LoadUint64(&b.dropped)
+}
+
+// Flush 立即导出队列中的全部span
+func (b *Batcher) Flush() {
+	ch := make(chan struct{})
+
+	select {
+	case b.flush <- ch:
+		<-ch
+	case <-b.done:
+	}
+}
+
+// Shutdown 导出剩余span后关闭导出器
+func (b *Batcher) Shutdown() error {
+	b.once.Do(func() {
+		close(b.closed)
+	})
+	<-b.done
+
+	return b.exporter.Shutdown()
+}
+
+func (b *Batcher) run() {
+	defer close(b.done)
+
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+
+	buf := make([]*Span, 0, b.batchSize)
+
+	for {
+		select {
+		case s := <-b.queue:
+			buf = append(buf, s)
+			if len(buf) >= b.batchSize {
+				buf = b.export(buf)
+			}
+		case <-ticker.C:
+			buf = b.export(buf)
+		case ch := <-b.flush:
+			buf = b.export(b.drain(buf))
+			close(ch)
+		case <-b.closed:
+			b.export(b.drain(buf))
+			return
+		}
+	}
+}
+
+// drain 取出队列中剩余的span
+func (b *Batcher) drain(buf []*Span) []*Span {
+	for {
+		select {
+		case s := <-b.queue:
+			buf = append(buf, s)
+		default:
+			return buf
+		}
+	}
+}
+
+// export 分批导出, 失败时按指数退避重试, 返回清空后的缓冲区
+func (b *Batcher) export(buf []*Span) []*Span {
+	for rest := buf; len(rest) > 0; {
+		n := len(rest)
+		if n > b.batchSize {
+			n = b.batchSize
+		}
+
+		batch := rest[:n]
+		backoff := 100 * time.Millisecond
+
+		var err error
+		for i := 0; i <= b.maxRetry; i++ {
+			if err = b.exporter.Export(batch); err == nil {
+				break
+			}
+
+			if i < b.maxRetry {
+				time.Sleep(backoff)
+				backoff *= 2
+			}
+		}
+
+		if err != nil {
+			logs.Errorf("Export %v spans err: %v", len(batch), err.Error())
+		}
+
+		rest = rest[n:]
+	}
+
+	// 清空已导出的span, 保留原有容量复用缓冲区
+	for i := range buf {
+		buf[i] = nil
+	}
+
+	return buf[:0]
+}
+
+// postJSON 发送json请求, 非2xx状态码视为失败
+func postJSON(client *http.Client, url string, body []byte, headers map[string]string) error {
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	rsp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer rsp.Body.Close()
+
+	if rsp.StatusCode < 200 || rsp.StatusCode >= 300 {
+		msg, _ := ioutil.ReadAll(rsp.Body)
+		return fmt.Errorf("post %v status:%v,body:%s", url, rsp.StatusCode, msg)
+	}
+
+	ioutil.ReadAll(rsp.Body)
+	return nil
+}
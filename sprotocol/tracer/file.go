@@ -0,0 +1,49 @@
+package tracer
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// FileExporter 以Zipkin v2 JSON格式逐行写入本地文件
+type FileExporter struct {
+	mu sync.Mutex
+	fp *os.File
+}
+
+// NewFileExporter 创建文件导出器, 文件以追加方式打开
+func NewFileExporter(path string) (*FileExporter, error) {
+	fp, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FileExporter{fp: fp}, nil
+}
+
+func (this *FileExporter) Export(spans []*Span) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	w := bufio.NewWriter(this.fp)
+	for _, zs := range toZipkinSpans(spans) {
+		line, err := json.Marshal(zs)
+		if err != nil {
+			return err
+		}
+
+		w.Write(line)
+		w.WriteByte('\n')
+	}
+
+	return w.Flush()
+}
+
+func (this *FileExporter) Shutdown() error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	return this.fp.Close()
+}
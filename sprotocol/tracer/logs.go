@@ -0,0 +1,40 @@
+package tracer
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/kkkkiven/fishpkg/logs"
+)
+
+func init() {
+	logs.SetContextExtractor(logFields)
+}
+
+// logFields 提取ctx中的trace_id与span_id, 注入到结构化日志
+func logFields(ctx context.Context) []logs.Field {
+	var high, traceId, spanId int64
+
+	if span := GetSpan(ctx); span != nil {
+		high, traceId, spanId = span.TraceIdHigh, span.TraceId, span.SpanId
+	} else if ps, ok := ctx.Value(ctxPropagateKeyInstance).(*PropagateSpan); ok {
+		high, traceId, spanId = ps.traceIdHigh, ps.traceId, ps.id
+	}
+
+	if traceId == 0 {
+		return nil
+	}
+
+	return []logs.Field{
+		logs.String("trace_id", traceIdHex(high, traceId)),
+		logs.String("span_id", fmt.Sprintf("%016x", uint64(spanId))),
+	}
+}
+
+func traceIdHex(high, low int64) string {
+	if high != 0 {
+		return fmt.Sprintf("%016x%016x", uint64(high), uint64(low))
+	}
+
+	return fmt.Sprintf("%016x", uint64(low))
+}
@@ -0,0 +1,44 @@
+package tracer
+
+import "sync"
+
+// MemoryExporter 将span保存在内存中, 用于测试
+type MemoryExporter struct {
+	mu    sync.Mutex
+	spans []*Span
+}
+
+// NewMemoryExporter 创建内存导出器
+func NewMemoryExporter() *MemoryExporter {
+	return &MemoryExporter{}
+}
+
+func (this *MemoryExporter) Export(spans []*Span) error {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	this.spans = append(this.spans, spans...)
+	return nil
+}
+
+func (this *MemoryExporter) Shutdown() error {
+	return nil
+}
+
+// Spans 获取已导出的span
+func (this *MemoryExporter) Spans() []*Span {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	list := make([]*Span, len(this.spans))
+	copy(list, this.spans)
+	return list
+}
+
+// Reset 清空已导出的span
+func (this *MemoryExporter) Reset() {
+	this.mu.Lock()
+	defer this.mu.Unlock()
+
+	this.spans = nil
+}
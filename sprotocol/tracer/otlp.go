@@ -0,0 +1,237 @@
+package tracer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// OTLP span类型
+const (
+	otlpKindServer = 2
+	otlpKindClient = 3
+)
+
+// OTLPExporter 以OTLP/HTTP JSON格式导出span
+type OTLPExporter struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+// NewOTLPExporter 创建OTLP导出器, url如 http://127.0.0.1:4318/v1/traces, headers用于鉴权等附加请求头
+func NewOTLPExporter(url string, headers map[string]string, timeout time.Duration) *OTLPExporter {
+	if timeout <= 0 {
+		timeout = DEFAULT_EXPORT_TIMEOUT
+	}
+
+	h := make(map[string]string, len(headers))
+	for k, v := range headers {
+		h[k] = v
+	}
+
+	return &OTLPExporter{
+		url:     url,
+		headers: h,
+		client:  &http.Client{Timeout: timeout},
+	}
+}
+
+func (this *OTLPExporter) Export(spans []*Span) error {
+	body, err := json.Marshal(toOTLPRequest(spans))
+	if err != nil {
+		return err
+	}
+
+	return postJSON(this.client, this.url, body, this.headers)
+}
+
+func (this *OTLPExporter) Shutdown() error {
+	return nil
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpEvent struct {
+	TimeUnixNano string `json:"timeUnixNano"`
+	Name         string `json:"name"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"`
+	Message string `json:"message,omitempty"`
+}
+
+type otlpLink struct {
+	TraceId string `json:"traceId"`
+	SpanId  string `json:"spanId"`
+}
+
+type otlpSpan struct {
+	TraceId           string          `json:"traceId"`
+	SpanId            string          `json:"spanId"`
+	ParentSpanId      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Events            []otlpEvent     `json:"events,omitempty"`
+	Links             []otlpLink      `json:"links,omitempty"`
+	Status            *otlpStatus     `json:"status,omitempty"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope   `json:"scope"`
+	Spans []*otlpSpan `json:"spans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpRequest struct {
+	ResourceSpans []*otlpResourceSpans `json:"resourceSpans"`
+}
+
+func otlpAttr(key, value string) otlpAttribute {
+	return otlpAttribute{Key: key, Value: otlpValue{StringValue: &value}}
+}
+
+// otlpTypedAttr 按属性类型编码, int64按OTLP JSON约定编码为字符串
+func otlpTypedAttr(key string, v interface{}) otlpAttribute {
+	switch val := v.(type) {
+	case int64:
+		s := strconv.FormatInt(val, 10)
+		return otlpAttribute{Key: key, Value: otlpValue{IntValue: &s}}
+	case float64:
+		return otlpAttribute{Key: key, Value: otlpValue{DoubleValue: &val}}
+	case bool:
+		return otlpAttribute{Key: key, Value: otlpValue{BoolValue: &val}}
+	}
+
+	return otlpAttr(key, fmt.Sprintf("%v", v))
+}
+
+// otlpId 将64位id编码为十六进制, trace id需补零至16字节
+func otlpId(high, low int64, trace bool) string {
+	if trace {
+		return fmt.Sprintf("%016x%016x", uint64(high), uint64(low))
+	}
+
+	return fmt.Sprintf("%016x", uint64(low))
+}
+
+// toOTLPRequest 按本地节点分组为resourceSpans, trace id左侧补零至16字节
+func toOTLPRequest(spans []*Span) *otlpRequest {
+	req := &otlpRequest{}
+	groups := make(map[*Endpoint]*otlpResourceSpans, 0)
+
+	for _, s := range spans {
+		rs, ok := groups[s.LocalEndpoint]
+		if !ok {
+			rs = &otlpResourceSpans{}
+			if e := s.LocalEndpoint; e != nil {
+				rs.Resource.Attributes = []otlpAttribute{
+					otlpAttr("service.name", e.ServiceName),
+					otlpAttr("service.instance.id", strconv.FormatUint(uint64(e.ServiceId), 10)),
+					otlpAttr("service.type", strconv.FormatUint(uint64(e.ServiceType), 10)),
+					otlpAttr("host.ip", e.IPv4),
+				}
+			}
+			rs.ScopeSpans = []otlpScopeSpans{{Scope: otlpScope{Name: "fishpkg/sprotocol/tracer"}}}
+
+			groups[s.LocalEndpoint] = rs
+			req.ResourceSpans = append(req.ResourceSpans, rs)
+		}
+
+		rs.ScopeSpans[0].Spans = append(rs.ScopeSpans[0].Spans, toOTLPSpan(s))
+	}
+
+	return req
+}
+
+func toOTLPSpan(s *Span) *otlpSpan {
+	start := s.Timestamp * 1e3
+	sp := &otlpSpan{
+		TraceId:           s.TraceIdStr,
+		SpanId:            s.SpanIdStr,
+		ParentSpanId:      s.ParentIdStr,
+		Name:              s.Name,
+		Kind:              otlpKindServer,
+		StartTimeUnixNano: strconv.FormatInt(start, 10),
+		EndTimeUnixNano:   strconv.FormatInt(start+s.Duration*1e3, 10),
+	}
+
+	if len(sp.TraceId) == 16 {
+		sp.TraceId = "0000000000000000" + sp.TraceId
+	}
+
+	if s.Kind == KIND_CLIENT {
+		sp.Kind = otlpKindClient
+	}
+
+	if e := s.RemoteEndPoint; e != nil {
+		sp.Attributes = append(sp.Attributes,
+			otlpAttr("peer.service", e.ServiceName),
+			otlpAttr("net.peer.ip", e.IPv4),
+			otlpAttr("net.peer.port", strconv.FormatUint(uint64(e.Port), 10)))
+	}
+
+	keys := make([]string, 0, len(s.Tags))
+	for k := range s.Tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if v, ok := s.attrs[k]; ok {
+			sp.Attributes = append(sp.Attributes, otlpTypedAttr(k, v))
+			continue
+		}
+		sp.Attributes = append(sp.Attributes, otlpAttr(k, fmt.Sprintf("%v", s.Tags[k])))
+	}
+
+	for _, l := range s.links {
+		sp.Links = append(sp.Links, otlpLink{
+			TraceId: otlpId(l.TraceIdHigh, l.TraceId, true),
+			SpanId:  otlpId(0, l.SpanId, false),
+		})
+	}
+
+	if code, msg := s.Status(); code != STATUS_UNSET {
+		sp.Status = &otlpStatus{Code: code, Message: msg}
+	} else if s.failed {
+		sp.Status = &otlpStatus{Code: STATUS_ERROR}
+	}
+
+	for _, an := range s.Annotations {
+		sp.Events = append(sp.Events, otlpEvent{
+			TimeUnixNano: strconv.FormatInt(an.Timestamp*1e3, 10),
+			Name:         an.Value,
+		})
+	}
+
+	return sp
+}
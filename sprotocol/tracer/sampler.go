@@ -0,0 +1,207 @@
+package tracer
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// DEFAULT_TAIL_MAX_SPANS 尾部采样单条链路最多缓存的span数量
+const DEFAULT_TAIL_MAX_SPANS = 512
+
+// SamplingRule 采样规则, ServiceType为发起链路的本服务类型, FunctionId为请求函数ID, 为0时匹配任意值
+type SamplingRule struct {
+	ServiceType uint16 `yaml:"service_type" json:"service_type"`
+	FunctionId  uint16 `yaml:"function_id" json:"function_id"`
+	Rate        int    `yaml:"rate" json:"rate"` // 按1/Rate概率采样, 0为不采样
+}
+
+// SamplingConfig 采样配置, 规则按顺序匹配, 未命中时使用追踪器采样率
+// 尾部采样(OnError、SlowMs)的决策在各服务进程内独立进行: 待定链路向下游传播,
+// 下游按自身规则决定是否保留其片段, 下游慢或出错时上游的调用span通常也会命中并保留;
+// 但上游在调用返回后才命中时, 无法追溯保留下游已丢弃的片段
+type SamplingConfig struct {
+	Rules []*SamplingRule `yaml:"rules" json:"rules"`
+
+	// OnError 链路中任一span出错时保留整条链路
+	OnError bool `yaml:"on_error" json:"on_error"`
+
+	// SlowMs 链路中任一span耗时超过该值(毫秒)时保留整条链路, 0为关闭
+	SlowMs int64 `yaml:"slow_ms" json:"slow_ms"`
+
+	// TargetPerSecond 自适应采样, 每秒采样的链路数量趋近该值, 0为关闭
+	TargetPerSecond int `yaml:"target_per_second" json:"target_per_second"`
+}
+
+// _Sampler 头部采样决策, 配置不可变, 更新时整体替换
+type _Sampler struct {
+	cfg SamplingConfig
+
+	mu     sync.Mutex
+	window int64
+	seen   int
+	kept   int
+	prob   float64
+}
+
+func newSampler(c *SamplingConfig) *_Sampler {
+	s := &_Sampler{prob: 1}
+	if c == nil {
+		return s
+	}
+
+	s.cfg = *c
+	s.cfg.Rules = nil
+	for _, r := range c.Rules {
+		if r != nil {
+			rule := *r
+			s.cfg.Rules = append(s.cfg.Rules, &rule)
+		}
+	}
+
+	return s
+}
+
+// tail 是否开启尾部采样
+func (s *_Sampler) tail() bool {
+	return s.cfg.OnError || s.cfg.SlowMs > 0
+}
+
+// rate 获取匹配规则的采样率
+func (s *_Sampler) rate(svrType, funcId uint16, def int) int {
+	for _, r := range s.cfg.Rules {
+		if r.ServiceType != 0 && r.ServiceType != svrType {
+			continue
+		}
+		if r.FunctionId != 0 && r.FunctionId != funcId {
+			continue
+		}
+
+		return r.Rate
+	}
+
+	return def
+}
+
+// sample 决定是否采样新链路
+func (s *_Sampler) sample(svrType, funcId uint16, def int) bool {
+	rate := s.rate(svrType, funcId, def)
+	if rate <= 0 {
+		return false
+	}
+
+	if s.cfg.TargetPerSecond > 0 {
+		return s.adaptive()
+	}
+
+	return rand.Intn(rate) == 0
+}
+
+// adaptive 按上一秒的链路数量调整采样概率, 并限制每秒采样上限
+func (s *_Sampler) adaptive() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	target := s.cfg.TargetPerSecond
+
+	now := time.Now().Unix()
+	if now != s.window {
+		if s.seen > 0 {
+			s.prob = float64(target) / float64(s.seen)
+			if s.prob > 1 {
+				s.prob = 1
+			}
+		}
+
+		s.window = now
+		s.seen = 0
+		s.kept = 0
+	}
+
+	s.seen++
+	if s.kept >= target {
+		return false
+	}
+
+	if rand.Float64() < s.prob {
+		s.kept++
+		return true
+	}
+
+	return false
+}
+
+// newPending 为未被头部采样的链路创建尾部采样缓存
+func (s *_Sampler) newPending(root *Span) *_Pending {
+	return &_Pending{
+		root:    root,
+		onError: s.cfg.OnError,
+		slowUs:  s.cfg.SlowMs * 1e3,
+	}
+}
+
+// _Pending 尾部采样缓存, 本地根span结束时决定整条链路是否保留
+type _Pending struct {
+	mu      sync.Mutex
+	root    *Span
+	spans   []*Span
+	onError bool
+	slowUs  int64
+	failed  bool
+	slow    bool
+	forced  bool
+	done    bool
+	kept    bool
+}
+
+// force 强制保留整条链路, 根span已结束时不再生效
+func (p *_Pending) force() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.forced = true
+}
+
+func (p *_Pending) end(s *Span) {
+	p.mu.Lock()
+
+	// 根span已结束, 之后结束的span按已有决策处理
+	if p.done {
+		kept := p.kept
+		p.mu.Unlock()
+
+		if kept {
+			s.getTracer().emit(s)
+		}
+		return
+	}
+
+	if s.failed {
+		p.failed = true
+	}
+	if p.slowUs > 0 && s.Duration >= p.slowUs {
+		p.slow = true
+	}
+	if len(p.spans) < DEFAULT_TAIL_MAX_SPANS {
+		p.spans = append(p.spans, s)
+	}
+
+	if s != p.root {
+		p.mu.Unlock()
+		return
+	}
+
+	p.done = true
+	p.kept = (p.onError && p.failed) || p.slow || p.forced
+	kept, spans := p.kept, p.spans
+	p.spans = nil
+	p.mu.Unlock()
+
+	if !kept {
+		return
+	}
+
+	for _, span := range spans {
+		span.getTracer().emit(span)
+	}
+}
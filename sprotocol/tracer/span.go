@@ -0,0 +1,262 @@
+package tracer
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/beinan/fastid"
+)
+
+// span状态
+const (
+	STATUS_UNSET = iota // 未设置
+	STATUS_OK           // 成功
+	STATUS_ERROR        // 失败
+)
+
+// DEFAULT_STACK_SIZE 错误堆栈最大长度
+const DEFAULT_STACK_SIZE = 4096
+
+// Link 关联其他链路的span, 如批量消费时关联各条消息的生产者span
+type Link struct {
+	TraceIdHigh int64
+	TraceId     int64
+	SpanId      int64
+}
+
+// LinkFromContext 获取context中的span作为关联
+func LinkFromContext(ctx context.Context) (Link, bool) {
+	span := GetSpan(ctx)
+	if span == nil {
+		return Link{}, false
+	}
+
+	return Link{TraceIdHigh: span.TraceIdHigh, TraceId: span.TraceId, SpanId: span.SpanId}, true
+}
+
+type _SpanConfig struct {
+	kind  string
+	start time.Time
+	links []Link
+	force bool
+}
+
+type spanOption func(*_SpanConfig)
+
+// SetSpanKind 设置span类型, 默认为CLIENT
+func SetSpanKind(kind string) spanOption {
+	return func(c *_SpanConfig) {
+		c.kind = kind
+	}
+}
+
+// SetStartTime 设置span开始时间, 用于补记已发生的调用
+func SetStartTime(t time.Time) spanOption {
+	return func(c *_SpanConfig) {
+		c.start = t
+	}
+}
+
+// SetSpanLinks 设置关联span
+func SetSpanLinks(links ...Link) spanOption {
+	return func(c *_SpanConfig) {
+		c.links = append(c.links, links...)
+	}
+}
+
+// SetForceSample 忽略采样决策强制创建span, 上游未采样时作为新链路的根节点
+func SetForceSample() spanOption {
+	return func(c *_SpanConfig) {
+		c.force = true
+	}
+}
+
+// StartSpan 在ctx的链路下创建子span, 用于数据库、缓存等任意调用, 优先使用父span所属的追踪器
+func StartSpan(ctx context.Context, name string, opts ...spanOption) (*Span, context.Context) {
+	if parent := GetSpan(ctx); parent != nil && parent.tracer != nil {
+		return parent.tracer.StartSpan(ctx, name, opts...)
+	}
+
+	return m.StartSpan(ctx, name, opts...)
+}
+
+// StartSpan 在ctx的链路下创建子span, 无父span时按采样规则创建根节点; 未采样时返回原ctx
+func (tr *Tracer) StartSpan(ctx context.Context, name string, opts ...spanOption) (*Span, context.Context) {
+	if ctx == nil {
+		ctx = context.TODO()
+	}
+
+	c := &_SpanConfig{kind: KIND_CLIENT}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	span, sctx := tr.CreateFuncSubSpan(ctx, 0)
+	if span == nil && c.force {
+		span, sctx = tr.forceSpan(ctx)
+	}
+	if span == nil {
+		return nil, ctx
+	}
+
+	if c.force {
+		span.ForceSample()
+	}
+
+	span.Name = name
+	span.Kind = c.kind
+	if !c.start.IsZero() {
+		span.Timestamp = c.start.UnixNano() / 1e3
+	}
+	span.links = append(span.links, c.links...)
+
+	return span, sctx
+}
+
+// forceSpan 不经采样直接创建根节点
+func (tr *Tracer) forceSpan(ctx context.Context) (*Span, context.Context) {
+	tr.RLock()
+	defer tr.RUnlock()
+
+	if !tr.open {
+		return nil, ctx
+	}
+
+	span := &Span{}
+	span.Kind = KIND_CLIENT
+	span.Name = tr.endpoint.ServiceName
+	span.LocalEndpoint = tr.endpoint
+	span.Timestamp = time.Now().UnixNano() / 1e3
+	span.TraceId = fastid.CommonConfig.GenInt64ID()
+	span.SpanId = fastid.CommonConfig.GenInt64ID()
+	span.tracer = tr
+	span.sampled = true
+
+	return span, context.WithValue(ctx, ctxKeyInstance, span)
+}
+
+// ForceSample 强制保留span所在链路, 用于慢调用等需要完整记录的场景
+func (s *Span) ForceSample() {
+	if s == nil || s.pending == nil {
+		return
+	}
+
+	s.pending.force()
+}
+
+// SetName 设置span名称
+func (s *Span) SetName(name string) {
+	if s == nil {
+		return
+	}
+
+	s.Name = name
+}
+
+// SetString 设置字符串属性
+func (s *Span) SetString(key, val string) {
+	s.setAttr(key, val)
+}
+
+// SetInt 设置整型属性
+func (s *Span) SetInt(key string, val int64) {
+	s.setAttr(key, val)
+}
+
+// SetFloat 设置浮点属性
+func (s *Span) SetFloat(key string, val float64) {
+	s.setAttr(key, val)
+}
+
+// SetBool 设置布尔属性
+func (s *Span) SetBool(key string, val bool) {
+	s.setAttr(key, val)
+}
+
+// setAttr 保留类型供OTLP导出, 同时以字符串写入Tags兼容Zipkin格式
+func (s *Span) setAttr(key string, val interface{}) {
+	if s == nil {
+		return
+	}
+
+	if s.attrs == nil {
+		s.attrs = make(map[string]interface{}, 0)
+	}
+	s.attrs[key] = val
+
+	s.Tag(key, val)
+}
+
+// AddEvent 记录事件, 以当前时间写入annotation
+func (s *Span) AddEvent(name string) {
+	s.AddAnnotation(name, time.Now().UnixNano()/1e3)
+}
+
+// AddLink 添加关联span
+func (s *Span) AddLink(link Link) {
+	if s == nil {
+		return
+	}
+
+	s.links = append(s.links, link)
+}
+
+// Links 获取关联span
+func (s *Span) Links() []Link {
+	if s == nil {
+		return nil
+	}
+
+	return s.links
+}
+
+// SetStatus 设置span状态, STATUS_ERROR同时标记出错
+func (s *Span) SetStatus(code int, msg string) {
+	if s == nil {
+		return
+	}
+
+	s.statusCode = code
+	s.statusMsg = msg
+
+	switch code {
+	case STATUS_OK:
+		s.Tag("otel.status_code", "OK")
+	case STATUS_ERROR:
+		s.Tag("otel.status_code", "ERROR")
+		s.MarkError()
+	}
+
+	if msg != "" {
+		s.Tag("otel.status_description", msg)
+	}
+}
+
+// Status 获取span状态
+func (s *Span) Status() (int, string) {
+	if s == nil {
+		return STATUS_UNSET, ""
+	}
+
+	return s.statusCode, s.statusMsg
+}
+
+// RecordError 记录错误及调用堆栈, 并将状态置为STATUS_ERROR
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+
+	stack := debug.Stack()
+	if len(stack) > DEFAULT_STACK_SIZE {
+		stack = stack[:DEFAULT_STACK_SIZE]
+	}
+
+	s.AddEvent("exception: " + err.Error())
+	s.SetString("exception.type", fmt.Sprintf("%T", err))
+	s.SetString("exception.message", err.Error())
+	s.SetString("exception.stacktrace", string(stack))
+	s.SetStatus(STATUS_ERROR, err.Error())
+}
@@ -6,7 +6,6 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"math/rand"
 	"sync"
 	"time"
 
@@ -25,57 +24,134 @@ const (
 	DEFAULT_TRACER_RATE = 5000
 )
 
-type _Manager struct {
+// Tracer 链路追踪器, 每个服务身份持有一个
+type Tracer struct {
 	sync.RWMutex
 
 	open       bool
 	rate       int
 	endpoint   *Endpoint
 	aggregator func([]byte, string) error
+	batchers   []*Batcher
+	sampler    *_Sampler
 }
 
-var m *_Manager = &_Manager{rate: DEFAULT_TRACER_RATE}
+var m *Tracer = &Tracer{rate: DEFAULT_TRACER_RATE, sampler: newSampler(nil)}
 
+// New 创建链路追踪器
+func New(serviceType uint16, serviceId uint32, serviceName string, rate int, fp func([]byte, string) error) *Tracer {
+	tr := &Tracer{sampler: newSampler(nil)}
+	tr.init(serviceType, serviceId, serviceName, rate, fp)
+	return tr
+}
+
+// Default 获取默认链路追踪器
+func Default() *Tracer {
+	return m
+}
+
+// Init 初始化默认链路追踪器
 func Init(serviceType uint16, serviceId uint32, serviceName string, rate int, fp func([]byte, string) error) {
-	m.Lock()
-	defer m.Unlock()
+	m.init(serviceType, serviceId, serviceName, rate, fp)
+}
 
-	m.rate = rate
+// Update 更新默认链路追踪器采样率
+func Update(rate int) {
+	m.Update(rate)
+}
+
+// SetSampling 更新默认链路追踪器采样配置
+func SetSampling(c *SamplingConfig) {
+	m.SetSampling(c)
+}
+
+// SetSampling 更新采样规则、尾部采样与自适应采样配置, 可在运行时调用
+func (tr *Tracer) SetSampling(c *SamplingConfig) {
+	s := newSampler(c)
+
+	tr.Lock()
+	defer tr.Unlock()
+
+	tr.sampler = s
+}
+
+// AddExporter 为默认链路追踪器添加导出器
+func AddExporter(exp Exporter, opts ...option) *Batcher {
+	return m.AddExporter(exp, opts...)
+}
+
+// Shutdown 导出默认链路追踪器剩余span并关闭全部导出器
+func Shutdown() {
+	m.Shutdown()
+}
+
+// AddExporter 添加导出器, span结束时除发送至aggregator外同时放入导出队列
+func (tr *Tracer) AddExporter(exp Exporter, opts ...option) *Batcher {
+	b := NewBatcher(exp, opts...)
+
+	tr.Lock()
+	tr.batchers = append(tr.batchers, b)
+	tr.Unlock()
+
+	return b
+}
+
+// Shutdown 导出剩余span并关闭全部导出器
+func (tr *Tracer) Shutdown() {
+	tr.Lock()
+	batchers := tr.batchers
+	tr.batchers = nil
+	tr.Unlock()
+
+	for _, b := range batchers {
+		if err := b.Shutdown(); err != nil {
+			logs.Errorf("Shutdown exporter err: %v", err.Error())
+		}
+	}
+}
+
+func (tr *Tracer) init(serviceType uint16, serviceId uint32, serviceName string, rate int, fp func([]byte, string) error) {
+	tr.Lock()
+	defer tr.Unlock()
+
+	tr.rate = rate
 	if rate < 0 {
-		m.rate = DEFAULT_TRACER_RATE
+		tr.rate = DEFAULT_TRACER_RATE
 	}
 
-	if m.rate > 0 {
-		m.open = true
+	if tr.rate > 0 {
+		tr.open = true
 	}
 
-	m.endpoint = &Endpoint{}
-	m.endpoint.ServiceType = serviceType
-	m.endpoint.ServiceName = serviceName
-	m.endpoint.ServiceId = serviceId
-	m.endpoint.IPv4 = utils.Long2ip(serviceId)
+	tr.endpoint = &Endpoint{}
+	tr.endpoint.ServiceType = serviceType
+	tr.endpoint.ServiceName = serviceName
+	tr.endpoint.ServiceId = serviceId
+	tr.endpoint.IPv4 = utils.Long2ip(serviceId)
 
-	m.aggregator = fp
+	tr.aggregator = fp
 }
 
-func Update(rate int) {
-	m.Lock()
-	defer m.Unlock()
+// Update 更新采样率
+func (tr *Tracer) Update(rate int) {
+	tr.Lock()
+	defer tr.Unlock()
 
 	if rate < 0 {
 		return
 	}
 
-	m.rate = rate
+	tr.rate = rate
 
-	if m.rate == 0 {
-		m.open = false
+	if tr.rate == 0 {
+		tr.open = false
 	}
 
 	return
 }
 
 type Span struct {
+	TraceIdHigh    int64                  `json:"-"`
 	TraceId        int64                  `json:"-"`
 	TraceIdStr     string                 `json:"traceId"`
 	ParentId       int64                  `json:"-"`
@@ -90,11 +166,33 @@ type Span struct {
 	RemoteEndPoint *Endpoint              `json:"remoteEndpoint,omitempty"`
 	Annotations    []Annotation           `json:"annotations,omitempty"`
 	Tags           map[string]interface{} `json:"tags,omitempty"`
+
+	tracer  *Tracer
+	sampled bool
+	failed  bool
+	pending *_Pending
+
+	attrs      map[string]interface{}
+	links      []Link
+	statusCode int
+	statusMsg  string
 }
 
 type PropagateSpan struct {
-	traceId int64
-	id      int64
+	traceIdHigh int64
+	traceId     int64
+	id          int64
+	sampled     bool
+	pending     *_Pending
+}
+
+// SetTraceIdHigh 设置128位trace id的高64位, 用于W3C/B3传播
+func (s *PropagateSpan) SetTraceIdHigh(high int64) {
+	if s == nil {
+		return
+	}
+
+	s.traceIdHigh = high
 }
 
 type Endpoint struct {
@@ -112,30 +210,92 @@ type Annotation struct {
 
 // CreateSpan 创建span, kind为SERVER
 func CreateSpan(traceId, parentId int64) (*Span, context.Context) {
-	m.RLock()
-	defer m.RUnlock()
+	return m.CreateSpan(traceId, parentId)
+}
 
-	return createSpan(traceId, parentId)
+// CreateDeferredSpan 创建上游处于尾部采样中的span, kind为SERVER
+func CreateDeferredSpan(traceId, parentId int64) (*Span, context.Context) {
+	return m.CreateDeferredSpan(traceId, parentId)
 }
 
 // CreatePropSpan 根据概率创建span，kind为SERVER
 func CreateProbSpan(traceId, parentId int64) (*Span, context.Context) {
-	m.RLock()
-	defer m.RUnlock()
+	return m.CreateProbSpan(traceId, parentId)
+}
+
+// CreateSubSpan 创建span，kind为CLIENT, 优先使用父span所属的追踪器
+func CreateSubSpan(ctx context.Context) (*Span, context.Context) {
+	if parent := GetSpan(ctx); parent != nil && parent.tracer != nil {
+		return parent.tracer.CreateSubSpan(ctx)
+	}
+
+	return m.CreateSubSpan(ctx)
+}
+
+// CreateFuncSubSpan 创建span, kind为CLIENT, funcId用于匹配采样规则
+func CreateFuncSubSpan(ctx context.Context, funcId uint16) (*Span, context.Context) {
+	if parent := GetSpan(ctx); parent != nil && parent.tracer != nil {
+		return parent.tracer.CreateFuncSubSpan(ctx, funcId)
+	}
+
+	return m.CreateFuncSubSpan(ctx, funcId)
+}
+
+// CreatePropagateSpan 创建传播span, 用于http服务
+func CreatePropagateSpan(traceId, parentId int64) (*PropagateSpan, context.Context) {
+	return m.CreatePropagateSpan(traceId, parentId)
+}
+
+// CreateSpan 创建span, kind为SERVER
+func (tr *Tracer) CreateSpan(traceId, parentId int64) (*Span, context.Context) {
+	tr.RLock()
+	defer tr.RUnlock()
+
+	return tr.createSpan(traceId, parentId)
+}
+
+// CreatePropSpan 根据概率创建span，kind为SERVER
+func (tr *Tracer) CreateProbSpan(traceId, parentId int64) (*Span, context.Context) {
+	tr.RLock()
+	defer tr.RUnlock()
 
-	if !m.open {
+	if !tr.open {
 		return nil, context.WithValue(context.TODO(), ctxNoopKeyInstance, &ctxNoopKey{})
 	}
 
-	if rand.Intn(m.rate) != 0 {
+	sampled := tr.sampler.sample(tr.endpoint.ServiceType, 0, tr.rate)
+	if !sampled && !tr.sampler.tail() {
 		return nil, context.WithValue(context.TODO(), ctxNoopKeyInstance, &ctxNoopKey{})
 	}
 
-	return createSpan(traceId, parentId)
+	span, ctx := tr.createSpan(traceId, parentId)
+	if !sampled {
+		span.sampled = false
+		span.pending = tr.sampler.newPending(span)
+	}
+
+	return span, ctx
+}
+
+// CreateDeferredSpan 创建上游处于尾部采样中的span, kind为SERVER
+// 上游的保留决策无法传回, 本服务按自身的尾部采样规则在本地根span结束时决定是否保留
+func (tr *Tracer) CreateDeferredSpan(traceId, parentId int64) (*Span, context.Context) {
+	tr.RLock()
+	defer tr.RUnlock()
+
+	span, ctx := tr.createSpan(traceId, parentId)
+	if span == nil {
+		return span, ctx
+	}
+
+	span.sampled = false
+	span.pending = tr.sampler.newPending(span)
+
+	return span, ctx
 }
 
-func createSpan(traceId, parentId int64) (*Span, context.Context) {
-	if !m.open {
+func (tr *Tracer) createSpan(traceId, parentId int64) (*Span, context.Context) {
+	if !tr.open {
 		return nil, context.WithValue(context.TODO(), ctxNoopKeyInstance, &ctxNoopKey{})
 	}
 
@@ -145,15 +305,22 @@ func createSpan(traceId, parentId int64) (*Span, context.Context) {
 	span.SpanId = fastid.CommonConfig.GenInt64ID()
 
 	span.Kind = KIND_SERVER
-	span.Name = m.endpoint.ServiceName
-	span.LocalEndpoint = m.endpoint
+	span.Name = tr.endpoint.ServiceName
+	span.LocalEndpoint = tr.endpoint
 	span.Timestamp = time.Now().UnixNano() / 1e3
+	span.tracer = tr
+	span.sampled = true
 
 	return span, context.WithValue(context.TODO(), ctxKeyInstance, span)
 }
 
 // CreateSubSpan 创建span，kind为CLIENT
-func CreateSubSpan(ctx context.Context) (*Span, context.Context) {
+func (tr *Tracer) CreateSubSpan(ctx context.Context) (*Span, context.Context) {
+	return tr.CreateFuncSubSpan(ctx, 0)
+}
+
+// CreateFuncSubSpan 创建span，kind为CLIENT, 无父span时按funcId匹配采样规则创建根节点
+func (tr *Tracer) CreateFuncSubSpan(ctx context.Context, funcId uint16) (*Span, context.Context) {
 	if ctx == nil {
 		return nil, context.WithValue(context.TODO(), ctxNoopKeyInstance, &ctxNoopKey{})
 	}
@@ -162,25 +329,29 @@ func CreateSubSpan(ctx context.Context) (*Span, context.Context) {
 		return nil, ctx
 	}
 
-	m.RLock()
-	defer m.RUnlock()
+	tr.RLock()
+	defer tr.RUnlock()
 
-	if !m.open {
+	if !tr.open {
 		return nil, context.WithValue(context.TODO(), ctxNoopKeyInstance, &ctxNoopKey{})
 	}
 
 	span := &Span{}
 	span.Kind = KIND_CLIENT
-	span.Name = m.endpoint.ServiceName
-	span.LocalEndpoint = m.endpoint
+	span.Name = tr.endpoint.ServiceName
+	span.LocalEndpoint = tr.endpoint
 	span.Timestamp = time.Now().UnixNano() / 1e3
 	span.SpanId = fastid.CommonConfig.GenInt64ID()
+	span.tracer = tr
 
 	// core服务父span创建子span
 	parentSpan, ok := ctx.Value(ctxKeyInstance).(*Span)
 	if ok {
+		span.TraceIdHigh = parentSpan.TraceIdHigh
 		span.TraceId = parentSpan.TraceId
 		span.ParentId = parentSpan.SpanId
+		span.sampled = parentSpan.sampled
+		span.pending = parentSpan.pending
 
 		return span, context.WithValue(ctx, ctxKeyInstance, span)
 	}
@@ -188,15 +359,22 @@ func CreateSubSpan(ctx context.Context) (*Span, context.Context) {
 	// http服务父span创建子span
 	propagateSpan, ok := ctx.Value(ctxPropagateKeyInstance).(*PropagateSpan)
 	if ok {
+		span.TraceIdHigh = propagateSpan.traceIdHigh
 		span.TraceId = propagateSpan.traceId
 		span.ParentId = propagateSpan.id
+		span.sampled = propagateSpan.sampled
+		span.pending = propagateSpan.pending
 
 		return span, context.WithValue(ctx, ctxKeyInstance, span)
 	}
 
-	// 创建根节点
-	if rand.Intn(int(m.rate)) != 0 {
-		return nil, context.WithValue(context.TODO(), ctxNoopKeyInstance, &ctxNoopKey{})
+	// 创建根节点, 未被采样但开启尾部采样时先缓存, 根节点结束后再决定
+	span.sampled = tr.sampler.sample(tr.endpoint.ServiceType, funcId, tr.rate)
+	if !span.sampled {
+		if !tr.sampler.tail() {
+			return nil, context.WithValue(context.TODO(), ctxNoopKeyInstance, &ctxNoopKey{})
+		}
+		span.pending = tr.sampler.newPending(span)
 	}
 
 	span.TraceId = fastid.CommonConfig.GenInt64ID()
@@ -204,22 +382,63 @@ func CreateSubSpan(ctx context.Context) (*Span, context.Context) {
 	return span, context.WithValue(ctx, ctxKeyInstance, span)
 }
 
-func CreatePropagateSpan(traceId, parentId int64) (*PropagateSpan, context.Context) {
-	m.RLock()
-	defer m.RUnlock()
+// CreatePropagateSpan 创建传播span, 用于http服务
+func (tr *Tracer) CreatePropagateSpan(traceId, parentId int64) (*PropagateSpan, context.Context) {
+	tr.RLock()
+	defer tr.RUnlock()
 
-	if !m.open {
+	if !tr.open {
 		return nil, context.WithValue(context.TODO(), ctxNoopKeyInstance, &ctxNoopKey{})
 	}
 
 	s := &PropagateSpan{
 		traceId: traceId,
 		id:      parentId,
+		sampled: true,
 	}
 
 	return s, context.WithValue(context.TODO(), ctxPropagateKeyInstance, s)
 }
 
+// PropagateContext 以当前span为父节点创建传播context, 保留128位trace id与采样状态
+func (s *Span) PropagateContext() context.Context {
+	if s == nil {
+		return NoopContext()
+	}
+
+	ps := &PropagateSpan{
+		traceIdHigh: s.TraceIdHigh,
+		traceId:     s.TraceId,
+		id:          s.SpanId,
+		sampled:     s.sampled,
+		pending:     s.pending,
+	}
+
+	return context.WithValue(context.TODO(), ctxPropagateKeyInstance, ps)
+}
+
+// NoopContext 创建不采样的context, 其下不再创建子span
+func NoopContext() context.Context {
+	return context.WithValue(context.TODO(), ctxNoopKeyInstance, &ctxNoopKey{})
+}
+
+// HasParent ctx中是否存在可作为父节点的span(本服务span或上游传播span)
+func HasParent(ctx context.Context) bool {
+	if GetSpan(ctx) != nil {
+		return true
+	}
+
+	if ctx == nil {
+		return false
+	}
+	if _, ok := ctx.Value(ctxNoopKeyInstance).(*ctxNoopKey); ok {
+		return false
+	}
+
+	_, ok := ctx.Value(ctxPropagateKeyInstance).(*PropagateSpan)
+	return ok
+}
+
 func GetSpan(ctx context.Context) *Span {
 	if ctx == nil {
 		return nil
@@ -245,6 +464,42 @@ func (s *Span) GetTraceID() int64 {
 	return s.TraceId
 }
 
+// GetTraceIDHigh 获取128位trace id的高64位, 64位trace id时为0
+func (s *Span) GetTraceIDHigh() int64 {
+	if s == nil {
+		return 0
+	}
+
+	return s.TraceIdHigh
+}
+
+// SetTraceIdHigh 设置128位trace id的高64位, 用于W3C/B3传播
+func (s *Span) SetTraceIdHigh(high int64) {
+	if s == nil {
+		return
+	}
+
+	s.TraceIdHigh = high
+}
+
+// IsSampled 是否被头部采样, 未被采样的span仅在尾部采样命中时上报, 向下游传播时标记为待定
+func (s *Span) IsSampled() bool {
+	if s == nil {
+		return false
+	}
+
+	return s.sampled
+}
+
+// MarkError 标记span出错, 开启错误采样时整条链路会被保留
+func (s *Span) MarkError() {
+	if s == nil {
+		return
+	}
+
+	s.failed = true
+}
+
 func (s *Span) GetSpanID() int64 {
 	if s == nil {
 		return 0
@@ -331,6 +586,11 @@ func (s *Span) End() {
 	binary.BigEndian.PutUint64(pids[:], uint64(s.ParentId))
 	binary.BigEndian.PutUint64(sids[:], uint64(s.SpanId))
 	s.TraceIdStr = hex.EncodeToString(tids[:])
+	if s.TraceIdHigh != 0 {
+		var hids [8]byte
+		binary.BigEndian.PutUint64(hids[:], uint64(s.TraceIdHigh))
+		s.TraceIdStr = hex.EncodeToString(hids[:]) + s.TraceIdStr
+	}
 	s.ParentIdStr = hex.EncodeToString(pids[:])
 	s.SpanIdStr = hex.EncodeToString(sids[:])
 	s.Duration = time.Now().UnixNano()/1e3 - s.Timestamp
@@ -339,17 +599,44 @@ func (s *Span) End() {
 		s.ParentIdStr = ""
 	}
 
+	if s.pending != nil {
+		s.pending.end(s)
+		return
+	}
+
+	s.getTracer().emit(s)
+
+	return
+}
+
+func (s *Span) getTracer() *Tracer {
+	if s.tracer == nil {
+		return m
+	}
+
+	return s.tracer
+}
+
+// emit 上报已结束的span
+func (tr *Tracer) emit(s *Span) {
 	msg, _ := json.Marshal(s)
 
-	if m.aggregator == nil {
+	tr.RLock()
+	aggregator := tr.aggregator
+	batchers := tr.batchers
+	tr.RUnlock()
+
+	for _, b := range batchers {
+		b.Enqueue(s)
+	}
+
+	if aggregator == nil {
 		logs.Debug(string(msg))
 	} else {
-		if err := m.aggregator(msg, utils.I64toA(s.TraceId)); err != nil {
+		if err := aggregator(msg, utils.I64toA(s.TraceId)); err != nil {
 			logs.Error(err.Error())
 		}
 	}
-
-	return
 }
 
 type ctxKey struct{}
@@ -0,0 +1,101 @@
+package tracer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ZipkinExporter 以Zipkin v2 JSON格式通过HTTP导出span
+type ZipkinExporter struct {
+	url    string
+	client *http.Client
+}
+
+// NewZipkinExporter 创建Zipkin导出器, url如 http://127.0.0.1:9411/api/v2/spans
+func NewZipkinExporter(url string, timeout time.Duration) *ZipkinExporter {
+	if timeout <= 0 {
+		timeout = DEFAULT_EXPORT_TIMEOUT
+	}
+
+	return &ZipkinExporter{
+		url:    url,
+		client: &http.Client{Timeout: timeout},
+	}
+}
+
+func (this *ZipkinExporter) Export(spans []*Span) error {
+	body, err := json.Marshal(toZipkinSpans(spans))
+	if err != nil {
+		return err
+	}
+
+	return postJSON(this.client, this.url, body, nil)
+}
+
+func (this *ZipkinExporter) Shutdown() error {
+	return nil
+}
+
+type zipkinEndpoint struct {
+	ServiceName string `json:"serviceName,omitempty"`
+	IPv4        string `json:"ipv4,omitempty"`
+	Port        uint16 `json:"port,omitempty"`
+}
+
+type zipkinSpan struct {
+	TraceId        string            `json:"traceId"`
+	ParentId       string            `json:"parentId,omitempty"`
+	Id             string            `json:"id"`
+	Name           string            `json:"name"`
+	Kind           string            `json:"kind,omitempty"`
+	Timestamp      int64             `json:"timestamp"`
+	Duration       int64             `json:"duration"`
+	LocalEndpoint  *zipkinEndpoint   `json:"localEndpoint,omitempty"`
+	RemoteEndpoint *zipkinEndpoint   `json:"remoteEndpoint,omitempty"`
+	Annotations    []Annotation      `json:"annotations,omitempty"`
+	Tags           map[string]string `json:"tags,omitempty"`
+}
+
+func toZipkinEndpoint(e *Endpoint) *zipkinEndpoint {
+	if e == nil {
+		return nil
+	}
+
+	return &zipkinEndpoint{
+		ServiceName: e.ServiceName,
+		IPv4:        e.IPv4,
+		Port:        e.Port,
+	}
+}
+
+// toZipkinSpans 转换为标准Zipkin v2格式, 去除私有的服务类型与ID字段
+func toZipkinSpans(spans []*Span) []*zipkinSpan {
+	list := make([]*zipkinSpan, 0, len(spans))
+	for _, s := range spans {
+		zs := &zipkinSpan{
+			TraceId:        s.TraceIdStr,
+			ParentId:       s.ParentIdStr,
+			Id:             s.SpanIdStr,
+			Name:           s.Name,
+			Kind:           s.Kind,
+			Timestamp:      s.Timestamp,
+			Duration:       s.Duration,
+			LocalEndpoint:  toZipkinEndpoint(s.LocalEndpoint),
+			RemoteEndpoint: toZipkinEndpoint(s.RemoteEndPoint),
+			Annotations:    s.Annotations,
+		}
+
+		if len(s.Tags) > 0 {
+			zs.Tags = make(map[string]string, len(s.Tags))
+			for k, v := range s.Tags {
+				zs.Tags[k] = fmt.Sprintf("%v", v)
+			}
+		}
+
+		list = append(list, zs)
+	}
+
+	return list
+}